@@ -0,0 +1,105 @@
+package main
+
+import (
+	"backend/analysis_service"
+	"backend/notes_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmotionSeriesRequest struct {
+	// First and last day of the series, e.g. 2024-01-05, both included
+	From string `json:"From"`
+	To   string `json:"To"`
+	// "day" (the default), "week" or "month"
+	Bucket string `json:"Bucket"`
+}
+
+func noteAnalysisHandler(w http.ResponseWriter, r *http.Request, analysisService *analysis_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noteId, err := uuid.Parse(req.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+
+	analysis, err := analysisService.NoteAnalysis(r.Context(), noteId)
+	switch {
+	case errors.Is(err, notes_service.ErrNoteNotFound):
+		http.Error(w, "Note not found", http.StatusNotFound)
+	case errors.Is(err, analysis_service.ErrAnalysisNotFound):
+		http.Error(w, "Note has not been analysed yet", http.StatusNotFound)
+	case err != nil:
+		log.Printf("Error getting note analysis: %v", err)
+		http.Error(w, "Failed to get note analysis", http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(analysis)
+	}
+}
+
+func emotionSeriesHandler(w http.ResponseWriter, r *http.Request, analysisService *analysis_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EmotionSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	from, err := time.ParseInLocation(time.DateOnly, req.From, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid From date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, req.To, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid To date", http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := analysisService.EmotionSeries(r.Context(), from, to, req.Bucket)
+	if errors.Is(err, analysis_service.ErrInvalidRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting emotion series: %v", err)
+		http.Error(w, "Failed to get emotion series", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+func analysisStatusHandler(w http.ResponseWriter, r *http.Request, analysisService *analysis_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, err := analysisService.Status(r.Context())
+	if err != nil {
+		log.Printf("Error getting analysis status: %v", err)
+		http.Error(w, "Failed to get analysis status", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,153 @@
+package analysis_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+var ErrInvalidRange = errors.New("invalid range")
+
+// Most buckets EmotionSeries returns, about ten years of days
+const maxBuckets = 3660
+
+// Topics listed per bucket
+const topTopics = 5
+
+// EmotionBucket aggregates the analysed entries of a day, week or month
+type EmotionBucket struct {
+	// First day of the bucket in the local time zone, e.g. 2024-01-01. Weeks
+	// start on Monday.
+	Start   string `json:"Start"`
+	Entries int    `json:"Entries"`
+	// Mean valence of the entries, 0 when there are none
+	Valence float64 `json:"Valence"`
+	// Mean intensity of each emotion across the entries, counting entries
+	// without the emotion as 0
+	Emotions map[string]float64 `json:"Emotions"`
+	Topics   []TopicCount       `json:"Topics"`
+}
+
+type TopicCount struct {
+	Topic string `json:"Topic"`
+	Count int    `json:"Count"`
+}
+
+// EmotionSeries aggregates analyses by bucket for entries created from the
+// day of from to the day of to, inclusive. Every bucket overlapping the range
+// is returned, empty or not, so the series can be charted directly.
+func (service *Service) EmotionSeries(ctx context.Context, from time.Time, to time.Time, bucket string) ([]EmotionBucket, error) {
+	if bucket == "" {
+		bucket = BucketDay
+	}
+	if bucket != BucketDay && bucket != BucketWeek && bucket != BucketMonth {
+		return nil, fmt.Errorf("%w: bucket must be %q, %q or %q", ErrInvalidRange, BucketDay, BucketWeek, BucketMonth)
+	}
+	from = startOfDay(from)
+	end := startOfDay(to).AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return nil, fmt.Errorf("%w: the range ends before it starts", ErrInvalidRange)
+	}
+
+	buckets := []EmotionBucket{}
+	indexByStart := map[string]int{}
+	for start := bucketStart(from, bucket); start.Before(end); start = nextBucket(start, bucket) {
+		if len(buckets) == maxBuckets {
+			return nil, fmt.Errorf("%w: more than %d buckets", ErrInvalidRange, maxBuckets)
+		}
+		key := start.Format(time.DateOnly)
+		indexByStart[key] = len(buckets)
+		buckets = append(buckets, EmotionBucket{Start: key, Emotions: map[string]float64{}, Topics: []TopicCount{}})
+	}
+
+	analyses, createdAt, err := loadAnalyses(ctx, service.dbClient, "julianday(n.created_at) >= julianday(?) AND julianday(n.created_at) < julianday(?)", from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]map[string]int, len(buckets))
+	for noteId, analysis := range analyses {
+		created := createdAt[noteId].Local()
+		if created.Before(from) || !created.Before(end) {
+			continue
+		}
+		i := indexByStart[bucketStart(created, bucket).Format(time.DateOnly)]
+		buckets[i].Entries++
+		// Sums until every analysis is read
+		buckets[i].Valence += analysis.Valence
+		for _, emotion := range analysis.Emotions {
+			buckets[i].Emotions[emotion.Emotion] += emotion.Intensity
+		}
+		if topics[i] == nil {
+			topics[i] = map[string]int{}
+		}
+		for _, topic := range analysis.Topics {
+			topics[i][topic]++
+		}
+	}
+
+	for i := range buckets {
+		if buckets[i].Entries == 0 {
+			continue
+		}
+		entries := float64(buckets[i].Entries)
+		buckets[i].Valence /= entries
+		for emotion, sum := range buckets[i].Emotions {
+			buckets[i].Emotions[emotion] = sum / entries
+		}
+		buckets[i].Topics = rankTopics(topics[i])
+	}
+	return buckets, nil
+}
+
+// rankTopics returns the most frequent topics, ties broken alphabetically
+func rankTopics(counts map[string]int) []TopicCount {
+	ranked := []TopicCount{}
+	for topic, count := range counts {
+		ranked = append(ranked, TopicCount{Topic: topic, Count: count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Topic < ranked[j].Topic
+	})
+	return ranked[:min(len(ranked), topTopics)]
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func bucketStart(t time.Time, bucket string) time.Time {
+	day := startOfDay(t)
+	switch bucket {
+	case BucketWeek:
+		// Weekday counts from Sunday, weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case BucketMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+The analysis_service package runs journal entries through the local model in
+the background to tag them with emotions, an overall valence and topics, and
+aggregates the results over time.
+
+Each analysis is stored with a hash of the note it was made from. After notes
+change, and periodically in case the model was not available, every note whose
+hash no longer matches is analysed again, one at a time.
+*/
+package analysis_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entries are saved as they are typed, so analysis waits for edits to settle
+const debounce = 30 * time.Second
+
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// Analyser is the part of the chat service the pipeline needs
+type Analyser interface {
+	AnalyseEntry(ctx context.Context, title string, content string) (lm_service.EntryAnalysis, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	// Provides Run, Trigger, Close and NotifyOnChange
+	*sweep.Worker
+	notesService notes_service.NotesService
+	analyser     Analyser
+	dbClient     *db_client.DBClient
+}
+
+// Status reports how far the pipeline has got
+type Status struct {
+	Analysed int `json:"Analysed"`
+	// Notes never analysed or changed since
+	Pending    int  `json:"Pending"`
+	ModelReady bool `json:"ModelReady"`
+}
+
+func NewService(notesService notes_service.NotesService, analyser Analyser, dbClient *db_client.DBClient) *Service {
+	service := &Service{
+		notesService: notesService,
+		analyser:     analyser,
+		dbClient:     dbClient,
+	}
+	service.Worker = sweep.New(notesService, sweep.Job{
+		Name:     "Entry analysis",
+		Version:  analysisVersion,
+		Debounce: debounce,
+		Ready:    analyser.GetStatus,
+		Hashes: func(ctx context.Context) (map[uuid.UUID]string, error) {
+			return loadHashes(ctx, dbClient)
+		},
+		Read: service.analyse,
+	})
+	return service
+}
+
+// analyse analyses a note, or removes its analysis once it has no content
+func (service *Service) analyse(ctx context.Context, note notes_service.Note) error {
+	if strings.TrimSpace(note.Content) == "" {
+		// Nothing to read, and an earlier analysis no longer applies
+		return deleteAnalysis(ctx, service.dbClient, note.NoteId)
+	}
+	result, err := service.analyser.AnalyseEntry(ctx, note.Title, note.Content)
+	if err != nil {
+		return err
+	}
+	return saveAnalysis(ctx, service.dbClient, newNoteAnalysis(note, result))
+}
+
+func (service *Service) Status(ctx context.Context) (Status, error) {
+	pending, err := service.Pending(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	hashes, err := loadHashes(ctx, service.dbClient)
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{
+		Analysed:   len(hashes),
+		Pending:    len(pending),
+		ModelReady: service.analyser.GetStatus(),
+	}, nil
+}
+
+// NoteAnalysis returns the stored analysis of a note, marked stale if the
+// note has changed since
+func (service *Service) NoteAnalysis(ctx context.Context, noteId uuid.UUID) (NoteAnalysis, error) {
+	note, err := service.notesService.GetNote(ctx, noteId)
+	if err != nil {
+		return NoteAnalysis{}, err
+	}
+	analyses, _, err := loadAnalyses(ctx, service.dbClient, "n.id = ?", noteId)
+	if err != nil {
+		return NoteAnalysis{}, err
+	}
+	analysis, ok := analyses[noteId]
+	if !ok {
+		return NoteAnalysis{}, fmt.Errorf("%w: %v", ErrAnalysisNotFound, noteId)
+	}
+	analysis.Stale = analysis.contentHash != sweep.ContentHash(analysisVersion, note)
+	return *analysis, nil
+}
+
+// Topics returns the topics of every analysed note, keyed by note id. Notes
+// changed since they were analysed keep their earlier topics.
+func (service *Service) Topics(ctx context.Context) (map[uuid.UUID][]string, error) {
+	return loadTopics(ctx, service.dbClient)
+}
@@ -0,0 +1,177 @@
+package analysis_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteAnalysis is the stored analysis of a note
+type NoteAnalysis struct {
+	NoteId   uuid.UUID          `json:"NoteId"`
+	Emotions []EmotionIntensity `json:"Emotions"`
+	// Overall tone from -1 (very negative) to 1 (very positive)
+	Valence    float64   `json:"Valence"`
+	Topics     []string  `json:"Topics"`
+	AnalysedAt time.Time `json:"AnalysedAt"`
+	// Set when the note has changed since it was analysed
+	Stale bool `json:"Stale"`
+
+	contentHash string
+}
+
+type EmotionIntensity struct {
+	Emotion string `json:"Emotion"`
+	// From 0 (barely present) to 1 (overwhelming)
+	Intensity float64 `json:"Intensity"`
+}
+
+// Bumped when the prompt or schema changes, so every note is analysed again
+const analysisVersion = "1"
+
+func newNoteAnalysis(note notes_service.Note, analysis lm_service.EntryAnalysis) NoteAnalysis {
+	emotions := []EmotionIntensity{}
+	for _, score := range analysis.Emotions {
+		emotions = append(emotions, EmotionIntensity{Emotion: score.Emotion, Intensity: score.Intensity})
+	}
+	return NoteAnalysis{
+		NoteId:      note.NoteId,
+		Emotions:    emotions,
+		Valence:     analysis.Valence,
+		Topics:      analysis.Topics,
+		AnalysedAt:  time.Now(),
+		contentHash: sweep.ContentHash(analysisVersion, note),
+	}
+}
+
+// loadHashes returns the content hash of every analysed note
+func loadHashes(ctx context.Context, executor db_client.Executor) (map[uuid.UUID]string, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, content_hash FROM note_analyses")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[uuid.UUID]string{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var hash string
+		if err := rows.Scan(&noteId, &hash); err != nil {
+			return nil, err
+		}
+		hashes[noteId] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadAnalyses loads the analyses of the notes matching where, a condition on
+// the notes table aliased n, keyed by note id. The creation time of each note
+// is returned alongside.
+func loadAnalyses(ctx context.Context, executor db_client.Executor, where string, args ...any) (map[uuid.UUID]*NoteAnalysis, map[uuid.UUID]time.Time, error) {
+	rows, err := executor.QueryContext(ctx, `SELECT a.note_id, a.content_hash, a.valence, a.topics, a.analysed_at, n.created_at
+		FROM note_analyses a JOIN notes n ON n.id = a.note_id WHERE `+where, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	analyses := map[uuid.UUID]*NoteAnalysis{}
+	createdAt := map[uuid.UUID]time.Time{}
+	for rows.Next() {
+		var analysis NoteAnalysis
+		var topics string
+		var noteCreatedAt time.Time
+		if err := rows.Scan(&analysis.NoteId, &analysis.contentHash, &analysis.Valence, &topics, &analysis.AnalysedAt, &noteCreatedAt); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal([]byte(topics), &analysis.Topics); err != nil {
+			return nil, nil, err
+		}
+		analysis.Emotions = []EmotionIntensity{}
+		analyses[analysis.NoteId] = &analysis
+		createdAt[analysis.NoteId] = noteCreatedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+
+	emotionRows, err := executor.QueryContext(ctx, `SELECT e.note_id, e.emotion, e.intensity FROM note_emotions e
+		JOIN notes n ON n.id = e.note_id WHERE `+where+` ORDER BY e.intensity DESC`, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer emotionRows.Close()
+	for emotionRows.Next() {
+		var noteId uuid.UUID
+		var emotion EmotionIntensity
+		if err := emotionRows.Scan(&noteId, &emotion.Emotion, &emotion.Intensity); err != nil {
+			return nil, nil, err
+		}
+		if analysis, ok := analyses[noteId]; ok {
+			analysis.Emotions = append(analysis.Emotions, emotion)
+		}
+	}
+	return analyses, createdAt, emotionRows.Err()
+}
+
+// loadTopics returns the topics of every analysed note
+func loadTopics(ctx context.Context, executor db_client.Executor) (map[uuid.UUID][]string, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, topics FROM note_analyses")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := map[uuid.UUID][]string{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var encoded string
+		if err := rows.Scan(&noteId, &encoded); err != nil {
+			return nil, err
+		}
+		var noteTopics []string
+		if err := json.Unmarshal([]byte(encoded), &noteTopics); err != nil {
+			return nil, err
+		}
+		topics[noteId] = noteTopics
+	}
+	return topics, rows.Err()
+}
+
+func saveAnalysis(ctx context.Context, dbClient *db_client.DBClient, analysis NoteAnalysis) error {
+	topics, err := json.Marshal(analysis.Topics)
+	if err != nil {
+		return err
+	}
+	return dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		sqlStatement := `INSERT INTO note_analyses (note_id, content_hash, valence, topics, analysed_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (note_id) DO UPDATE SET content_hash = excluded.content_hash, valence = excluded.valence,
+			topics = excluded.topics, analysed_at = excluded.analysed_at`
+		_, err := tx.ExecContext(ctx, sqlStatement, analysis.NoteId, analysis.contentHash, analysis.Valence, string(topics), analysis.AnalysedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM note_emotions WHERE note_id = ?", analysis.NoteId); err != nil {
+			return err
+		}
+		for _, emotion := range analysis.Emotions {
+			_, err := tx.ExecContext(ctx, "INSERT INTO note_emotions (note_id, emotion, intensity) VALUES (?, ?, ?)", analysis.NoteId, emotion.Emotion, emotion.Intensity)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteAnalysis(ctx context.Context, executor db_client.Executor, noteId uuid.UUID) error {
+	_, err := executor.ExecContext(ctx, "DELETE FROM note_analyses WHERE note_id = ?", noteId)
+	return err
+}
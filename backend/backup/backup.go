@@ -0,0 +1,377 @@
+/*
+The backup package takes point-in-time backups of the journal database and
+restores them, both while the backend keeps serving requests.
+
+A backup is a zip archive holding manifest.json and a snapshot of the
+database, which is encrypted when a passphrase is given. The manifest records
+the schema version, the number of notes and a checksum of the snapshot so an
+archive can be validated before it replaces the journal.
+*/
+package backup
+
+import (
+	"archive/zip"
+	"backend/db"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of backup, recorded in the manifest and the file name
+const (
+	KindManual    = "manual"
+	KindAutomatic = "auto"
+	// Taken just before a restore replaces the journal
+	KindPreRestore = "pre-restore"
+)
+
+const (
+	formatVersion         = 1
+	filePrefix            = "athena-backup-"
+	manifestName          = "manifest.json"
+	databaseName          = "notes.db"
+	encryptedDatabaseName = "notes.db.enc"
+)
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	// Returned, wrapped with the reason, when an archive fails validation
+	ErrInvalidBackup      = errors.New("invalid backup")
+	ErrPassphraseRequired = errors.New("backup is encrypted, a passphrase is required")
+)
+
+type Manifest struct {
+	Format        int       `json:"Format"`
+	Kind          string    `json:"Kind"`
+	CreatedAt     time.Time `json:"CreatedAt"`
+	SchemaVersion int       `json:"SchemaVersion"`
+	NoteCount     int       `json:"NoteCount"`
+	// Size and sha256 of the database before encryption
+	Size      int64  `json:"Size"`
+	SHA256    string `json:"SHA256"`
+	Encrypted bool   `json:"Encrypted"`
+	// How the key was derived from the passphrase, when encrypted
+	KDF *KDFParams `json:"KDF,omitempty"`
+}
+
+// Info describes a backup stored in the backup folder
+type Info struct {
+	FileName string   `json:"FileName"`
+	Size     int64    `json:"Size"`
+	Manifest Manifest `json:"Manifest"`
+}
+
+type Manager struct {
+	dir      string
+	dbClient *db_client.DBClient
+
+	// Held while a backup or restore runs
+	mu sync.Mutex
+	// Tracks the scheduled backup goroutine
+	running sync.WaitGroup
+}
+
+// NewManager creates a manager keeping backups in dir, creating it if needed
+func NewManager(dir string, dbClient *db_client.DBClient) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup folder %s: %w", dir, err)
+	}
+	return &Manager{dir: dir, dbClient: dbClient}, nil
+}
+
+// Create backs up the database into the backup folder, encrypting it when
+// passphrase is not empty.
+func (manager *Manager) Create(ctx context.Context, kind string, passphrase string) (Info, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager.create(ctx, kind, passphrase)
+}
+
+func (manager *Manager) create(ctx context.Context, kind string, passphrase string) (Info, error) {
+	// Work in a hidden folder so a partly written backup is never listed
+	tmpDir, err := os.MkdirTemp(manager.dir, ".athena-backup-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshotPath := filepath.Join(tmpDir, databaseName)
+	if err := manager.dbClient.Snapshot(ctx, snapshotPath); err != nil {
+		return Info{}, err
+	}
+	dbInfo, err := db_client.Inspect(ctx, snapshotPath)
+	if err != nil {
+		return Info{}, fmt.Errorf("snapshot failed validation: %w", err)
+	}
+	data, err := os.ReadFile(snapshotPath)
+	if err != nil {
+		return Info{}, err
+	}
+
+	now := time.Now()
+	manifest := Manifest{
+		Format:        formatVersion,
+		Kind:          kind,
+		CreatedAt:     now,
+		SchemaVersion: dbInfo.SchemaVersion,
+		NoteCount:     dbInfo.NoteCount,
+		Size:          int64(len(data)),
+		SHA256:        checksum(data),
+	}
+	entryName := databaseName
+	if passphrase != "" {
+		manifest.Encrypted = true
+		if manifest.KDF, err = newKDFParams(); err != nil {
+			return Info{}, err
+		}
+		if data, err = encrypt(data, passphrase, manifest.KDF); err != nil {
+			return Info{}, err
+		}
+		entryName = encryptedDatabaseName
+	}
+
+	archivePath := filepath.Join(tmpDir, "archive.zip")
+	if err := writeArchive(archivePath, manifest, entryName, data); err != nil {
+		return Info{}, err
+	}
+	name := manager.newFileName(kind, now)
+	if err := os.Rename(archivePath, filepath.Join(manager.dir, name)); err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(filepath.Join(manager.dir, name))
+	if err != nil {
+		return Info{}, err
+	}
+	log.Printf("Backed up %d notes to %s", manifest.NoteCount, name)
+	return Info{FileName: name, Size: stat.Size(), Manifest: manifest}, nil
+}
+
+// newFileName names a backup by its kind and time, e.g.
+// athena-backup-auto-20261019-083000.zip
+func (manager *Manager) newFileName(kind string, now time.Time) string {
+	base := filePrefix + kind + "-" + now.Format("20060102-150405")
+	name := base + ".zip"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(manager.dir, name)); errors.Is(err, os.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d.zip", base, i)
+	}
+}
+
+func writeArchive(path string, manifest Manifest, entryName string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	manifestWriter, err := archive.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+	// Encrypted data does not compress
+	method := zip.Deflate
+	if manifest.Encrypted {
+		method = zip.Store
+	}
+	dataWriter, err := archive.CreateHeader(&zip.FileHeader{Name: entryName, Method: method, Modified: manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := dataWriter.Write(data); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// List returns the backups in the backup folder, newest first
+func (manager *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(manager.dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := []Info{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || filepath.Ext(name) != ".zip" {
+			continue
+		}
+		info, err := manager.stat(name)
+		if err != nil {
+			log.Printf("Ignoring unreadable backup %s: %v", name, err)
+			continue
+		}
+		backups = append(backups, info)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Manifest.CreatedAt.After(backups[j].Manifest.CreatedAt)
+	})
+	return backups, nil
+}
+
+func (manager *Manager) stat(name string) (Info, error) {
+	path := filepath.Join(manager.dir, name)
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer archive.Close()
+	manifest, err := readManifest(&archive.Reader)
+	if err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{FileName: name, Size: stat.Size(), Manifest: manifest}, nil
+}
+
+// Path returns where the named backup is stored
+func (manager *Manager) Path(name string) (string, error) {
+	if name != filepath.Base(name) || !strings.HasPrefix(name, filePrefix) {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+	path := filepath.Join(manager.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+	return path, nil
+}
+
+// RestoreFile restores the named backup from the backup folder
+func (manager *Manager) RestoreFile(ctx context.Context, name string, passphrase string) (Manifest, error) {
+	path, err := manager.Path(name)
+	if err != nil {
+		return Manifest{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return manager.Restore(ctx, data, passphrase)
+}
+
+// Restore validates a backup archive and replaces the journal with it. The
+// current journal is backed up first so a restore can be undone.
+func (manager *Manager) Restore(ctx context.Context, archive []byte, passphrase string) (Manifest, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manifest, data, err := readArchive(archive, passphrase)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	tmpDir, err := os.MkdirTemp(manager.dir, ".athena-restore-*")
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	restorePath := filepath.Join(tmpDir, databaseName)
+	if err := os.WriteFile(restorePath, data, 0600); err != nil {
+		return Manifest{}, err
+	}
+	dbInfo, err := db_client.Inspect(ctx, restorePath)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if dbInfo.SchemaVersion != manifest.SchemaVersion {
+		return Manifest{}, fmt.Errorf("%w: schema version %d does not match manifest version %d", ErrInvalidBackup, dbInfo.SchemaVersion, manifest.SchemaVersion)
+	}
+
+	if _, err := manager.create(ctx, KindPreRestore, ""); err != nil {
+		return Manifest{}, fmt.Errorf("failed to back up the journal before restoring: %w", err)
+	}
+	if err := manager.dbClient.Restore(ctx, restorePath); err != nil {
+		return Manifest{}, err
+	}
+	log.Printf("Restored %d notes from a backup taken %s", manifest.NoteCount, manifest.CreatedAt.Format(time.RFC3339))
+	return manifest, nil
+}
+
+// readArchive reads the manifest and the database from a backup archive,
+// decrypting it if needed and checking it against the manifest's checksum.
+func readArchive(data []byte, passphrase string) (Manifest, []byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Manifest{}, nil, fmt.Errorf("%w: not a zip archive", ErrInvalidBackup)
+	}
+	manifest, err := readManifest(archive)
+	if err != nil {
+		return Manifest{}, nil, err
+	}
+
+	entryName := databaseName
+	if manifest.Encrypted {
+		entryName = encryptedDatabaseName
+	}
+	file, err := archive.Open(entryName)
+	if err != nil {
+		return Manifest{}, nil, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, entryName)
+	}
+	defer file.Close()
+	database, err := io.ReadAll(file)
+	if err != nil {
+		return Manifest{}, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	if manifest.Encrypted {
+		if passphrase == "" {
+			return Manifest{}, nil, ErrPassphraseRequired
+		}
+		if manifest.KDF == nil {
+			return Manifest{}, nil, fmt.Errorf("%w: encryption parameters are missing", ErrInvalidBackup)
+		}
+		if database, err = decrypt(database, passphrase, manifest.KDF); err != nil {
+			return Manifest{}, nil, err
+		}
+	}
+	if int64(len(database)) != manifest.Size || checksum(database) != manifest.SHA256 {
+		return Manifest{}, nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidBackup)
+	}
+	return manifest, database, nil
+}
+
+func readManifest(archive *zip.Reader) (Manifest, error) {
+	file, err := archive.Open(manifestName)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%w: %s is missing", ErrInvalidBackup, manifestName)
+	}
+	defer file.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(file).Decode(&manifest); err != nil {
+		return Manifest{}, fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidBackup, err)
+	}
+	if manifest.Format != formatVersion {
+		return Manifest{}, fmt.Errorf("%w: unsupported format %d", ErrInvalidBackup, manifest.Format)
+	}
+	return manifest, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
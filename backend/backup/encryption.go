@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when an encrypted backup cannot be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted backup")
+
+// KDFParams records how the encryption key was derived from the passphrase
+type KDFParams struct {
+	Algorithm string `json:"Algorithm"`
+	Salt      []byte `json:"Salt"`
+	N         int    `json:"N"`
+	R         int    `json:"R"`
+	P         int    `json:"P"`
+}
+
+func newKDFParams() (*KDFParams, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &KDFParams{Algorithm: "scrypt", Salt: salt, N: 1 << 15, R: 8, P: 1}, nil
+}
+
+func (params *KDFParams) key(passphrase string) ([]byte, error) {
+	if params.Algorithm != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %q", params.Algorithm)
+	}
+	return scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, 32)
+}
+
+// encrypt seals data with AES-256-GCM, prefixing the nonce
+func encrypt(data []byte, passphrase string, params *KDFParams) ([]byte, error) {
+	aead, err := newAEAD(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func decrypt(data []byte, passphrase string, params *KDFParams) ([]byte, error) {
+	aead, err := newAEAD(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(passphrase string, params *KDFParams) (cipher.AEAD, error) {
+	key, err := params.key(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StartSchedule takes an automatic backup every interval in the background,
+// keeping the newest keep automatic backups, until ctx is cancelled. The first
+// backup is due interval after the last automatic one.
+func (manager *Manager) StartSchedule(ctx context.Context, interval time.Duration, keep int, passphrase string) {
+	manager.running.Add(1)
+	go func() {
+		defer manager.running.Done()
+		wait := interval
+		if last, ok := manager.lastAutomatic(); ok {
+			wait = max(time.Until(last.Add(interval)), 0)
+		}
+		for {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if _, err := manager.Create(ctx, KindAutomatic, passphrase); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Automatic backup failed: %v", err)
+			} else if err := manager.Rotate(keep); err != nil {
+				log.Printf("Failed to rotate backups: %v", err)
+			}
+			wait = interval
+		}
+	}()
+}
+
+// Rotate deletes all but the newest keep automatic backups. Manual and
+// pre-restore backups are never deleted.
+func (manager *Manager) Rotate(keep int) error {
+	backups, err := manager.List()
+	if err != nil {
+		return err
+	}
+	kept := 0
+	for _, backup := range backups {
+		if backup.Manifest.Kind != KindAutomatic {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(manager.dir, backup.FileName)); err != nil {
+			return err
+		}
+		log.Printf("Removed old backup %s", backup.FileName)
+	}
+	return nil
+}
+
+// Close waits for a scheduled backup that is running to finish
+func (manager *Manager) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		manager.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (manager *Manager) lastAutomatic() (time.Time, bool) {
+	backups, err := manager.List()
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, backup := range backups {
+		if backup.Manifest.Kind == KindAutomatic {
+			return backup.Manifest.CreatedAt, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"backend/backup"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type CreateBackupRequest struct {
+	// Encrypts the backup when set
+	Passphrase string `json:"Passphrase"`
+}
+
+type BackupFileRequest struct {
+	FileName string `json:"FileName"`
+	// Needed to restore an encrypted backup
+	Passphrase string `json:"Passphrase"`
+}
+
+// Header carrying the passphrase when a backup archive is uploaded for restore
+const backupPassphraseHeader = "X-Backup-Passphrase"
+
+func createBackupHandler(w http.ResponseWriter, r *http.Request, backups *backup.Manager) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CreateBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	info, err := backups.Create(r.Context(), backup.KindManual, req.Passphrase)
+	if err != nil {
+		log.Printf("Error creating backup: %v", err)
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func listBackupsHandler(w http.ResponseWriter, r *http.Request, backups *backup.Manager) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	infos, err := backups.List()
+	if err != nil {
+		log.Printf("Error listing backups: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func downloadBackupHandler(w http.ResponseWriter, r *http.Request, backups *backup.Manager) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BackupFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	path, err := backups.Path(req.FileName)
+	if errors.Is(err, backup.ErrBackupNotFound) {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read backup", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.FileName))
+	http.ServeFile(w, r, path)
+}
+
+// restoreBackupHandler restores either a backup from the backup folder, named
+// in a JSON body, or an uploaded backup archive.
+func restoreBackupHandler(w http.ResponseWriter, r *http.Request, backups *backup.Manager) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var manifest backup.Manifest
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req BackupFileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		manifest, err = backups.RestoreFile(r.Context(), req.FileName, req.Passphrase)
+	} else {
+		archive, readErr := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+		if readErr != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		manifest, err = backups.Restore(r.Context(), archive, r.Header.Get(backupPassphraseHeader))
+	}
+
+	switch {
+	case errors.Is(err, backup.ErrBackupNotFound):
+		http.Error(w, "Backup not found", http.StatusNotFound)
+	case errors.Is(err, backup.ErrInvalidBackup), errors.Is(err, backup.ErrPassphraseRequired), errors.Is(err, backup.ErrWrongPassphrase):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		log.Printf("Error restoring backup: %v", err)
+		http.Error(w, "Failed to restore backup", http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(manifest)
+	}
+}
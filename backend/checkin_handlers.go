@@ -0,0 +1,170 @@
+package main
+
+import (
+	"backend/notes_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SetCheckInRequest struct {
+	NoteId string `json:"NoteId"`
+	// Replaces the note's check-in. An empty check-in clears it.
+	CheckIn notes_service.CheckIn `json:"CheckIn"`
+}
+
+type DeleteCheckInFieldRequest struct {
+	Name string `json:"Name"`
+}
+
+type CheckInSeriesRequest struct {
+	// First and last day of the series, e.g. 2024-01-05, both included
+	From string `json:"From"`
+	To   string `json:"To"`
+	// Fields to include, all when empty
+	Fields []string `json:"Fields"`
+}
+
+func setCheckInHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService, checkInService *notes_service.CheckInService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetCheckInRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noteId, err := uuid.Parse(req.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+	if err := checkInService.Validate(r.Context(), req.CheckIn); err != nil {
+		writeCheckInError(w, err)
+		return
+	}
+
+	note, err := notesService.GetNote(r.Context(), noteId)
+	if errors.Is(err, notes_service.ErrNoteNotFound) {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting note: %v", err)
+		http.Error(w, "Failed to get note", http.StatusInternalServerError)
+		return
+	}
+	note.CheckIn = &req.CheckIn
+	if _, err := notesService.UpdateNote(r.Context(), note); err != nil {
+		log.Printf("Error saving check-in: %v", err)
+		http.Error(w, "Failed to save check-in", http.StatusInternalServerError)
+		return
+	}
+
+	note, err = notesService.GetNote(r.Context(), noteId)
+	if err != nil {
+		log.Printf("Error getting note: %v", err)
+		http.Error(w, "Failed to get note", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
+
+// checkInFieldsHandler lists the fields on GET and creates or updates a custom
+// field on POST
+func checkInFieldsHandler(w http.ResponseWriter, r *http.Request, checkInService *notes_service.CheckInService) {
+	switch r.Method {
+	case http.MethodGet:
+		fields, err := checkInService.Fields(r.Context())
+		if err != nil {
+			log.Printf("Error listing check-in fields: %v", err)
+			http.Error(w, "Failed to list check-in fields", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fields)
+	case http.MethodPost:
+		var field notes_service.CheckInField
+		if err := json.NewDecoder(r.Body).Decode(&field); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		field, err := checkInService.DefineField(r.Context(), field)
+		if err != nil {
+			writeCheckInError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(field)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func deleteCheckInFieldHandler(w http.ResponseWriter, r *http.Request, checkInService *notes_service.CheckInService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DeleteCheckInFieldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := checkInService.DeleteField(r.Context(), req.Name); err != nil {
+		writeCheckInError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func checkInSeriesHandler(w http.ResponseWriter, r *http.Request, checkInService *notes_service.CheckInService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CheckInSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	from, err := time.ParseInLocation(time.DateOnly, req.From, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid From date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, req.To, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid To date", http.StatusBadRequest)
+		return
+	}
+
+	days, err := checkInService.DailySeries(r.Context(), from, to, req.Fields)
+	if err != nil {
+		writeCheckInError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(days)
+}
+
+func writeCheckInError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notes_service.ErrCheckInFieldNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, notes_service.ErrInvalidCheckIn):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error handling check-in request: %v", err)
+		http.Error(w, "Failed to handle check-in request", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"backend/clarity_service"
+	"backend/notes_service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ClarityRequest struct {
+	// A named timeframe such as "2weeks", "last_month" or "2026-Q2", see
+	// notes_service.ResolveTimeframe. Ignored when From or To is set.
+	Timeframe string `json:"timeframe"`
+	// An explicit range, each bound either a day, included, or an RFC 3339
+	// timestamp. Either may be left open.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// IANA time zone the timeframe and days are in, the backend's by default
+	Timezone string `json:"timezone,omitempty"`
+	// "created_at", the default, or "updated_at"
+	DateField string `json:"dateField,omitempty"`
+	// Write a new report even if one was written from the same notes
+	Regenerate bool `json:"regenerate,omitempty"`
+}
+
+// ClarityRange is the range a clarity request resolved to and the report
+// answering it. It is sent as the first line of the stream.
+type ClarityRange struct {
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+	Timezone    string     `json:"timezone,omitempty"`
+	DateField   string     `json:"dateField"`
+	Description string     `json:"description"`
+	// Empty when there were no entries to report on
+	ReportId string `json:"reportId,omitempty"`
+	// Whether the report was stored earlier rather than written now
+	Cached bool `json:"cached"`
+}
+
+// ClarityFinal is sent as the last line of the stream once the report is
+// complete. Its content has citations of entries that do not exist dropped,
+// and every remaining citation, such as [E3], is linked to its note.
+type ClarityFinal struct {
+	ReportId  string                     `json:"reportId"`
+	Content   string                     `json:"content"`
+	Citations []clarity_service.Citation `json:"citations"`
+}
+
+type GetClarityReportRequest struct {
+	ReportId string `json:"ReportId"`
+}
+
+// resolveClarityRange works out the notes a clarity request covers
+func resolveClarityRange(req ClarityRequest, now time.Time) (clarity_service.Scope, error) {
+	field, err := notes_service.ParseDateField(req.DateField)
+	if err != nil {
+		return clarity_service.Scope{}, err
+	}
+	loc := time.Local
+	if req.Timezone != "" {
+		if loc, err = time.LoadLocation(req.Timezone); err != nil {
+			return clarity_service.Scope{}, fmt.Errorf("%w: unknown time zone %q", notes_service.ErrInvalidDateRange, req.Timezone)
+		}
+	}
+	scope := clarity_service.Scope{DateRange: notes_service.DateRange{Field: field}, Timezone: req.Timezone}
+
+	if req.From == "" && req.To == "" {
+		scope.From, scope.To, scope.Description, err = notes_service.ResolveTimeframe(req.Timeframe, now.In(loc))
+		return scope, err
+	}
+	descriptions := []string{}
+	if req.From != "" {
+		if scope.From, err = notes_service.ParseRangeBound(req.From, loc, false); err != nil {
+			return clarity_service.Scope{}, err
+		}
+		descriptions = append(descriptions, "from "+req.From)
+	}
+	if req.To != "" {
+		if scope.To, err = notes_service.ParseRangeBound(req.To, loc, true); err != nil {
+			return clarity_service.Scope{}, err
+		}
+		descriptions = append(descriptions, "to "+req.To)
+	}
+	if !scope.From.IsZero() && !scope.To.IsZero() && !scope.From.Before(scope.To) {
+		return clarity_service.Scope{}, fmt.Errorf("%w: from must be before to", notes_service.ErrInvalidDateRange)
+	}
+	scope.Description = strings.Join(descriptions, " ")
+	return scope, nil
+}
+
+func clarityStreamHandler(w http.ResponseWriter, r *http.Request, clarityService *clarity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ClarityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := resolveClarityRange(req, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Clarity request received for %s (%s from %v to %v)", scope.Description, scope.Field, scope.From, scope.To)
+
+	streamClarity(w, scope, func(onStart func(clarity_service.Report, bool), onLine func(string)) (clarity_service.Report, error) {
+		return clarityService.Stream(r.Context(), scope, req.Regenerate, onStart, onLine)
+	})
+}
+
+// regenerateClarityHandler writes a stored report again over the same range
+func regenerateClarityHandler(w http.ResponseWriter, r *http.Request, clarityService *clarity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetClarityReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	reportId, err := uuid.Parse(req.ReportId)
+	if err != nil {
+		http.Error(w, "Invalid report id", http.StatusBadRequest)
+		return
+	}
+	report, err := clarityService.Get(r.Context(), reportId)
+	if errors.Is(err, clarity_service.ErrReportNotFound) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting clarity report: %v", err)
+		http.Error(w, "Failed to get report", http.StatusInternalServerError)
+		return
+	}
+
+	scope := report.Scope()
+	streamClarity(w, scope, func(onStart func(clarity_service.Report, bool), onLine func(string)) (clarity_service.Report, error) {
+		return clarityService.Stream(r.Context(), scope, true, onStart, onLine)
+	})
+}
+
+// streamClarity writes the range line, the report generated by stream and
+// the final line to w
+func streamClarity(w http.ResponseWriter, scope clarity_service.Scope, stream func(onStart func(clarity_service.Report, bool), onLine func(string)) (clarity_service.Report, error)) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	writeLine := func(line string) {
+		w.Write([]byte(line + "\n\n"))
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	writeRange := func(reportId string, cached bool) {
+		resolved := ClarityRange{Timezone: scope.Timezone, DateField: string(scope.Field), Description: scope.Description, ReportId: reportId, Cached: cached}
+		if !scope.From.IsZero() {
+			resolved.From = &scope.From
+		}
+		if !scope.To.IsZero() {
+			resolved.To = &scope.To
+		}
+		line, _ := json.Marshal(map[string]ClarityRange{"range": resolved})
+		writeLine("data: " + string(line))
+	}
+
+	report, err := stream(func(report clarity_service.Report, cached bool) {
+		log.Printf("Clarity report %v for %s, cached: %t", report.ReportId, scope.Description, cached)
+		writeRange(report.ReportId.String(), cached)
+	}, writeLine)
+	if errors.Is(err, clarity_service.ErrNoEntries) {
+		log.Printf("No notes found for %s", scope.Description)
+		writeRange("", false)
+		// Send a message indicating no notes were found
+		message, _ := json.Marshal(map[string]string{"content": fmt.Sprintf("No journal entries found for %s. Try creating some notes first.", scope.Description)})
+		writeLine("data: " + string(message))
+		return
+	}
+	if err != nil {
+		log.Printf("Error streaming clarity: %v", err)
+		http.Error(w, "Failed to get clarity", http.StatusInternalServerError)
+		return
+	}
+	final, _ := json.Marshal(map[string]ClarityFinal{"final": {ReportId: report.ReportId.String(), Content: report.Content, Citations: report.Citations}})
+	writeLine("data: " + string(final))
+}
+
+// clarityHistoryHandler lists stored clarity reports, newest first
+func clarityHistoryHandler(w http.ResponseWriter, r *http.Request, clarityService *clarity_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reports, err := clarityService.History(r.Context())
+	if err != nil {
+		log.Printf("Error listing clarity reports: %v", err)
+		http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+func getClarityReportHandler(w http.ResponseWriter, r *http.Request, clarityService *clarity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetClarityReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	reportId, err := uuid.Parse(req.ReportId)
+	if err != nil {
+		http.Error(w, "Invalid report id", http.StatusBadRequest)
+		return
+	}
+
+	report, err := clarityService.Get(r.Context(), reportId)
+	if errors.Is(err, clarity_service.ErrReportNotFound) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting clarity report: %v", err)
+		http.Error(w, "Failed to get report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
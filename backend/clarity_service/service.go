@@ -0,0 +1,169 @@
+/*
+The clarity_service package writes clarity reports, summaries by the local
+model of the journal entries within a range, and keeps them.
+
+A report is served again instead of being written anew while the notes it
+would be written from, the model and the prompt version are all unchanged. A
+stored report is marked stale once the notes within its range change.
+*/
+package clarity_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReportNotFound = errors.New("clarity report not found")
+	ErrNoEntries      = errors.New("no journal entries in this range")
+	// The stream ended before the model finished, so nothing was stored
+	ErrIncompleteReport = errors.New("clarity report was not finished")
+)
+
+// ClarityGenerator is the part of the chat service reports need
+type ClarityGenerator interface {
+	GetClaritySummaryStream(ctx context.Context, entries []lm_service.ClarityEntry, callback func(chunk string)) error
+}
+
+// Scope is the range of notes a report covers
+type Scope struct {
+	notes_service.DateRange
+	// The time zone the range was resolved in, kept for display
+	Timezone string
+	// e.g. "last month"
+	Description string
+}
+
+type Service struct {
+	notesService notes_service.NotesService
+	generator    ClarityGenerator
+	model        string
+	dbClient     *db_client.DBClient
+}
+
+// NewService creates the service. model names the model reports are written
+// with, so reports written by another model are not served.
+func NewService(notesService notes_service.NotesService, generator ClarityGenerator, model string, dbClient *db_client.DBClient) *Service {
+	return &Service{notesService: notesService, generator: generator, model: model, dbClient: dbClient}
+}
+
+// Stream writes the report on the notes in scope to onLine, as lines of the
+// completion stream, and returns it. Unless regenerate is set, the latest
+// report written from the same notes with the same model and prompt is
+// served; otherwise a new report is generated and stored once the model
+// finishes. onStart is called with the report, without its content, before
+// the first line.
+//
+// The streamed text is the model's own. The returned report's content has
+// citations of entries that do not exist dropped.
+func (service *Service) Stream(ctx context.Context, scope Scope, regenerate bool, onStart func(report Report, cached bool), onLine func(line string)) (Report, error) {
+	notes, err := service.notesService.GetNotesInRange(ctx, scope.DateRange)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(notes) == 0 {
+		return Report{}, fmt.Errorf("%w: %s", ErrNoEntries, scope.Description)
+	}
+	hash := inputHash(notes)
+
+	if !regenerate {
+		report, err := findReport(ctx, service.dbClient, hash, service.model, lm_service.ClarityPromptVersion)
+		if err == nil {
+			onStart(report, true)
+			line, err := json.Marshal(map[string]string{"content": report.Content})
+			if err != nil {
+				return Report{}, err
+			}
+			onLine("data: " + string(line))
+			return report, nil
+		}
+		if !errors.Is(err, ErrReportNotFound) {
+			return Report{}, err
+		}
+	}
+
+	report := newReport(scope, service.model, hash, notes)
+	onStart(report, false)
+	entries := []lm_service.ClarityEntry{}
+	for _, note := range notes {
+		entries = append(entries, lm_service.ClarityEntry{CreatedAt: note.CreatedAt.Local(), Title: note.Title, Content: note.Content})
+	}
+	var content strings.Builder
+	finished := false
+	err = service.generator.GetClaritySummaryStream(ctx, entries, func(chunk string) {
+		onLine(chunk)
+		data, ok := strings.CutPrefix(chunk, "data: ")
+		var completion struct {
+			Content string `json:"content"`
+			Stop    bool   `json:"stop"`
+		}
+		if ok && json.Unmarshal([]byte(data), &completion) == nil {
+			content.WriteString(completion.Content)
+			finished = finished || completion.Stop
+		}
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	if !finished {
+		return Report{}, ErrIncompleteReport
+	}
+	report.Content, report.Citations = resolveCitations(strings.TrimSpace(content.String()), notes)
+	report.CreatedAt = time.Now()
+	err = service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		return saveReport(ctx, tx, report)
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// Get returns a stored report with the notes it was written from
+func (service *Service) Get(ctx context.Context, reportId uuid.UUID) (Report, error) {
+	report, err := loadReport(ctx, service.dbClient, reportId)
+	if err != nil {
+		return Report{}, err
+	}
+	if report.SourceNoteIds, err = loadSourceNoteIds(ctx, service.dbClient, reportId); err != nil {
+		return Report{}, err
+	}
+	if report.Citations, err = loadCitations(ctx, service.dbClient, reportId); err != nil {
+		return Report{}, err
+	}
+	report.Stale, err = service.stale(ctx, report.Scope(), report.InputHash)
+	return report, err
+}
+
+// History lists the stored reports, newest first
+func (service *Service) History(ctx context.Context) ([]ReportSummary, error) {
+	summaries, err := loadReportSummaries(ctx, service.dbClient)
+	if err != nil {
+		return nil, err
+	}
+	for i, summary := range summaries {
+		if summaries[i].Stale, err = service.stale(ctx, summary.scope(), summary.inputHash); err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
+
+// stale reports whether the notes in scope differ from those a report with
+// hash was written from
+func (service *Service) stale(ctx context.Context, scope Scope, hash string) (bool, error) {
+	notes, err := service.notesService.GetNotesInRange(ctx, scope.DateRange)
+	if err != nil {
+		return false, err
+	}
+	return inputHash(notes) != hash, nil
+}
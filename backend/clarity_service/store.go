@@ -0,0 +1,255 @@
+package clarity_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Report struct {
+	ReportId    uuid.UUID `json:"ReportId"`
+	Description string    `json:"Description"`
+	// Bounds of the range, omitted when open. To is excluded.
+	From          *time.Time  `json:"From,omitempty"`
+	To            *time.Time  `json:"To,omitempty"`
+	DateField     string      `json:"DateField"`
+	Timezone      string      `json:"Timezone,omitempty"`
+	Model         string      `json:"Model"`
+	PromptVersion int         `json:"PromptVersion"`
+	InputHash     string      `json:"InputHash"`
+	Content       string      `json:"Content"`
+	SourceNoteIds []uuid.UUID `json:"SourceNoteIds"`
+	// The entries the content cites, in order of first citation
+	Citations []Citation `json:"Citations"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+	// Set when the notes within the range have changed since
+	Stale bool `json:"Stale"`
+}
+
+// Citation links a label cited in a report's content, such as [E3], to the
+// note it stands for
+type Citation struct {
+	Ref       string    `json:"Ref"`
+	NoteId    uuid.UUID `json:"NoteId"`
+	Title     string    `json:"Title"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// ReportSummary describes a report without its content, for the history
+type ReportSummary struct {
+	ReportId      uuid.UUID  `json:"ReportId"`
+	Description   string     `json:"Description"`
+	From          *time.Time `json:"From,omitempty"`
+	To            *time.Time `json:"To,omitempty"`
+	DateField     string     `json:"DateField"`
+	Timezone      string     `json:"Timezone,omitempty"`
+	Model         string     `json:"Model"`
+	PromptVersion int        `json:"PromptVersion"`
+	NoteCount     int        `json:"NoteCount"`
+	CreatedAt     time.Time  `json:"CreatedAt"`
+	Stale         bool       `json:"Stale"`
+
+	inputHash string
+}
+
+// inputHash identifies what the model is shown for a set of notes, in order
+func inputHash(notes []notes_service.Note) string {
+	hash := sha256.New()
+	for _, note := range notes {
+		fmt.Fprintf(hash, "%s\n%s\n%d\n%s\n%d\n%s\n", note.NoteId, note.CreatedAt.Format(time.RFC3339Nano),
+			len(note.Title), note.Title, len(note.Content), note.Content)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// resolveCitations drops citations of entries that do not exist from content
+// written from notes, and links the rest to their notes
+func resolveCitations(content string, notes []notes_service.Note) (string, []Citation) {
+	content, cited := lm_service.ResolveCitations(content, len(notes))
+	citations := []Citation{}
+	for _, index := range cited {
+		note := notes[index]
+		citations = append(citations, Citation{Ref: lm_service.ClarityRef(index), NoteId: note.NoteId, Title: note.Title, CreatedAt: note.CreatedAt})
+	}
+	return content, citations
+}
+
+func newReport(scope Scope, model string, hash string, notes []notes_service.Note) Report {
+	report := Report{
+		ReportId:      uuid.New(),
+		Description:   scope.Description,
+		DateField:     string(scope.Field),
+		Timezone:      scope.Timezone,
+		Model:         model,
+		PromptVersion: lm_service.ClarityPromptVersion,
+		InputHash:     hash,
+		SourceNoteIds: []uuid.UUID{},
+		Citations:     []Citation{},
+	}
+	if !scope.From.IsZero() {
+		report.From = &scope.From
+	}
+	if !scope.To.IsZero() {
+		report.To = &scope.To
+	}
+	for _, note := range notes {
+		report.SourceNoteIds = append(report.SourceNoteIds, note.NoteId)
+	}
+	return report
+}
+
+func newScope(from *time.Time, to *time.Time, field string, timezone string, description string) Scope {
+	scope := Scope{DateRange: notes_service.DateRange{Field: notes_service.DateField(field)}, Timezone: timezone, Description: description}
+	if from != nil {
+		scope.From = *from
+	}
+	if to != nil {
+		scope.To = *to
+	}
+	return scope
+}
+
+// Scope is the range the report covers, to write it again
+func (report Report) Scope() Scope {
+	return newScope(report.From, report.To, report.DateField, report.Timezone, report.Description)
+}
+
+func (summary ReportSummary) scope() Scope {
+	return newScope(summary.From, summary.To, summary.DateField, summary.Timezone, summary.Description)
+}
+
+const selectReportColumns = `SELECT id, description, range_from, range_to, date_field, timezone, model, prompt_version, input_hash, content, created_at
+	FROM clarity_reports`
+
+func scanReport(row interface{ Scan(...any) error }) (Report, error) {
+	var report Report
+	var from, to sql.NullTime
+	err := row.Scan(&report.ReportId, &report.Description, &from, &to, &report.DateField, &report.Timezone,
+		&report.Model, &report.PromptVersion, &report.InputHash, &report.Content, &report.CreatedAt)
+	if from.Valid {
+		report.From = &from.Time
+	}
+	if to.Valid {
+		report.To = &to.Time
+	}
+	report.SourceNoteIds = []uuid.UUID{}
+	report.Citations = []Citation{}
+	return report, err
+}
+
+// findReport returns the latest report written from the notes with hash by
+// model with the prompt version
+func findReport(ctx context.Context, executor db_client.Executor, hash string, model string, promptVersion int) (Report, error) {
+	row := executor.QueryRowContext(ctx, selectReportColumns+" WHERE input_hash = ? AND model = ? AND prompt_version = ? ORDER BY created_at DESC LIMIT 1", hash, model, promptVersion)
+	report, err := scanReport(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Report{}, ErrReportNotFound
+	}
+	if err != nil {
+		return Report{}, err
+	}
+	report.Citations, err = loadCitations(ctx, executor, report.ReportId)
+	return report, err
+}
+
+func loadReport(ctx context.Context, executor db_client.Executor, reportId uuid.UUID) (Report, error) {
+	report, err := scanReport(executor.QueryRowContext(ctx, selectReportColumns+" WHERE id = ?", reportId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Report{}, fmt.Errorf("%w: %v", ErrReportNotFound, reportId)
+	}
+	return report, err
+}
+
+func loadSourceNoteIds(ctx context.Context, executor db_client.Executor, reportId uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id FROM clarity_report_notes WHERE report_id = ?", reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	noteIds := []uuid.UUID{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		if err := rows.Scan(&noteId); err != nil {
+			return nil, err
+		}
+		noteIds = append(noteIds, noteId)
+	}
+	return noteIds, rows.Err()
+}
+
+func loadCitations(ctx context.Context, executor db_client.Executor, reportId uuid.UUID) ([]Citation, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT ref, note_id, title, created_at FROM clarity_citations WHERE report_id = ? ORDER BY rowid", reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	citations := []Citation{}
+	for rows.Next() {
+		var citation Citation
+		if err := rows.Scan(&citation.Ref, &citation.NoteId, &citation.Title, &citation.CreatedAt); err != nil {
+			return nil, err
+		}
+		citations = append(citations, citation)
+	}
+	return citations, rows.Err()
+}
+
+func loadReportSummaries(ctx context.Context, executor db_client.Executor) ([]ReportSummary, error) {
+	rows, err := executor.QueryContext(ctx, `SELECT r.id, r.description, r.range_from, r.range_to, r.date_field, r.timezone, r.model, r.prompt_version,
+			r.input_hash, r.created_at, COUNT(n.note_id)
+		FROM clarity_reports r LEFT JOIN clarity_report_notes n ON n.report_id = r.id
+		GROUP BY r.id ORDER BY r.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries := []ReportSummary{}
+	for rows.Next() {
+		var summary ReportSummary
+		var from, to sql.NullTime
+		err := rows.Scan(&summary.ReportId, &summary.Description, &from, &to, &summary.DateField, &summary.Timezone,
+			&summary.Model, &summary.PromptVersion, &summary.inputHash, &summary.CreatedAt, &summary.NoteCount)
+		if err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			summary.From = &from.Time
+		}
+		if to.Valid {
+			summary.To = &to.Time
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+func saveReport(ctx context.Context, executor db_client.Executor, report Report) error {
+	sqlStatement := `INSERT INTO clarity_reports (id, description, range_from, range_to, date_field, timezone, model, prompt_version, input_hash, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := executor.ExecContext(ctx, sqlStatement, report.ReportId, report.Description, report.From, report.To, report.DateField, report.Timezone,
+		report.Model, report.PromptVersion, report.InputHash, report.Content, report.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, noteId := range report.SourceNoteIds {
+		if _, err := executor.ExecContext(ctx, "INSERT INTO clarity_report_notes (report_id, note_id) VALUES (?, ?)", report.ReportId, noteId); err != nil {
+			return err
+		}
+	}
+	for _, citation := range report.Citations {
+		sqlStatement := "INSERT INTO clarity_citations (report_id, ref, note_id, title, created_at) VALUES (?, ?, ?, ?, ?)"
+		if _, err := executor.ExecContext(ctx, sqlStatement, report.ReportId, citation.Ref, citation.NoteId, citation.Title, citation.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
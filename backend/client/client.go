@@ -0,0 +1,341 @@
+/*
+The client package is a typed Go client for the Athena backend HTTP API as
+described by openapi.json. It can talk to the backend over TCP or over the
+Unix domain socket transport.
+
+The types and the methods of the plain JSON operations are generated from
+openapi.json into types.go and operations.go. Streaming, upload and download
+operations are written here.
+*/
+package client
+
+//go:generate go run ./internal/gen -spec ../openapi.json -out .
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// APIError is returned when the backend answers with a non 2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("athena api error %d: %s", err.StatusCode, err.Message)
+}
+
+// NewClient creates a client for a backend listening on baseURL, e.g.
+// "http://127.0.0.1:8080", authenticating with the given bearer token.
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// NewUnixSocketClient creates a client for a backend serving on a Unix domain
+// socket.
+func NewUnixSocketClient(socketPath string, token string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{
+		// The host is ignored by the dialer but must be present in the URL
+		baseURL:    "http://athena",
+		token:      token,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+func (client *Client) Hello(ctx context.Context) (string, error) {
+	resp, err := client.send(ctx, http.MethodGet, "/hello", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// Chat streams a reflection on a journal entry, calling onLine for every line
+// of the response.
+func (client *Client) Chat(ctx context.Context, req ChatRequest, onLine func(line string)) error {
+	return client.Stream(ctx, http.MethodPost, "/chat", req, onLine)
+}
+
+// Clarity streams a summary of the entries written within a timeframe or date
+// range, calling onLine for every line of the response. The first line
+// carries the resolved ClarityRange and the id of the report, the last the
+// ClarityFinal.
+func (client *Client) Clarity(ctx context.Context, req ClarityRequest, onLine func(line string)) error {
+	return client.Stream(ctx, http.MethodPost, "/clarity", req, onLine)
+}
+
+// RegenerateClarity writes a stored clarity report again over the same
+// range, streaming it like Clarity
+func (client *Client) RegenerateClarity(ctx context.Context, reportId string, onLine func(line string)) error {
+	return client.Stream(ctx, http.MethodPost, "/clarity/regenerate", GetClarityReportRequest{ReportId: reportId}, onLine)
+}
+
+// ExportMarkdown returns a zip of the matching notes as Markdown files
+func (client *Client) ExportMarkdown(ctx context.Context, filter NoteFilter) ([]byte, error) {
+	resp, err := client.send(ctx, http.MethodPost, "/export/markdown", ExportMarkdownRequest{NoteFilter: filter})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// ExportMarkdownToDir has the backend write the matching notes into dir,
+// relative to the export folder it was started with.
+func (client *Client) ExportMarkdownToDir(ctx context.Context, filter NoteFilter, dir string) (ExportMarkdownResponse, error) {
+	var result ExportMarkdownResponse
+	err := client.Do(ctx, http.MethodPost, "/export/markdown", ExportMarkdownRequest{NoteFilter: filter, Directory: dir}, &result)
+	return result, err
+}
+
+// ExportHTML returns a zip of the matching notes rendered as a static website
+func (client *Client) ExportHTML(ctx context.Context, req ExportHTMLRequest) ([]byte, error) {
+	resp, err := client.send(ctx, http.MethodPost, "/export/html", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// ImportMarkdown uploads a zip of Markdown files, or a single Markdown file
+// named fileName, and upserts the notes by id.
+func (client *Client) ImportMarkdown(ctx context.Context, fileName string, data []byte) (ImportResult, error) {
+	var result ImportResult
+	contentType := "text/markdown"
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		contentType = "application/zip"
+	}
+	resp, err := client.sendBody(ctx, http.MethodPost, "/import/markdown?filename="+url.QueryEscape(fileName), bytes.NewReader(data), contentType)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+// ImportDayOne uploads a Day One JSON export, either the zip or a single
+// journal JSON file.
+func (client *Client) ImportDayOne(ctx context.Context, data []byte) (ImportResult, error) {
+	var result ImportResult
+	contentType := "application/json"
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		contentType = "application/zip"
+	}
+	resp, err := client.sendBody(ctx, http.MethodPost, "/import/dayone", bytes.NewReader(data), contentType)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+func (client *Client) DownloadBackup(ctx context.Context, fileName string) ([]byte, error) {
+	resp, err := client.send(ctx, http.MethodPost, "/backup/download", BackupFileRequest{FileName: fileName})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// RestoreBackup replaces the journal with a backup from the backend's backup
+// folder.
+func (client *Client) RestoreBackup(ctx context.Context, fileName string, passphrase string) (BackupManifest, error) {
+	var manifest BackupManifest
+	err := client.Do(ctx, http.MethodPost, "/backup/restore", BackupFileRequest{FileName: fileName, Passphrase: passphrase}, &manifest)
+	return manifest, err
+}
+
+// RestoreBackupArchive replaces the journal with an uploaded backup archive
+func (client *Client) RestoreBackupArchive(ctx context.Context, archive []byte, passphrase string) (BackupManifest, error) {
+	var manifest BackupManifest
+	req, err := client.newRequest(ctx, http.MethodPost, "/backup/restore", bytes.NewReader(archive), "application/zip")
+	if err != nil {
+		return manifest, err
+	}
+	if passphrase != "" {
+		req.Header.Set("X-Backup-Passphrase", passphrase)
+	}
+	resp, err := client.do(req)
+	if err != nil {
+		return manifest, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	return manifest, err
+}
+
+// PreviewImport reports what importing a file would do without changing
+// anything. format is detected from the file when empty.
+func (client *Client) PreviewImport(ctx context.Context, fileName string, data []byte, format string) (ImportPreview, error) {
+	var preview ImportPreview
+	resp, err := client.sendImport(ctx, fileName, data, format, true)
+	if err != nil {
+		return preview, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&preview)
+	return preview, err
+}
+
+// Import imports a file in any supported format, calling onProgress (when not
+// nil) as notes are imported. format is detected from the file when empty.
+func (client *Client) Import(ctx context.Context, fileName string, data []byte, format string, onProgress func(done int, total int)) (ImportResult, error) {
+	resp, err := client.sendImport(ctx, fileName, data, format, false)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress ImportProgress
+		if err := decoder.Decode(&progress); err != nil {
+			return ImportResult{}, err
+		}
+		switch {
+		case progress.Error != "":
+			return ImportResult{}, errors.New(progress.Error)
+		case progress.Result != nil:
+			return *progress.Result, nil
+		case onProgress != nil:
+			onProgress(progress.Done, progress.Total)
+		}
+	}
+}
+
+func (client *Client) sendImport(ctx context.Context, fileName string, data []byte, format string, dryRun bool) (*http.Response, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if format != "" {
+		form.WriteField("format", format)
+	}
+	form.WriteField("dryRun", strconv.FormatBool(dryRun))
+	file, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	file.Write(data)
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	return client.sendBody(ctx, http.MethodPost, "/import", &body, form.FormDataContentType())
+}
+
+// Do sends in as a JSON body (when not nil) and decodes the JSON response into
+// out (when not nil). It can be used for endpoints without a typed helper.
+func (client *Client) Do(ctx context.Context, method string, path string, in any, out any) error {
+	resp, err := client.send(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Stream sends in as a JSON body and calls onLine for every non-empty line of
+// the streamed response.
+func (client *Client) Stream(ctx context.Context, method string, path string, in any, onLine func(line string)) error {
+	resp, err := client.send(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			onLine(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// withQuery appends the encoded query to path, when there is one
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+func (client *Client) send(ctx context.Context, method string, path string, in any) (*http.Response, error) {
+	if in == nil {
+		return client.sendBody(ctx, method, path, nil, "")
+	}
+	jsonData, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return client.sendBody(ctx, method, path, bytes.NewReader(jsonData), "application/json")
+}
+
+func (client *Client) sendBody(ctx context.Context, method string, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := client.newRequest(ctx, method, path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return client.do(req)
+}
+
+func (client *Client) newRequest(ctx context.Context, method string, path string, body io.Reader, contentType string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, client.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+client.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+func (client *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
@@ -0,0 +1,428 @@
+/*
+Command gen writes the request and response types of the client package, and
+the methods for its plain JSON operations, from openapi.json. Operations that
+stream, upload or download files are written by hand in client.go.
+
+It is run by go generate in the client package:
+
+	go generate ./client
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const header = "// Code generated by client/internal/gen from openapi.json. DO NOT EDIT.\n\n"
+
+// ordered is a JSON object that keeps the order of its keys, so the output
+// follows the order of the document
+type ordered[T any] struct {
+	keys   []string
+	values map[string]T
+}
+
+func (object *ordered[T]) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	object.values = map[string]T{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value T
+		if err := decoder.Decode(&value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		object.keys = append(object.keys, key)
+		object.values[key] = value
+	}
+	return nil
+}
+
+type schema struct {
+	Ref                  string            `json:"$ref"`
+	Type                 string            `json:"type"`
+	Format               string            `json:"format"`
+	Description          string            `json:"description"`
+	Properties           ordered[*schema]  `json:"properties"`
+	Required             []string          `json:"required"`
+	Items                *schema           `json:"items"`
+	AdditionalProperties *schema           `json:"additionalProperties"`
+	AllOf                []*schema         `json:"allOf"`
+	Enum                 []json.RawMessage `json:"enum"`
+	Nullable             bool              `json:"nullable"`
+	ReadOnly             bool              `json:"readOnly"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationId string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content ordered[mediaType] `json:"content"`
+	} `json:"requestBody"`
+	Responses ordered[struct {
+		Ref     string             `json:"$ref"`
+		Content ordered[mediaType] `json:"content"`
+	}] `json:"responses"`
+}
+
+type document struct {
+	Paths      ordered[ordered[operation]] `json:"paths"`
+	Components struct {
+		Schemas ordered[*schema] `json:"schemas"`
+	} `json:"components"`
+}
+
+func main() {
+	specPath := flag.String("spec", "../openapi.json", "OpenAPI document to read")
+	outDir := flag.String("out", ".", "directory of the client package")
+	flag.Parse()
+
+	spec, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := generate(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(*outDir, name), source, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// generate returns the source of the generated files by name
+func generate(spec []byte) (map[string][]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, err
+	}
+	g := generator{schemas: doc.Components.Schemas.values}
+
+	types := g.types(doc)
+	operations, err := g.operations(doc)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for name, source := range map[string]string{"types.go": types, "operations.go": operations} {
+		formatted, err := format.Source([]byte(source))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = formatted
+	}
+	return files, nil
+}
+
+type generator struct {
+	schemas map[string]*schema
+}
+
+func (g generator) types(doc document) string {
+	var out strings.Builder
+	for _, name := range doc.Components.Schemas.keys {
+		s := doc.Components.Schemas.values[name]
+		out.WriteString("\n")
+		writeComment(&out, "", s.Description)
+		fmt.Fprintf(&out, "type %s struct {\n", name)
+		members := []*schema{s}
+		if len(s.AllOf) > 0 {
+			members = s.AllOf
+		}
+		for _, member := range members {
+			if member.Ref != "" {
+				fmt.Fprintf(&out, "\t%s\n", refName(member.Ref))
+				continue
+			}
+			g.fields(&out, member)
+		}
+		out.WriteString("}\n")
+	}
+	return header + "package client\n\n" + imports(out.String(), "time") + out.String()
+}
+
+func (g generator) fields(out *strings.Builder, s *schema) {
+	for _, name := range s.Properties.keys {
+		property := s.Properties.values[name]
+		required := slices.Contains(s.Required, name) || property.ReadOnly
+		comment := property.Description
+		if comment == "" && len(property.Enum) > 0 {
+			comment = enumComment(property.Enum)
+		}
+		writeComment(out, "\t", comment)
+		tag := name
+		if !required {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(out, "\t%s %s `json:%q`\n", goName(name), g.fieldType(property, required), tag)
+	}
+}
+
+// fieldType is the Go type of a property. Values that may be null, and
+// optional values whose zero value means something, are pointers.
+func (g generator) fieldType(s *schema, required bool) string {
+	base := g.goType(s)
+	if strings.HasPrefix(base, "[]") || strings.HasPrefix(base, "map[") {
+		return base
+	}
+	if s.Nullable || !required && g.pointable(s) {
+		return "*" + base
+	}
+	return base
+}
+
+func (g generator) pointable(s *schema) bool {
+	switch {
+	case s.Ref != "":
+		return g.pointable(g.schemas[refName(s.Ref)])
+	case len(s.AllOf) == 1:
+		return g.pointable(s.AllOf[0])
+	case s.Type == "object", len(s.AllOf) > 1:
+		return true
+	case s.Type == "string":
+		return s.Format == "date-time"
+	}
+	return s.Type == "integer" || s.Type == "number"
+}
+
+func (g generator) goType(s *schema) string {
+	switch {
+	case s.Ref != "":
+		return refName(s.Ref)
+	case len(s.AllOf) == 1:
+		return g.goType(s.AllOf[0])
+	}
+	switch s.Type {
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			return "map[string]" + g.goType(s.AdditionalProperties)
+		}
+		return "map[string]any"
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	}
+	return "any"
+}
+
+func (g generator) operations(doc document) (string, error) {
+	var out strings.Builder
+	for _, path := range doc.Paths.keys {
+		methods := doc.Paths.values[path]
+		for _, method := range methods.keys {
+			op := methods.values[method]
+			source, ok, err := g.operation(path, method, op)
+			if err != nil {
+				return "", fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if ok {
+				out.WriteString("\n" + source)
+			}
+		}
+	}
+	return header + "package client\n\n" + imports(out.String(), "context", "net/http", "net/url") + out.String(), nil
+}
+
+// operation writes the method for an operation sending and receiving JSON,
+// reporting false for the operations written by hand
+func (g generator) operation(path string, method string, op operation) (string, bool, error) {
+	var requestType string
+	if op.RequestBody != nil {
+		media, ok := jsonOnly(op.RequestBody.Content)
+		if !ok {
+			return "", false, nil
+		}
+		requestType = g.goType(media.Schema)
+	}
+	var responseType string
+	if response, ok := op.Responses.values["200"]; ok {
+		if response.Ref != "" {
+			// A shared response, such as the completion stream
+			return "", false, nil
+		}
+		if len(response.Content.keys) > 0 {
+			media, ok := jsonOnly(response.Content)
+			if !ok {
+				return "", false, nil
+			}
+			responseType = g.goType(media.Schema)
+		}
+	}
+	for _, param := range op.Parameters {
+		if param.In != "query" {
+			return "", false, nil
+		}
+		if param.Schema == nil || param.Schema.Type != "string" {
+			return "", false, fmt.Errorf("query parameter %s must be a string", param.Name)
+		}
+	}
+
+	name := goName(op.OperationId)
+	var out strings.Builder
+	args := []string{"ctx context.Context"}
+	if len(op.Parameters) > 0 {
+		fmt.Fprintf(&out, "// %sParams are the query parameters of %s, left out when empty\n", name, name)
+		fmt.Fprintf(&out, "type %sParams struct {\n", name)
+		for _, param := range op.Parameters {
+			comment := param.Description
+			if len(param.Schema.Enum) > 0 {
+				comment = strings.TrimSpace(comment + ". " + enumComment(param.Schema.Enum))
+				comment = strings.TrimPrefix(comment, ". ")
+			}
+			writeComment(&out, "\t", comment)
+			fmt.Fprintf(&out, "\t%s string\n", goName(param.Name))
+		}
+		out.WriteString("}\n\n")
+		args = append(args, "params "+name+"Params")
+	}
+	if requestType != "" {
+		args = append(args, "req "+requestType)
+	}
+	results := "error"
+	if responseType != "" {
+		results = "(" + responseType + ", error)"
+	}
+
+	writeComment(&out, "", fmt.Sprintf("%s sends %s %s: %s", name, strings.ToUpper(method), path, lowerFirst(op.Summary)))
+	fmt.Fprintf(&out, "func (client *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), results)
+	target := fmt.Sprintf("%q", path)
+	if len(op.Parameters) > 0 {
+		out.WriteString("\tquery := url.Values{}\n")
+		for _, param := range op.Parameters {
+			field := "params." + goName(param.Name)
+			fmt.Fprintf(&out, "\tif %s != \"\" {\n\t\tquery.Set(%q, %s)\n\t}\n", field, param.Name, field)
+		}
+		target = fmt.Sprintf("withQuery(%q, query)", path)
+	}
+	body := "nil"
+	if requestType != "" {
+		body = "req"
+	}
+	httpMethod := "http.Method" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+	if responseType == "" {
+		fmt.Fprintf(&out, "\treturn client.Do(ctx, %s, %s, %s, nil)\n}\n", httpMethod, target, body)
+	} else {
+		fmt.Fprintf(&out, "\tvar out %s\n", responseType)
+		fmt.Fprintf(&out, "\terr := client.Do(ctx, %s, %s, %s, &out)\n\treturn out, err\n}\n", httpMethod, target, body)
+	}
+	return out.String(), true, nil
+}
+
+// jsonOnly returns the JSON media type of content when it is the only one
+func jsonOnly(content ordered[mediaType]) (mediaType, bool) {
+	if len(content.keys) != 1 || content.keys[0] != "application/json" {
+		return mediaType{}, false
+	}
+	return content.values["application/json"], true
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// goName turns a JSON name such as "n_predict" or "reportId" into an
+// exported Go name such as NPredict or ReportId
+func goName(name string) string {
+	var out strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		out.WriteString(string(runes))
+	}
+	return out.String()
+}
+
+func lowerFirst(text string) string {
+	runes := []rune(text)
+	if len(runes) > 1 && unicode.IsUpper(runes[0]) && !unicode.IsUpper(runes[1]) {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+	return string(runes)
+}
+
+// enumComment lists the allowed values, e.g. "a", "b" or "c"
+func enumComment(values []json.RawMessage) string {
+	quoted := []string{}
+	for _, value := range values {
+		quoted = append(quoted, string(value))
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
+// writeComment writes text as a comment wrapped at 80 columns
+func writeComment(out *strings.Builder, indent string, text string) {
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(indent)*4+len(line)+len(word)+4 > 80 {
+			out.WriteString(indent + "// " + line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		out.WriteString(indent + "// " + line + "\n")
+	}
+}
+
+// imports returns the import block for body, with the packages it uses
+func imports(body string, packages ...string) string {
+	used := []string{}
+	for _, pkg := range packages {
+		if strings.Contains(body, pkg[strings.LastIndex(pkg, "/")+1:]+".") {
+			used = append(used, fmt.Sprintf("\t%q\n", pkg))
+		}
+	}
+	if len(used) == 0 {
+		return ""
+	}
+	return "import (\n" + strings.Join(used, "") + ")\n"
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGeneratedFilesAreCurrent fails when openapi.json changed without
+// running go generate in the client package
+func TestGeneratedFilesAreCurrent(t *testing.T) {
+	spec, err := os.ReadFile("../../../openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := generate(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range files {
+		current, err := os.ReadFile(filepath.Join("../..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(current, source) {
+			t.Errorf("client/%s is out of date with openapi.json, run go generate ./client", name)
+		}
+	}
+}
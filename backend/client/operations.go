@@ -0,0 +1,379 @@
+// Code generated by client/internal/gen from openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GetOpenAPI sends GET /openapi.json: this document
+func (client *Client) GetOpenAPI(ctx context.Context) (map[string]any, error) {
+	var out map[string]any
+	err := client.Do(ctx, http.MethodGet, "/openapi.json", nil, &out)
+	return out, err
+}
+
+// CreateNote sends GET /createnote: create an empty untitled note
+func (client *Client) CreateNote(ctx context.Context) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodGet, "/createnote", nil, &out)
+	return out, err
+}
+
+// CreateNoteFromTemplate sends POST /createnote/template: create a note from a
+// template
+func (client *Client) CreateNoteFromTemplate(ctx context.Context, req TemplateRequest) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodPost, "/createnote/template", req, &out)
+	return out, err
+}
+
+// ListTemplates sends GET /templates: list note templates by name
+func (client *Client) ListTemplates(ctx context.Context) ([]Template, error) {
+	var out []Template
+	err := client.Do(ctx, http.MethodGet, "/templates", nil, &out)
+	return out, err
+}
+
+// SaveTemplate sends POST /templates: create or update a note template
+func (client *Client) SaveTemplate(ctx context.Context, req Template) (Template, error) {
+	var out Template
+	err := client.Do(ctx, http.MethodPost, "/templates", req, &out)
+	return out, err
+}
+
+// DeleteTemplate sends POST /templates/delete: delete a note template
+func (client *Client) DeleteTemplate(ctx context.Context, req TemplateRequest) error {
+	return client.Do(ctx, http.MethodPost, "/templates/delete", req, nil)
+}
+
+// GetAllNotes sends GET /getallnotes: list every note
+func (client *Client) GetAllNotes(ctx context.Context) ([]Note, error) {
+	var out []Note
+	err := client.Do(ctx, http.MethodGet, "/getallnotes", nil, &out)
+	return out, err
+}
+
+// GetNote sends POST /getnote: fetch a single note
+func (client *Client) GetNote(ctx context.Context, req GetNoteRequest) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodPost, "/getnote", req, &out)
+	return out, err
+}
+
+// UpdateNote sends POST /updatenote: update the title and content of a note
+func (client *Client) UpdateNote(ctx context.Context, req UpdateNoteRequest) (UpdateNoteResponse, error) {
+	var out UpdateNoteResponse
+	err := client.Do(ctx, http.MethodPost, "/updatenote", req, &out)
+	return out, err
+}
+
+// DeleteNote sends POST /deletenote: delete a note
+func (client *Client) DeleteNote(ctx context.Context, req GetNoteRequest) error {
+	return client.Do(ctx, http.MethodPost, "/deletenote", req, nil)
+}
+
+// ClarityHistory sends GET /clarity/reports: list stored clarity reports,
+// newest first
+func (client *Client) ClarityHistory(ctx context.Context) ([]ClarityReportSummary, error) {
+	var out []ClarityReportSummary
+	err := client.Do(ctx, http.MethodGet, "/clarity/reports", nil, &out)
+	return out, err
+}
+
+// GetClarityReport sends POST /clarity/report: get a stored clarity report
+func (client *Client) GetClarityReport(ctx context.Context, req GetClarityReportRequest) (ClarityReport, error) {
+	var out ClarityReport
+	err := client.Do(ctx, http.MethodPost, "/clarity/report", req, &out)
+	return out, err
+}
+
+// SetCheckIn sends POST /checkin: set the check-in of a note
+func (client *Client) SetCheckIn(ctx context.Context, req SetCheckInRequest) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodPost, "/checkin", req, &out)
+	return out, err
+}
+
+// ListCheckInFields sends GET /checkin/fields: list check-in fields
+func (client *Client) ListCheckInFields(ctx context.Context) ([]CheckInField, error) {
+	var out []CheckInField
+	err := client.Do(ctx, http.MethodGet, "/checkin/fields", nil, &out)
+	return out, err
+}
+
+// DefineCheckInField sends POST /checkin/fields: create or update a custom
+// check-in field
+func (client *Client) DefineCheckInField(ctx context.Context, req CheckInField) (CheckInField, error) {
+	var out CheckInField
+	err := client.Do(ctx, http.MethodPost, "/checkin/fields", req, &out)
+	return out, err
+}
+
+// DeleteCheckInField sends POST /checkin/fields/delete: delete a custom
+// check-in field and its values
+func (client *Client) DeleteCheckInField(ctx context.Context, req DeleteCheckInFieldRequest) error {
+	return client.Do(ctx, http.MethodPost, "/checkin/fields/delete", req, nil)
+}
+
+// CheckInSeries sends POST /checkin/series: daily check-in aggregates for
+// charting
+func (client *Client) CheckInSeries(ctx context.Context, req CheckInSeriesRequest) ([]CheckInDay, error) {
+	var out []CheckInDay
+	err := client.Do(ctx, http.MethodPost, "/checkin/series", req, &out)
+	return out, err
+}
+
+// WritingStats sends GET /stats: writing statistics and streaks
+func (client *Client) WritingStats(ctx context.Context) (WritingStats, error) {
+	var out WritingStats
+	err := client.Do(ctx, http.MethodGet, "/stats", nil, &out)
+	return out, err
+}
+
+// Activity sends POST /stats/activity: entries and words per day or week, for
+// charts and the calendar heatmap
+func (client *Client) Activity(ctx context.Context, req ActivityRequest) ([]ActivityBucket, error) {
+	var out []ActivityBucket
+	err := client.Do(ctx, http.MethodPost, "/stats/activity", req, &out)
+	return out, err
+}
+
+// GetNoteAnalysis sends POST /analysis/note: get the emotion analysis of a note
+func (client *Client) GetNoteAnalysis(ctx context.Context, req GetNoteRequest) (NoteAnalysis, error) {
+	var out NoteAnalysis
+	err := client.Do(ctx, http.MethodPost, "/analysis/note", req, &out)
+	return out, err
+}
+
+// EmotionSeries sends POST /analysis/emotions: emotions, valence and topics
+// over time
+func (client *Client) EmotionSeries(ctx context.Context, req EmotionSeriesRequest) ([]EmotionBucket, error) {
+	var out []EmotionBucket
+	err := client.Do(ctx, http.MethodPost, "/analysis/emotions", req, &out)
+	return out, err
+}
+
+// AnalysisStatus sends GET /analysis/status: progress of the background
+// analysis
+func (client *Client) AnalysisStatus(ctx context.Context) (AnalysisStatus, error) {
+	var out AnalysisStatus
+	err := client.Do(ctx, http.MethodGet, "/analysis/status", nil, &out)
+	return out, err
+}
+
+// NoteLinks sends POST /links/note: wiki links in a note, in the order they
+// appear
+func (client *Client) NoteLinks(ctx context.Context, req GetNoteRequest) ([]Link, error) {
+	var out []Link
+	err := client.Do(ctx, http.MethodPost, "/links/note", req, &out)
+	return out, err
+}
+
+// Backlinks sends POST /links/backlinks: links to a note from other notes,
+// newest notes first
+func (client *Client) Backlinks(ctx context.Context, req GetNoteRequest) ([]Backlink, error) {
+	var out []Backlink
+	err := client.Do(ctx, http.MethodPost, "/links/backlinks", req, &out)
+	return out, err
+}
+
+// UnresolvedLinks sends POST /links/unresolved: links in a note no note was
+// found for
+func (client *Client) UnresolvedLinks(ctx context.Context, req GetNoteRequest) ([]Link, error) {
+	var out []Link
+	err := client.Do(ctx, http.MethodPost, "/links/unresolved", req, &out)
+	return out, err
+}
+
+// ListTasksParams are the query parameters of ListTasks, left out when empty
+type ListTasksParams struct {
+	// Only tasks with this status. "proposed", "accepted", "dismissed" or
+	// "completed"
+	Status string
+}
+
+// ListTasks sends GET /tasks: list tasks found in notes, newest first
+func (client *Client) ListTasks(ctx context.Context, params ListTasksParams) ([]Task, error) {
+	query := url.Values{}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	var out []Task
+	err := client.Do(ctx, http.MethodGet, withQuery("/tasks", query), nil, &out)
+	return out, err
+}
+
+// NoteTasks sends POST /tasks/note: tasks found in a note, in the order they
+// appear
+func (client *Client) NoteTasks(ctx context.Context, req GetNoteRequest) ([]Task, error) {
+	var out []Task
+	err := client.Do(ctx, http.MethodPost, "/tasks/note", req, &out)
+	return out, err
+}
+
+// SetTaskStatus sends POST /tasks/status: accept, dismiss, complete or reopen a
+// task
+func (client *Client) SetTaskStatus(ctx context.Context, req SetTaskStatusRequest) (Task, error) {
+	var out Task
+	err := client.Do(ctx, http.MethodPost, "/tasks/status", req, &out)
+	return out, err
+}
+
+// ListEntitiesParams are the query parameters of ListEntities, left out when empty
+type ListEntitiesParams struct {
+	// Only people or only places. "person" or "place"
+	Kind string
+	// Only entities with this status. "proposed", "confirmed" or "rejected"
+	Status string
+}
+
+// ListEntities sends GET /entities: list the people and places mentioned in
+// notes, the most mentioned first
+func (client *Client) ListEntities(ctx context.Context, params ListEntitiesParams) ([]Entity, error) {
+	query := url.Values{}
+	if params.Kind != "" {
+		query.Set("kind", params.Kind)
+	}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	var out []Entity
+	err := client.Do(ctx, http.MethodGet, withQuery("/entities", query), nil, &out)
+	return out, err
+}
+
+// SetEntityStatus sends POST /entities/status: confirm or reject a person or
+// place
+func (client *Client) SetEntityStatus(ctx context.Context, req SetEntityStatusRequest) (Entity, error) {
+	var out Entity
+	err := client.Do(ctx, http.MethodPost, "/entities/status", req, &out)
+	return out, err
+}
+
+// UpdateEntity sends POST /entities/update: rename a person or place and set
+// the other names it goes by
+func (client *Client) UpdateEntity(ctx context.Context, req UpdateEntityRequest) (Entity, error) {
+	var out Entity
+	err := client.Do(ctx, http.MethodPost, "/entities/update", req, &out)
+	return out, err
+}
+
+// MergeEntities sends POST /entities/merge: merge two entities found under
+// different names
+func (client *Client) MergeEntities(ctx context.Context, req MergeEntitiesRequest) (Entity, error) {
+	var out Entity
+	err := client.Do(ctx, http.MethodPost, "/entities/merge", req, &out)
+	return out, err
+}
+
+// EntityMentions sends POST /entities/mentions: notes mentioning a person or
+// place, newest first
+func (client *Client) EntityMentions(ctx context.Context, req EntityRequest) ([]Mention, error) {
+	var out []Mention
+	err := client.Do(ctx, http.MethodPost, "/entities/mentions", req, &out)
+	return out, err
+}
+
+// EntitySentiment sends POST /entities/sentiment: how the writer felt about a
+// person or place over time
+func (client *Client) EntitySentiment(ctx context.Context, req SentimentSeriesRequest) ([]SentimentBucket, error) {
+	var out []SentimentBucket
+	err := client.Do(ctx, http.MethodPost, "/entities/sentiment", req, &out)
+	return out, err
+}
+
+// RevertTitle sends POST /title/revert: revert a generated title
+func (client *Client) RevertTitle(ctx context.Context, req GetNoteRequest) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodPost, "/title/revert", req, &out)
+	return out, err
+}
+
+// ListDigestsParams are the query parameters of ListDigests, left out when empty
+type ListDigestsParams struct {
+	// Only list digests of this kind. "weekly" or "monthly"
+	Kind string
+}
+
+// ListDigests sends GET /digests: list weekly and monthly digests, newest first
+func (client *Client) ListDigests(ctx context.Context, params ListDigestsParams) ([]DigestSummary, error) {
+	query := url.Values{}
+	if params.Kind != "" {
+		query.Set("kind", params.Kind)
+	}
+	var out []DigestSummary
+	err := client.Do(ctx, http.MethodGet, withQuery("/digests", query), nil, &out)
+	return out, err
+}
+
+// GetDigest sends POST /digest: get a digest
+func (client *Client) GetDigest(ctx context.Context, req GetDigestRequest) (Digest, error) {
+	var out Digest
+	err := client.Do(ctx, http.MethodPost, "/digest", req, &out)
+	return out, err
+}
+
+// GenerateDigest sends POST /digests/generate: write the digest of a week or
+// month now
+func (client *Client) GenerateDigest(ctx context.Context, req GenerateDigestRequest) (Digest, error) {
+	var out Digest
+	err := client.Do(ctx, http.MethodPost, "/digests/generate", req, &out)
+	return out, err
+}
+
+// OnThisDay sends POST /onthisday: entries written on the same calendar day in
+// earlier years
+func (client *Client) OnThisDay(ctx context.Context, req OnThisDayRequest) ([]OnThisDayEntry, error) {
+	var out []OnThisDayEntry
+	err := client.Do(ctx, http.MethodPost, "/onthisday", req, &out)
+	return out, err
+}
+
+// Resurface sends POST /resurface: older entries related to what was written on
+// a day
+func (client *Client) Resurface(ctx context.Context, req ResurfaceRequest) ([]ResurfacedEntry, error) {
+	var out []ResurfacedEntry
+	err := client.Do(ctx, http.MethodPost, "/resurface", req, &out)
+	return out, err
+}
+
+// DailyPrompts sends GET /prompts/daily: today's journaling prompts
+func (client *Client) DailyPrompts(ctx context.Context) (DailyPrompts, error) {
+	var out DailyPrompts
+	err := client.Do(ctx, http.MethodGet, "/prompts/daily", nil, &out)
+	return out, err
+}
+
+// StartPrompt sends POST /prompts/start: create an untitled note from a
+// journaling prompt
+func (client *Client) StartPrompt(ctx context.Context, req StartPromptRequest) (Note, error) {
+	var out Note
+	err := client.Do(ctx, http.MethodPost, "/prompts/start", req, &out)
+	return out, err
+}
+
+// SyncVault sends POST /vault/sync: sync the notes with the configured vault
+// folder now
+func (client *Client) SyncVault(ctx context.Context) (VaultSyncResult, error) {
+	var out VaultSyncResult
+	err := client.Do(ctx, http.MethodPost, "/vault/sync", nil, &out)
+	return out, err
+}
+
+// CreateBackup sends POST /backup: back up the journal into the backup folder
+func (client *Client) CreateBackup(ctx context.Context, req CreateBackupRequest) (BackupInfo, error) {
+	var out BackupInfo
+	err := client.Do(ctx, http.MethodPost, "/backup", req, &out)
+	return out, err
+}
+
+// ListBackups sends GET /backups: list the backups in the backup folder, newest
+// first
+func (client *Client) ListBackups(ctx context.Context) ([]BackupInfo, error) {
+	var out []BackupInfo
+	err := client.Do(ctx, http.MethodGet, "/backups", nil, &out)
+	return out, err
+}
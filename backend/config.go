@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Origins the Tauri webview can load the frontend from. tauri://localhost is
+// used on macOS and Linux, http://tauri.localhost on Windows and
+// http://localhost:1420 is the vite dev server.
+var defaultAllowedOrigins = []string{
+	"tauri://localhost",
+	"http://tauri.localhost",
+	"https://tauri.localhost",
+	"http://localhost:1420",
+}
+
+type Config struct {
+	// Address the HTTP API listens on. Defaults to loopback so the journal is
+	// never exposed to other devices on the network.
+	Addr string
+	// Path of a Unix domain socket to serve the API on instead of TCP. Left
+	// empty to use TCP.
+	SocketPath string
+	// Bearer token required on every request. Handed to us by the Tauri shell
+	// through ATHENA_API_TOKEN, or generated for this launch if not provided.
+	APIToken       string
+	TokenGenerated bool
+	AllowedOrigins []string
+	// How long in-flight requests and shutdown steps get before we give up
+	ShutdownTimeout time.Duration
+	// Folder, e.g. inside an Obsidian vault, to keep in sync with the notes as
+	// Markdown files. Left empty to disable the sync.
+	VaultDir string
+	// Folder Markdown exports may be written into, relative to it. Left empty
+	// to only allow exports as a zip.
+	ExportDir string
+	// Folder backups are written to. Defaults to a backups folder beside the
+	// database.
+	BackupDir string
+	// How often an automatic backup is taken, 0 to disable them, and how many
+	// automatic backups are kept
+	BackupInterval time.Duration
+	BackupKeep     int
+	// Encrypts automatic backups when set. Only read from ATHENA_BACKUP_PASSPHRASE
+	// so it never shows up in the process list.
+	BackupPassphrase string
+}
+
+// LoadConfig reads the backend configuration from command line flags, falling
+// back to environment variables and then to safe defaults.
+func LoadConfig(args []string) (Config, error) {
+	flags := flag.NewFlagSet("athena-backend", flag.ContinueOnError)
+	addr := flags.String("addr", envOrDefault("ATHENA_ADDR", "127.0.0.1:8080"), "address for the HTTP API to listen on")
+	socketPath := flags.String("socket", os.Getenv("ATHENA_SOCKET"), "serve the HTTP API on this Unix domain socket instead of TCP")
+	origins := flags.String("allowed-origins", os.Getenv("ATHENA_ALLOWED_ORIGINS"), "comma separated list of origins allowed to call the API")
+	vaultDir := flags.String("vault", os.Getenv("ATHENA_VAULT_DIR"), "keep the notes in sync with Markdown files in this folder")
+	exportDir := flags.String("export-dir", os.Getenv("ATHENA_EXPORT_DIR"), "folder Markdown exports may be written into")
+	backupDir := flags.String("backup-dir", os.Getenv("ATHENA_BACKUP_DIR"), "folder to write backups to")
+	backupInterval := flags.Duration("backup-interval", 24*time.Hour, "how often to take an automatic backup, 0 to disable")
+	backupKeep := flags.Int("backup-keep", 7, "number of automatic backups to keep")
+	shutdownTimeout := flags.Duration("shutdown-timeout", 10*time.Second, "time allowed for a graceful shutdown")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	config := Config{
+		Addr:             *addr,
+		SocketPath:       *socketPath,
+		APIToken:         os.Getenv("ATHENA_API_TOKEN"),
+		AllowedOrigins:   defaultAllowedOrigins,
+		ShutdownTimeout:  *shutdownTimeout,
+		BackupInterval:   *backupInterval,
+		BackupKeep:       *backupKeep,
+		BackupPassphrase: os.Getenv("ATHENA_BACKUP_PASSPHRASE"),
+	}
+
+	if *origins != "" {
+		config.AllowedOrigins = nil
+		for _, origin := range strings.Split(*origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				config.AllowedOrigins = append(config.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if config.SocketPath != "" {
+		absPath, err := filepath.Abs(*socketPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid socket path %s: %w", *socketPath, err)
+		}
+		config.SocketPath = absPath
+	}
+
+	if *vaultDir != "" {
+		absPath, err := filepath.Abs(*vaultDir)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid vault folder %s: %w", *vaultDir, err)
+		}
+		config.VaultDir = absPath
+	}
+
+	if *exportDir != "" {
+		absPath, err := filepath.Abs(*exportDir)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid export folder %s: %w", *exportDir, err)
+		}
+		config.ExportDir = absPath
+	}
+
+	if *backupDir != "" {
+		absPath, err := filepath.Abs(*backupDir)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid backup folder %s: %w", *backupDir, err)
+		}
+		config.BackupDir = absPath
+	}
+	if config.BackupKeep < 1 {
+		return Config{}, fmt.Errorf("backup-keep must be at least 1")
+	}
+
+	if config.APIToken == "" {
+		token, err := generateAPIToken()
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to generate api token: %w", err)
+		}
+		config.APIToken = token
+		config.TokenGenerated = true
+	}
+
+	return config, nil
+}
+
+func generateAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,104 @@
+package db_client
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DatabaseInfo describes a database file checked by Inspect
+type DatabaseInfo struct {
+	SchemaVersion int
+	NoteCount     int
+}
+
+// Snapshot writes a consistent copy of the database to path while it stays in
+// use. path must not exist yet.
+func (client *DBClient) Snapshot(ctx context.Context, path string) error {
+	if _, err := client.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// Inspect opens the database file at path read only, checks its integrity and
+// that this build can use its schema.
+func Inspect(ctx context.Context, path string) (DatabaseInfo, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return DatabaseInfo{}, err
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return DatabaseInfo{}, fmt.Errorf("not a valid database: %w", err)
+	}
+	if integrity != "ok" {
+		return DatabaseInfo{}, fmt.Errorf("database is corrupt: %s", integrity)
+	}
+
+	var info DatabaseInfo
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&info.SchemaVersion); err != nil {
+		return DatabaseInfo{}, err
+	}
+	if info.SchemaVersion > SchemaVersion {
+		return DatabaseInfo{}, fmt.Errorf("database schema version %d is newer than supported version %d", info.SchemaVersion, SchemaVersion)
+	}
+	if info.SchemaVersion < 1 {
+		return DatabaseInfo{}, fmt.Errorf("database has no journal schema")
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notes").Scan(&info.NoteCount); err != nil {
+		return DatabaseInfo{}, fmt.Errorf("database has no notes table: %w", err)
+	}
+	return info, nil
+}
+
+// Restore replaces the contents of the database with the database file at
+// path using SQLite's online backup API, then brings the restored schema up
+// to date. The client stays open throughout; statements run through it wait
+// for the restore to finish, and other connections see either the old or the
+// restored data. Check the file with Inspect first.
+func (client *DBClient) Restore(ctx context.Context, path string) error {
+	client.restoreMu.Lock()
+	defer client.restoreMu.Unlock()
+
+	source, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	sourceConn, err := source.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer sourceConn.Close()
+	destConn, err := client.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriverConn any) error {
+		return sourceConn.Raw(func(sourceDriverConn any) error {
+			backup, err := destDriverConn.(*sqlite3.SQLiteConn).Backup("main", sourceDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	// Statements were prepared against the old schema
+	client.closeStatements()
+	return client.migrate(ctx)
+}
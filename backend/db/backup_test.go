@@ -0,0 +1,55 @@
+package db_client
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRestoreWhileQuerying(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	client, err := NewDBClient(ctx, filepath.Join(dir, "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(ctx)
+	snapshot := filepath.Join(dir, "snapshot.db")
+	if err := client.Snapshot(ctx, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	errs := make(chan error, 8)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var count int
+				if err := client.QueryRowContext(ctx, "SELECT COUNT(*) FROM notes").Scan(&count); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for range 200 {
+		if err := client.Restore(ctx, snapshot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("query during restore: %v", err)
+	}
+}
@@ -1,7 +1,200 @@
+/*
+The db_client package owns the SQLite connection. It configures the database
+(WAL journal, busy timeout, foreign keys), applies schema migrations, caches
+prepared statements and provides a transaction helper. Services build their
+queries on top of the Executor interface so the same code can run inside or
+outside a transaction.
+*/
 package db_client
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Executor is implemented by both DBClient and Tx
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
 
 type DBClient struct {
 	db *sql.DB
+
+	// Held for reading while a statement is prepared and run, and for writing
+	// while Restore replaces the database and drops the statements prepared
+	// against it
+	restoreMu sync.RWMutex
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// Tx wraps a transaction started by WithTx. Statements run through it reuse
+// the client's prepared statements.
+type Tx struct {
+	tx     *sql.Tx
+	client *DBClient
+}
+
+// NewDBClient opens the SQLite database at path and brings its schema up to
+// date. The returned client owns the connection and must be closed.
+func NewDBClient(ctx context.Context, path string) (*DBClient, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_foreign_keys", "on")
+	// Take the write lock when a transaction begins rather than on its first
+	// write, so concurrent transactions wait instead of failing with SQLITE_BUSY
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database %s: %w", path, err)
+	}
+
+	client := &DBClient{
+		db:    db,
+		stmts: map[string]*sql.Stmt{},
+	}
+	if err := client.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// DB exposes the underlying connection pool for operations the client does
+// not wrap.
+func (client *DBClient) DB() *sql.DB {
+	return client.db
+}
+
+// prepared returns a cached prepared statement for query, preparing it on
+// first use.
+func (client *DBClient) prepared(ctx context.Context, query string) (*sql.Stmt, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if stmt, ok := client.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := client.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	client.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (client *DBClient) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	client.restoreMu.RLock()
+	defer client.restoreMu.RUnlock()
+	stmt, err := client.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// QueryContext returns rows that stay readable after a Restore, as a closed
+// statement is only finalised once its rows are closed.
+func (client *DBClient) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	client.restoreMu.RLock()
+	defer client.restoreMu.RUnlock()
+	stmt, err := client.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, args...)
+}
+
+// QueryRowContext falls back to an unprepared query if preparing fails, so
+// the error surfaces from Scan as it does with database/sql.
+func (client *DBClient) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	client.restoreMu.RLock()
+	defer client.restoreMu.RUnlock()
+	stmt, err := client.prepared(ctx, query)
+	if err != nil {
+		return client.db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// WithTx runs fn inside a transaction, committing if it returns nil and
+// rolling back otherwise. Only the statements of the transaction hold off a
+// Restore, not the whole of fn, so fn may use the client too.
+func (client *DBClient) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := client.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(&Tx{tx: sqlTx, client: client}); err != nil {
+		if rollbackErr := sqlTx.Rollback(); rollbackErr != nil {
+			log.Printf("Failed to roll back transaction: %v", rollbackErr)
+		}
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	tx.client.restoreMu.RLock()
+	defer tx.client.restoreMu.RUnlock()
+	stmt, err := tx.client.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	tx.client.restoreMu.RLock()
+	defer tx.client.restoreMu.RUnlock()
+	stmt, err := tx.client.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	tx.client.restoreMu.RLock()
+	defer tx.client.restoreMu.RUnlock()
+	stmt, err := tx.client.prepared(ctx, query)
+	if err != nil {
+		return tx.tx.QueryRowContext(ctx, query, args...)
+	}
+	return tx.tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+}
+
+// Close checkpoints the write-ahead log into the main database file and closes
+// the connection.
+func (client *DBClient) Close(ctx context.Context) error {
+	client.closeStatements()
+
+	if _, err := client.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		log.Printf("Failed to checkpoint database: %v", err)
+	}
+	return client.db.Close()
+}
+
+// closeStatements drops every cached prepared statement
+func (client *DBClient) closeStatements() {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for query, stmt := range client.stmts {
+		stmt.Close()
+		delete(client.stmts, query)
+	}
 }
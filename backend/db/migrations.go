@@ -0,0 +1,296 @@
+package db_client
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Schema migrations, applied in order. The number of migrations applied is
+// stored in PRAGMA user_version, so entries must only ever be appended.
+var migrations = []string{
+	// 1: notes
+	`CREATE TABLE IF NOT EXISTS notes (id TEXT PRIMARY KEY, title TEXT, content TEXT, created_at DATETIME, updated_at DATETIME)`,
+	// 2: note tags
+	`CREATE TABLE note_tags (
+		note_id TEXT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (note_id, tag)
+	);
+	CREATE INDEX note_tags_tag ON note_tags (tag);`,
+	// 3: vault sync state. Not tied to notes by a foreign key so a row outlives
+	// its note and the sync can remove the mirrored file.
+	`CREATE TABLE vault_sync_state (
+		vault TEXT NOT NULL,
+		note_id TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		file_hash TEXT NOT NULL,
+		note_updated_at DATETIME NOT NULL,
+		PRIMARY KEY (vault, note_id)
+	);`,
+	// 4: check-ins. Built-in fields (mood, energy, sleep hours) are defined in
+	// code, only custom fields are listed in checkin_fields.
+	`CREATE TABLE checkin_fields (
+		name TEXT PRIMARY KEY,
+		label TEXT NOT NULL,
+		type TEXT NOT NULL CHECK (type IN ('number', 'boolean')),
+		min REAL,
+		max REAL
+	);
+	CREATE TABLE note_checkins (
+		note_id TEXT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		field TEXT NOT NULL,
+		value REAL NOT NULL,
+		PRIMARY KEY (note_id, field)
+	);
+	CREATE INDEX note_checkins_field ON note_checkins (field);`,
+	// 5: model analysis of entries. content_hash identifies the version of the
+	// note that was analysed.
+	`CREATE TABLE note_analyses (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		content_hash TEXT NOT NULL,
+		valence REAL NOT NULL,
+		topics TEXT NOT NULL,
+		analysed_at DATETIME NOT NULL
+	);
+	CREATE TABLE note_emotions (
+		note_id TEXT NOT NULL REFERENCES note_analyses(note_id) ON DELETE CASCADE,
+		emotion TEXT NOT NULL,
+		intensity REAL NOT NULL,
+		PRIMARY KEY (note_id, emotion)
+	);`,
+	// 6: titles generated by the model. A reverted title is kept so the note
+	// is not given another.
+	`CREATE TABLE generated_titles (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		generated_at DATETIME NOT NULL,
+		reverted BOOLEAN NOT NULL DEFAULT 0
+	);`,
+	// 7: digests. Periods are local calendar dates, the last day included.
+	// Source notes are not tied by a foreign key so a digest keeps listing
+	// notes deleted since.
+	`CREATE TABLE digests (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		period_start TEXT NOT NULL,
+		period_end TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		UNIQUE (kind, period_start)
+	);
+	CREATE TABLE digest_notes (
+		digest_id TEXT NOT NULL REFERENCES digests(id) ON DELETE CASCADE,
+		note_id TEXT NOT NULL,
+		PRIMARY KEY (digest_id, note_id)
+	);`,
+	// 8: clarity_reports. A report is served again while the hash of the
+	// notes it was written from, the model and the prompt version match. An
+	// open end of the range is NULL.
+	`CREATE TABLE clarity_reports (
+		id TEXT PRIMARY KEY,
+		description TEXT NOT NULL,
+		range_from DATETIME,
+		range_to DATETIME,
+		date_field TEXT NOT NULL,
+		timezone TEXT NOT NULL,
+		model TEXT NOT NULL,
+		prompt_version INTEGER NOT NULL,
+		input_hash TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX clarity_reports_input ON clarity_reports (input_hash, model, prompt_version);
+	CREATE TABLE clarity_report_notes (
+		report_id TEXT NOT NULL REFERENCES clarity_reports(id) ON DELETE CASCADE,
+		note_id TEXT NOT NULL,
+		PRIMARY KEY (report_id, note_id)
+	);`,
+	// 9: clarity_citations. The entries a report cites, by the label they
+	// had in the prompt. Title and date are kept for notes deleted since.
+	`CREATE TABLE clarity_citations (
+		report_id TEXT NOT NULL REFERENCES clarity_reports(id) ON DELETE CASCADE,
+		ref TEXT NOT NULL,
+		note_id TEXT NOT NULL,
+		title TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (report_id, ref)
+	);`,
+	// 10: note_stats, kept up to date as notes are written so writing
+	// statistics need not read every note. day, hour and weekday (0 for
+	// Sunday) are local to the backend when the note was last written.
+	`CREATE TABLE note_stats (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		day TEXT NOT NULL,
+		hour INTEGER NOT NULL,
+		weekday INTEGER NOT NULL,
+		words INTEGER NOT NULL,
+		characters INTEGER NOT NULL
+	);
+	CREATE INDEX note_stats_day ON note_stats (day);`,
+	// 11: daily_prompts, one set of journaling prompts per local day, from
+	// the model or, while it is unavailable, the built-in library. note_prompts
+	// keeps the text of the prompt a note was started from, as sets from the
+	// library are replaced once the model can write one.
+	`CREATE TABLE daily_prompts (
+		prompt_id TEXT PRIMARY KEY,
+		day TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		source TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX daily_prompts_day ON daily_prompts (day);
+	CREATE TABLE note_prompts (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		prompt_id TEXT NOT NULL,
+		prompt TEXT NOT NULL
+	);`,
+	// 12: note_templates, seeded with a few common formats that can be
+	// changed or deleted like any other template
+	`CREATE TABLE note_templates (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	INSERT INTO note_templates (id, name, title, content, created_at, updated_at) VALUES
+		('6f1d3c52-8a0e-4b7a-9c51-2f4e8d7a1b01', 'Morning pages', 'Morning pages, {{long_date}}',
+			'Write three pages by hand or keep typing until nothing is left. Do not stop to edit.' || char(10) || char(10),
+			datetime('now'), datetime('now')),
+		('6f1d3c52-8a0e-4b7a-9c51-2f4e8d7a1b02', 'Gratitude list', 'Grateful on {{weekday}}',
+			'Three things I am grateful for today:' || char(10) || '1. ' || char(10) || '2. ' || char(10) || '3. ' || char(10) || char(10) || 'One person I appreciate, and why:' || char(10),
+			datetime('now'), datetime('now')),
+		('6f1d3c52-8a0e-4b7a-9c51-2f4e8d7a1b03', 'Weekly review', 'Week {{week}} review',
+			'What went well this week?' || char(10) || char(10) || 'What did not go to plan, and what did I learn?' || char(10) || char(10) || 'What am I carrying into next week?' || char(10) || char(10) || 'Top three priorities for next week:' || char(10) || '1. ' || char(10) || '2. ' || char(10) || '3. ' || char(10),
+			datetime('now'), datetime('now')),
+		('6f1d3c52-8a0e-4b7a-9c51-2f4e8d7a1b04', '1:1 prep', '1:1 prep, {{date}}',
+			'Wins since last time:' || char(10) || char(10) || 'Blockers and where I need help:' || char(10) || char(10) || 'Topics to raise:' || char(10) || char(10) || 'Feedback to give or ask for:' || char(10),
+			datetime('now'), datetime('now'));`,
+	// 13: tasks, action items found in notes by the model. fingerprint is the
+	// normalised task text, so a task dismissed once is not proposed again
+	// after the note is edited. start_offset and end_offset locate quote in
+	// the note's content in characters, and are null once it is edited away.
+	// task_extractions holds the hash of each note as it was last read.
+	`CREATE TABLE tasks (
+		id TEXT PRIMARY KEY,
+		note_id TEXT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		text TEXT NOT NULL,
+		quote TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		status TEXT NOT NULL,
+		start_offset INTEGER,
+		end_offset INTEGER,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		completed_at DATETIME
+	);
+	CREATE INDEX tasks_note ON tasks (note_id);
+	CREATE INDEX tasks_status ON tasks (status);
+	CREATE TABLE task_extractions (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		content_hash TEXT NOT NULL,
+		extracted_at DATETIME NOT NULL
+	);`,
+	// 14: entities, the people and places found in notes by the model, with
+	// the names they go by in entity_aliases. alias_key is the lower case
+	// name, unique per kind, so each name found leads to one entity. Names of
+	// rejected entities stay so they are not proposed again.
+	// entity_mentions links notes to the entities they mention, with how the
+	// writer felt about them there from -1 to 1, and entity_extractions holds
+	// the hash of each note as it was last read.
+	`CREATE TABLE entities (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE entity_aliases (
+		entity_id TEXT NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		alias TEXT NOT NULL,
+		alias_key TEXT NOT NULL,
+		PRIMARY KEY (kind, alias_key)
+	);
+	CREATE INDEX entity_aliases_entity ON entity_aliases (entity_id);
+	CREATE TABLE entity_mentions (
+		note_id TEXT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		entity_id TEXT NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		sentiment REAL NOT NULL,
+		PRIMARY KEY (note_id, entity_id)
+	);
+	CREATE INDEX entity_mentions_entity ON entity_mentions (entity_id);
+	CREATE TABLE entity_extractions (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		content_hash TEXT NOT NULL,
+		extracted_at DATETIME NOT NULL
+	);`,
+	// 15: note_links, the [[wiki links]] in note content by position, with
+	// the note each resolves to or NULL while none does. note_link_keys holds
+	// what a note is linked to by, its title and local creation day, and marks
+	// the notes whose links have been recorded.
+	`CREATE TABLE note_links (
+		source_id TEXT NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		target TEXT NOT NULL,
+		target_key TEXT NOT NULL,
+		label TEXT NOT NULL,
+		start_offset INTEGER NOT NULL,
+		end_offset INTEGER NOT NULL,
+		target_id TEXT REFERENCES notes(id) ON DELETE SET NULL,
+		PRIMARY KEY (source_id, position)
+	);
+	CREATE INDEX note_links_target ON note_links (target_id);
+	CREATE TABLE note_link_keys (
+		note_id TEXT PRIMARY KEY REFERENCES notes(id) ON DELETE CASCADE,
+		title_key TEXT NOT NULL,
+		day TEXT NOT NULL
+	);
+	CREATE INDEX note_link_keys_title ON note_link_keys (title_key);
+	CREATE INDEX note_link_keys_day ON note_link_keys (day);`,
+	// 16: the hash of a note's title and content at the last vault sync, as
+	// automatic renames keep the note's updated_at. Empty for rows written
+	// before it was kept.
+	`ALTER TABLE vault_sync_state ADD COLUMN note_hash TEXT NOT NULL DEFAULT '';`,
+}
+
+// SchemaVersion is the schema version this build of the backend expects
+var SchemaVersion = len(migrations)
+
+// CurrentSchemaVersion reads the schema version of the open database
+func (client *DBClient) CurrentSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := client.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+func (client *DBClient) migrate(ctx context.Context) error {
+	version, err := client.CurrentSchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	for i := version; i < SchemaVersion; i++ {
+		log.Printf("Applying database migration %d", i+1)
+		err := client.WithTx(ctx, func(tx *Tx) error {
+			if _, err := tx.tx.ExecContext(ctx, migrations[i]); err != nil {
+				return err
+			}
+			// PRAGMA does not accept bound parameters
+			_, err := tx.tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"backend/digest_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GetDigestRequest struct {
+	DigestId string `json:"DigestId"`
+}
+
+type GenerateDigestRequest struct {
+	Kind string `json:"Kind"`
+	// Any day of the period, e.g. 2024-03-15 for March 2024
+	Date string `json:"Date"`
+}
+
+// listDigestsHandler lists digests newest first, of one kind when the kind
+// query parameter is set
+func listDigestsHandler(w http.ResponseWriter, r *http.Request, digestService *digest_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && kind != digest_service.KindWeekly && kind != digest_service.KindMonthly {
+		http.Error(w, digest_service.ErrInvalidKind.Error(), http.StatusBadRequest)
+		return
+	}
+	digests, err := digestService.List(r.Context(), kind)
+	if err != nil {
+		log.Printf("Error listing digests: %v", err)
+		http.Error(w, "Failed to list digests", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(digests)
+}
+
+func getDigestHandler(w http.ResponseWriter, r *http.Request, digestService *digest_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetDigestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	digestId, err := uuid.Parse(req.DigestId)
+	if err != nil {
+		http.Error(w, "Invalid digest id", http.StatusBadRequest)
+		return
+	}
+
+	digest, err := digestService.Get(r.Context(), digestId)
+	if errors.Is(err, digest_service.ErrDigestNotFound) {
+		http.Error(w, "Digest not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting digest: %v", err)
+		http.Error(w, "Failed to get digest", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(digest)
+}
+
+// generateDigestHandler writes the digest of a period straight away, e.g. for
+// a period before digests were introduced or to rewrite one
+func generateDigestHandler(w http.ResponseWriter, r *http.Request, digestService *digest_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GenerateDigestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	day, err := time.ParseInLocation(time.DateOnly, req.Date, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	digest, err := digestService.Generate(r.Context(), req.Kind, day)
+	switch {
+	case errors.Is(err, digest_service.ErrInvalidKind), errors.Is(err, digest_service.ErrNoEntries):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, digest_service.ErrModelUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		log.Printf("Error generating digest: %v", err)
+		http.Error(w, "Failed to generate digest", http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(digest)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+The digest_service package writes weekly and monthly digests of the journal
+with the local model and keeps them, along with the notes each was written
+from, so past digests can be read again.
+
+Weeks run from Monday to Sunday and months from the first to the last day,
+both in the local time zone. A digest is written once its period is over;
+periods without entries get no digest.
+*/
+package digest_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/scheduler"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	KindWeekly  = "weekly"
+	KindMonthly = "monthly"
+)
+
+var (
+	ErrDigestNotFound = errors.New("digest not found")
+	ErrInvalidKind    = fmt.Errorf("kind must be %q or %q", KindWeekly, KindMonthly)
+	ErrNoEntries      = errors.New("no journal entries in this period")
+	// The scheduler tries again later when this is returned
+	ErrModelUnavailable = errors.New("the model is not available yet")
+)
+
+// How long to wait before trying a digest again, e.g. while the model loads
+const retryAfter = 10 * time.Minute
+
+type Digest struct {
+	DigestId uuid.UUID `json:"DigestId"`
+	Kind     string    `json:"Kind"`
+	// First and last day of the period, e.g. 2024-03-04 and 2024-03-10
+	PeriodStart   string      `json:"PeriodStart"`
+	PeriodEnd     string      `json:"PeriodEnd"`
+	Content       string      `json:"Content"`
+	SourceNoteIds []uuid.UUID `json:"SourceNoteIds"`
+	CreatedAt     time.Time   `json:"CreatedAt"`
+}
+
+// DigestSummary describes a digest without its content, for listing
+type DigestSummary struct {
+	DigestId    uuid.UUID `json:"DigestId"`
+	Kind        string    `json:"Kind"`
+	PeriodStart string    `json:"PeriodStart"`
+	PeriodEnd   string    `json:"PeriodEnd"`
+	NoteCount   int       `json:"NoteCount"`
+	CreatedAt   time.Time `json:"CreatedAt"`
+}
+
+// DigestGenerator is the part of the chat service digests need
+type DigestGenerator interface {
+	GenerateDigest(ctx context.Context, period string, entries []lm_service.DigestEntry) (string, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	notesService notes_service.NotesService
+	generator    DigestGenerator
+	dbClient     *db_client.DBClient
+}
+
+func NewService(notesService notes_service.NotesService, generator DigestGenerator, dbClient *db_client.DBClient) *Service {
+	return &Service{notesService: notesService, generator: generator, dbClient: dbClient}
+}
+
+// Period returns the start of the period of kind containing t and the start
+// of the next one
+func Period(kind string, t time.Time) (time.Time, time.Time, error) {
+	year, month, day := t.In(time.Local).Date()
+	switch kind {
+	case KindWeekly:
+		// Weekday counts from Sunday, weeks start on Monday
+		day -= (int(t.In(time.Local).Weekday()) + 6) % 7
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(0, 0, 7), nil
+	case KindMonthly:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, ErrInvalidKind
+	}
+}
+
+// describePeriod names a period for the model
+func describePeriod(kind string, start time.Time) string {
+	if kind == KindWeekly {
+		return "the week of " + start.Format("Monday 2 January 2006")
+	}
+	return start.Format("January 2006")
+}
+
+// Generate writes the digest of the period of kind containing day, replacing
+// any digest already written for it
+func (service *Service) Generate(ctx context.Context, kind string, day time.Time) (Digest, error) {
+	start, end, err := Period(kind, day)
+	if err != nil {
+		return Digest{}, err
+	}
+	notes, err := service.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return Digest{}, err
+	}
+	notes = notes_service.NoteFilter{From: start, To: end}.Apply(notes)
+	if len(notes) == 0 {
+		return Digest{}, fmt.Errorf("%w: %s", ErrNoEntries, describePeriod(kind, start))
+	}
+	if !service.generator.GetStatus() {
+		return Digest{}, ErrModelUnavailable
+	}
+
+	entries := []lm_service.DigestEntry{}
+	noteIds := []uuid.UUID{}
+	for _, note := range notes {
+		entries = append(entries, lm_service.DigestEntry{CreatedAt: note.CreatedAt.Local(), Title: note.Title, Content: note.Content})
+		noteIds = append(noteIds, note.NoteId)
+	}
+	content, err := service.generator.GenerateDigest(ctx, describePeriod(kind, start), entries)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to write the %s digest for %s: %w", kind, start.Format(time.DateOnly), err)
+	}
+
+	digest := Digest{
+		DigestId:      uuid.New(),
+		Kind:          kind,
+		PeriodStart:   start.Format(time.DateOnly),
+		PeriodEnd:     end.AddDate(0, 0, -1).Format(time.DateOnly),
+		Content:       content,
+		SourceNoteIds: noteIds,
+		CreatedAt:     time.Now(),
+	}
+	err = service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		// Regenerating keeps the digest's id
+		var existingId uuid.UUID
+		err := tx.QueryRowContext(ctx, "SELECT id FROM digests WHERE kind = ? AND period_start = ?", kind, digest.PeriodStart).Scan(&existingId)
+		switch {
+		case err == nil:
+			digest.DigestId = existingId
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		return saveDigest(ctx, tx, digest)
+	})
+	if err != nil {
+		return Digest{}, err
+	}
+	return digest, nil
+}
+
+// Jobs schedules the digests: weekly ones on Monday morning and monthly ones
+// on the morning of the first. Both also run at start to catch up on a digest
+// missed while the backend was not running.
+func (service *Service) Jobs() []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:       "weekly digest",
+			Schedule:   scheduler.Weekly{Weekday: time.Monday, Hour: 6},
+			RunAtStart: true,
+			RetryAfter: retryAfter,
+			Run:        func(ctx context.Context) error { return service.GenerateDue(ctx, KindWeekly) },
+		},
+		{
+			Name:       "monthly digest",
+			Schedule:   scheduler.Monthly{Day: 1, Hour: 6},
+			RunAtStart: true,
+			RetryAfter: retryAfter,
+			Run:        func(ctx context.Context) error { return service.GenerateDue(ctx, KindMonthly) },
+		},
+	}
+}
+
+// GenerateDue writes the digest of the last complete period of kind if it has
+// not been written yet
+func (service *Service) GenerateDue(ctx context.Context, kind string) error {
+	current, _, err := Period(kind, time.Now())
+	if err != nil {
+		return err
+	}
+	last, _, _ := Period(kind, current.AddDate(0, 0, -1))
+	var exists int
+	err = service.dbClient.QueryRowContext(ctx, "SELECT COUNT(*) FROM digests WHERE kind = ? AND period_start = ?", kind, last.Format(time.DateOnly)).Scan(&exists)
+	if err != nil || exists > 0 {
+		return err
+	}
+
+	digest, err := service.Generate(ctx, kind, last)
+	if errors.Is(err, ErrNoEntries) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Wrote the %s digest for %s to %s", kind, digest.PeriodStart, digest.PeriodEnd)
+	return nil
+}
+
+// List returns the digests of kind, or of every kind when kind is empty,
+// newest period first
+func (service *Service) List(ctx context.Context, kind string) ([]DigestSummary, error) {
+	query := `SELECT d.id, d.kind, d.period_start, d.period_end, d.created_at, COUNT(n.note_id)
+		FROM digests d LEFT JOIN digest_notes n ON n.digest_id = d.id`
+	args := []any{}
+	if kind != "" {
+		query += " WHERE d.kind = ?"
+		args = append(args, kind)
+	}
+	query += " GROUP BY d.id ORDER BY d.period_start DESC, d.kind"
+
+	rows, err := service.dbClient.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries := []DigestSummary{}
+	for rows.Next() {
+		var summary DigestSummary
+		if err := rows.Scan(&summary.DigestId, &summary.Kind, &summary.PeriodStart, &summary.PeriodEnd, &summary.CreatedAt, &summary.NoteCount); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+func (service *Service) Get(ctx context.Context, id uuid.UUID) (Digest, error) {
+	digest := Digest{SourceNoteIds: []uuid.UUID{}}
+	err := service.dbClient.QueryRowContext(ctx, "SELECT id, kind, period_start, period_end, content, created_at FROM digests WHERE id = ?", id).
+		Scan(&digest.DigestId, &digest.Kind, &digest.PeriodStart, &digest.PeriodEnd, &digest.Content, &digest.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Digest{}, fmt.Errorf("%w: %v", ErrDigestNotFound, id)
+	}
+	if err != nil {
+		return Digest{}, err
+	}
+
+	rows, err := service.dbClient.QueryContext(ctx, "SELECT note_id FROM digest_notes WHERE digest_id = ?", id)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var noteId uuid.UUID
+		if err := rows.Scan(&noteId); err != nil {
+			return Digest{}, err
+		}
+		digest.SourceNoteIds = append(digest.SourceNoteIds, noteId)
+	}
+	return digest, rows.Err()
+}
+
+func saveDigest(ctx context.Context, executor db_client.Executor, digest Digest) error {
+	sqlStatement := `INSERT INTO digests (id, kind, period_start, period_end, content, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET content = excluded.content, period_end = excluded.period_end, created_at = excluded.created_at`
+	_, err := executor.ExecContext(ctx, sqlStatement, digest.DigestId, digest.Kind, digest.PeriodStart, digest.PeriodEnd, digest.Content, digest.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := executor.ExecContext(ctx, "DELETE FROM digest_notes WHERE digest_id = ?", digest.DigestId); err != nil {
+		return err
+	}
+	for _, noteId := range digest.SourceNoteIds {
+		if _, err := executor.ExecContext(ctx, "INSERT INTO digest_notes (digest_id, note_id) VALUES (?, ?)", digest.DigestId, noteId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"backend/entity_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// EntityRequest names an entity by id or by any of its names
+type EntityRequest struct {
+	EntityId string `json:"EntityId"`
+	// Used when EntityId is empty, e.g. "Alex"
+	Name string `json:"Name"`
+}
+
+type SetEntityStatusRequest struct {
+	EntityId string `json:"EntityId"`
+	// "confirmed", "rejected", or "proposed" to undo a decision
+	Status string `json:"Status"`
+}
+
+type UpdateEntityRequest struct {
+	EntityId string `json:"EntityId"`
+	Name     string `json:"Name"`
+	// The other names the entity goes by, replacing the current ones
+	Aliases []string `json:"Aliases"`
+}
+
+type MergeEntitiesRequest struct {
+	// Merged into IntoEntityId and deleted
+	EntityId     string `json:"EntityId"`
+	IntoEntityId string `json:"IntoEntityId"`
+}
+
+type SentimentSeriesRequest struct {
+	EntityId string `json:"EntityId"`
+	Name     string `json:"Name"`
+	// "day", "week" or "month" (the default)
+	Bucket string `json:"Bucket"`
+}
+
+// listEntitiesHandler lists people and places, the most mentioned first,
+// filtered by the kind and status query parameters when set
+func listEntitiesHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	entities, err := entityService.Entities(r.Context(), query.Get("kind"), query.Get("status"))
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entities)
+}
+
+func setEntityStatusHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetEntityStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entityId, err := uuid.Parse(req.EntityId)
+	if err != nil {
+		http.Error(w, "Invalid entity id", http.StatusBadRequest)
+		return
+	}
+
+	entity, err := entityService.SetStatus(r.Context(), entityId, req.Status)
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
+}
+
+func updateEntityHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req UpdateEntityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entityId, err := uuid.Parse(req.EntityId)
+	if err != nil {
+		http.Error(w, "Invalid entity id", http.StatusBadRequest)
+		return
+	}
+
+	entity, err := entityService.Update(r.Context(), entityId, req.Name, req.Aliases)
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
+}
+
+func mergeEntitiesHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MergeEntitiesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entityId, err := uuid.Parse(req.EntityId)
+	if err != nil {
+		http.Error(w, "Invalid entity id", http.StatusBadRequest)
+		return
+	}
+	intoId, err := uuid.Parse(req.IntoEntityId)
+	if err != nil {
+		http.Error(w, "Invalid entity id to merge into", http.StatusBadRequest)
+		return
+	}
+
+	entity, err := entityService.Merge(r.Context(), entityId, intoId)
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
+}
+
+// entityMentionsHandler lists the notes mentioning an entity, the newest
+// first
+func entityMentionsHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EntityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entityId, ok := resolveEntity(w, r, entityService, req.EntityId, req.Name)
+	if !ok {
+		return
+	}
+
+	mentions, err := entityService.Mentions(r.Context(), entityId)
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mentions)
+}
+
+// entitySentimentHandler returns how the writer felt about an entity over
+// time
+func entitySentimentHandler(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SentimentSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entityId, ok := resolveEntity(w, r, entityService, req.EntityId, req.Name)
+	if !ok {
+		return
+	}
+
+	buckets, err := entityService.SentimentSeries(r.Context(), entityId, req.Bucket)
+	if err != nil {
+		writeEntityError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+// resolveEntity parses entityId, or finds the entity going by name when it
+// is empty
+func resolveEntity(w http.ResponseWriter, r *http.Request, entityService *entity_service.Service, entityId string, name string) (uuid.UUID, bool) {
+	if entityId != "" {
+		id, err := uuid.Parse(entityId)
+		if err != nil {
+			http.Error(w, "Invalid entity id", http.StatusBadRequest)
+			return uuid.Nil, false
+		}
+		return id, true
+	}
+	if name == "" {
+		http.Error(w, "EntityId or Name is required", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	entity, err := entityService.Find(r.Context(), name)
+	if err != nil {
+		writeEntityError(w, err)
+		return uuid.Nil, false
+	}
+	return entity.EntityId, true
+}
+
+func writeEntityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, entity_service.ErrEntityNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, entity_service.ErrInvalidEntity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error handling people and places request: %v", err)
+		http.Error(w, "Failed to handle people and places request", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,123 @@
+package entity_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Most buckets SentimentSeries returns, about ten years of days
+const maxBuckets = 3660
+
+// SentimentBucket aggregates the mentions of an entity over a day, week or
+// month
+type SentimentBucket struct {
+	// First day of the bucket in the local time zone, e.g. 2024-01-01. Weeks
+	// start on Monday.
+	Start    string `json:"Start"`
+	Mentions int    `json:"Mentions"`
+	// Mean sentiment of the mentions from -1 to 1, omitted without mentions
+	Sentiment *float64 `json:"Sentiment,omitempty"`
+}
+
+// SentimentSeries aggregates how the writer felt about an entity by bucket,
+// from the first note mentioning it to the last. Every bucket in between is
+// returned, empty or not, so the series can be charted directly.
+func (service *Service) SentimentSeries(ctx context.Context, id uuid.UUID, bucket string) ([]SentimentBucket, error) {
+	if bucket == "" {
+		bucket = BucketMonth
+	}
+	if bucket != BucketDay && bucket != BucketWeek && bucket != BucketMonth {
+		return nil, fmt.Errorf("%w: bucket must be %q, %q or %q", ErrInvalidEntity, BucketDay, BucketWeek, BucketMonth)
+	}
+	if _, err := service.Entity(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := service.dbClient.QueryContext(ctx, `SELECT n.created_at, m.sentiment FROM entity_mentions m JOIN notes n ON n.id = m.note_id
+		WHERE m.entity_id = ? ORDER BY julianday(n.created_at)`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type mention struct {
+		createdAt time.Time
+		sentiment float64
+	}
+	mentions := []mention{}
+	for rows.Next() {
+		var m mention
+		if err := rows.Scan(&m.createdAt, &m.sentiment); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	buckets := []SentimentBucket{}
+	if len(mentions) == 0 {
+		return buckets, nil
+	}
+
+	indexByStart := map[string]int{}
+	end := nextBucket(bucketStart(mentions[len(mentions)-1].createdAt, bucket), bucket)
+	for start := bucketStart(mentions[0].createdAt, bucket); start.Before(end); start = nextBucket(start, bucket) {
+		if len(buckets) == maxBuckets {
+			return nil, fmt.Errorf("%w: more than %d buckets, use a longer bucket", ErrInvalidEntity, maxBuckets)
+		}
+		key := start.Format(time.DateOnly)
+		indexByStart[key] = len(buckets)
+		buckets = append(buckets, SentimentBucket{Start: key})
+	}
+	sums := make([]float64, len(buckets))
+	for _, m := range mentions {
+		i := indexByStart[bucketStart(m.createdAt, bucket).Format(time.DateOnly)]
+		buckets[i].Mentions++
+		sums[i] += m.sentiment
+	}
+	for i := range buckets {
+		if buckets[i].Mentions > 0 {
+			mean := sums[i] / float64(buckets[i].Mentions)
+			buckets[i].Sentiment = &mean
+		}
+	}
+	return buckets, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func bucketStart(t time.Time, bucket string) time.Time {
+	day := startOfDay(t)
+	switch bucket {
+	case BucketWeek:
+		// Weekday counts from Sunday, weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case BucketMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case BucketMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
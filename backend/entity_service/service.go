@@ -0,0 +1,331 @@
+/*
+The entity_service package recognises the people and places that come up
+across journal entries. The local model reads each note in the background for
+the people and places it mentions, and how the writer feels about them there.
+
+Each name found leads to an entity, matched on any of the names the entity
+goes by, regardless of case. New entities are proposed until the user
+confirms or rejects them; the user can also rename them, give them more names
+and merge two entities found under different names. Names of rejected
+entities are not proposed again.
+*/
+package entity_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entries are saved as they are typed, so extraction waits for edits to
+// settle
+const debounce = time.Minute
+
+var (
+	ErrEntityNotFound = errors.New("entity not found")
+	ErrInvalidEntity  = errors.New("invalid entity")
+	ErrInvalidStatus  = fmt.Errorf("%w: status must be %q, %q or %q", ErrInvalidEntity, StatusProposed, StatusConfirmed, StatusRejected)
+)
+
+// Extractor is the part of the chat service the job needs
+type Extractor interface {
+	ExtractEntities(ctx context.Context, title string, content string) ([]lm_service.ExtractedEntity, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	// Provides Run, Trigger, Close and NotifyOnChange
+	*sweep.Worker
+	notesService notes_service.NotesService
+	extractor    Extractor
+	dbClient     *db_client.DBClient
+}
+
+func NewService(notesService notes_service.NotesService, extractor Extractor, dbClient *db_client.DBClient) *Service {
+	service := &Service{
+		notesService: notesService,
+		extractor:    extractor,
+		dbClient:     dbClient,
+	}
+	service.Worker = sweep.New(notesService, sweep.Job{
+		Name:     "People and places extraction",
+		Version:  extractionVersion,
+		Debounce: debounce,
+		Ready:    extractor.GetStatus,
+		Hashes: func(ctx context.Context) (map[uuid.UUID]string, error) {
+			return loadHashes(ctx, dbClient)
+		},
+		Read: service.read,
+		AfterSweep: func(ctx context.Context) error {
+			return deleteUnmentioned(ctx, dbClient)
+		},
+	})
+	return service
+}
+
+// read records the people and places a note mentions
+func (service *Service) read(ctx context.Context, note notes_service.Note) error {
+	extracted := []lm_service.ExtractedEntity{}
+	if strings.TrimSpace(note.Content) != "" {
+		var err error
+		extracted, err = service.extractor.ExtractEntities(ctx, note.Title, note.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		return saveMentions(ctx, tx, note, extracted)
+	})
+}
+
+// saveMentions replaces the mentions of note with the entities just
+// extracted from it, proposing an entity for each name not known yet
+func saveMentions(ctx context.Context, executor db_client.Executor, note notes_service.Note, extracted []lm_service.ExtractedEntity) error {
+	if _, err := executor.ExecContext(ctx, "DELETE FROM entity_mentions WHERE note_id = ?", note.NoteId); err != nil {
+		return err
+	}
+	for _, entity := range extracted {
+		entityId, status, err := findAlias(ctx, executor, entity.Kind, entity.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			status = StatusProposed
+			entityId, err = createEntity(ctx, executor, entity.Name, entity.Kind)
+		}
+		if err != nil {
+			return err
+		}
+		if status == StatusRejected {
+			continue
+		}
+		// A note naming an entity twice, e.g. by two of its names, keeps the
+		// first
+		_, err = executor.ExecContext(ctx, `INSERT INTO entity_mentions (note_id, entity_id, name, sentiment) VALUES (?, ?, ?, ?)
+			ON CONFLICT (note_id, entity_id) DO NOTHING`, note.NoteId, entityId, entity.Name, entity.Sentiment)
+		if err != nil {
+			return err
+		}
+	}
+	return saveExtraction(ctx, executor, note)
+}
+
+// Entities returns the entities of a kind and status, the most mentioned
+// first. Empty kind or status matches any.
+func (service *Service) Entities(ctx context.Context, kind string, status string) ([]Entity, error) {
+	if kind != "" && kind != lm_service.EntityPerson && kind != lm_service.EntityPlace {
+		return nil, fmt.Errorf("%w: kind must be %q or %q", ErrInvalidEntity, lm_service.EntityPerson, lm_service.EntityPlace)
+	}
+	if status != "" && !isStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	return loadEntities(ctx, service.dbClient, "(? = '' OR e.kind = ?) AND (? = '' OR e.status = ?)", kind, kind, status, status)
+}
+
+func (service *Service) Entity(ctx context.Context, id uuid.UUID) (Entity, error) {
+	return getEntity(ctx, service.dbClient, id)
+}
+
+// Find returns the entity going by name, preferring a confirmed one when
+// both a person and a place do
+func (service *Service) Find(ctx context.Context, name string) (Entity, error) {
+	var entityId uuid.UUID
+	err := service.dbClient.QueryRowContext(ctx, `SELECT e.id FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+		WHERE a.alias_key = ? AND e.status != ? ORDER BY e.status = ? DESC LIMIT 1`, aliasKey(name), StatusRejected, StatusConfirmed).Scan(&entityId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entity{}, fmt.Errorf("%w: %q", ErrEntityNotFound, name)
+	}
+	if err != nil {
+		return Entity{}, err
+	}
+	return service.Entity(ctx, entityId)
+}
+
+// SetStatus confirms or rejects an entity, or proposes it again
+func (service *Service) SetStatus(ctx context.Context, id uuid.UUID, status string) (Entity, error) {
+	if !isStatus(status) {
+		return Entity{}, ErrInvalidStatus
+	}
+	var entity Entity
+	reread := false
+	err := service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		var previous string
+		err := tx.QueryRowContext(ctx, "SELECT status FROM entities WHERE id = ?", id).Scan(&previous)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %v", ErrEntityNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE entities SET status = ?, updated_at = ? WHERE id = ?", status, time.Now(), id); err != nil {
+			return err
+		}
+		if status == StatusRejected {
+			// Kept for its names only
+			if _, err := tx.ExecContext(ctx, "DELETE FROM entity_mentions WHERE entity_id = ?", id); err != nil {
+				return err
+			}
+		}
+		if previous == StatusRejected && status != StatusRejected {
+			// Its mentions were dropped and notes read since skipped it, so
+			// the notes naming it are read again
+			if err := forgetExtractionsNaming(ctx, tx, id); err != nil {
+				return err
+			}
+			reread = true
+		}
+		entity, err = getEntity(ctx, tx, id)
+		return err
+	})
+	if err == nil && reread {
+		service.Trigger()
+	}
+	return entity, err
+}
+
+// Update renames an entity and replaces the other names it goes by. Notes
+// already read keep their mentions; the names apply to notes read from now
+// on.
+func (service *Service) Update(ctx context.Context, id uuid.UUID, name string, aliases []string) (Entity, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return Entity{}, fmt.Errorf("%w: name is required", ErrInvalidEntity)
+	}
+	var entity Entity
+	err := service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		current, err := getEntity(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		names := map[string]string{aliasKey(name): name}
+		for _, alias := range aliases {
+			alias = strings.Join(strings.Fields(alias), " ")
+			if _, ok := names[aliasKey(alias)]; alias != "" && !ok {
+				names[aliasKey(alias)] = alias
+			}
+		}
+		for _, alias := range names {
+			otherId, _, err := findAlias(ctx, tx, current.Kind, alias)
+			if err == nil && otherId != id {
+				other, err := getEntity(ctx, tx, otherId)
+				if err != nil {
+					return err
+				}
+				return fmt.Errorf("%w: %q is a name of %s, merge the two instead", ErrInvalidEntity, alias, other.Name)
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE entities SET name = ?, updated_at = ? WHERE id = ?", name, time.Now(), id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM entity_aliases WHERE entity_id = ?", id); err != nil {
+			return err
+		}
+		for key, alias := range names {
+			_, err := tx.ExecContext(ctx, "INSERT INTO entity_aliases (entity_id, kind, alias, alias_key) VALUES (?, ?, ?, ?)", id, current.Kind, alias, key)
+			if err != nil {
+				return err
+			}
+		}
+		entity, err = getEntity(ctx, tx, id)
+		return err
+	})
+	return entity, err
+}
+
+// Merge moves the names and mentions of one entity to another of the same
+// kind, e.g. "Alex" into "Alexandra", and deletes it. The merged entity is
+// confirmed.
+func (service *Service) Merge(ctx context.Context, id uuid.UUID, intoId uuid.UUID) (Entity, error) {
+	if id == intoId {
+		return Entity{}, fmt.Errorf("%w: an entity cannot be merged into itself", ErrInvalidEntity)
+	}
+	var entity Entity
+	err := service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		from, err := getEntity(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		into, err := getEntity(ctx, tx, intoId)
+		if err != nil {
+			return err
+		}
+		if from.Kind != into.Kind {
+			return fmt.Errorf("%w: a %s cannot be merged into a %s", ErrInvalidEntity, from.Kind, into.Kind)
+		}
+
+		statements := []string{
+			"UPDATE entity_aliases SET entity_id = ? WHERE entity_id = ?",
+			// Notes mentioning both keep the mention of the entity merged into
+			`INSERT INTO entity_mentions (note_id, entity_id, name, sentiment) SELECT note_id, ?, name, sentiment FROM entity_mentions
+				WHERE entity_id = ? ON CONFLICT (note_id, entity_id) DO NOTHING`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement, intoId, id); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM entities WHERE id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE entities SET status = ?, updated_at = ? WHERE id = ?", StatusConfirmed, time.Now(), intoId); err != nil {
+			return err
+		}
+		entity, err = getEntity(ctx, tx, intoId)
+		return err
+	})
+	return entity, err
+}
+
+// Mentions returns the notes mentioning an entity, the newest first
+func (service *Service) Mentions(ctx context.Context, id uuid.UUID) ([]Mention, error) {
+	if _, err := service.Entity(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := service.dbClient.QueryContext(ctx, "SELECT note_id, name, sentiment FROM entity_mentions WHERE entity_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	mentions := map[uuid.UUID]Mention{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var mention Mention
+		if err := rows.Scan(&noteId, &mention.Name, &mention.Sentiment); err != nil {
+			return nil, err
+		}
+		mentions[noteId] = mention
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	notes, err := service.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []Mention{}
+	for _, note := range notes {
+		if mention, ok := mentions[note.NoteId]; ok {
+			mention.Note = note
+			result = append(result, mention)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Note.CreatedAt.After(result[j].Note.CreatedAt) })
+	return result, nil
+}
+
+func isStatus(status string) bool {
+	return status == StatusProposed || status == StatusConfirmed || status == StatusRejected
+}
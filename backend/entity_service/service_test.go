@@ -0,0 +1,94 @@
+package entity_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeExtractor finds the names it knows in an entry, as they are written
+type fakeExtractor struct {
+	names []string
+}
+
+func (extractor *fakeExtractor) ExtractEntities(_ context.Context, title string, content string) ([]lm_service.ExtractedEntity, error) {
+	entities := []lm_service.ExtractedEntity{}
+	text := title + " " + content
+	for _, name := range extractor.names {
+		if index := strings.Index(strings.ToLower(text), strings.ToLower(name)); index >= 0 {
+			entities = append(entities, lm_service.ExtractedEntity{Name: text[index : index+len(name)], Kind: lm_service.EntityPerson})
+		}
+	}
+	return entities, nil
+}
+
+func (extractor *fakeExtractor) GetStatus() bool { return true }
+
+func TestUnrejectedEntityIsReadAgain(t *testing.T) {
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbClient.Close(ctx) })
+	notesService := notes_service.NewNotesServiceImpl(dbClient)
+	service := NewService(notesService, &fakeExtractor{names: []string{"Sam"}}, dbClient)
+
+	read := func(title string, content string) notes_service.Note {
+		t.Helper()
+		note, err := notesService.CreateNoteWithContent(ctx, title, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := service.read(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+		return note
+	}
+	coffee := read("Coffee", "Met sam at the cafe")
+	walk := read("Walk", "Alone by the river")
+
+	sam, err := service.Find(ctx, "Sam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SetStatus(ctx, sam.EntityId, StatusRejected); err != nil {
+		t.Fatal(err)
+	}
+	// Read while rejected, so it is not mentioned
+	lunch := read("Lunch", "Sam again")
+
+	if _, err := service.SetStatus(ctx, sam.EntityId, StatusConfirmed); err != nil {
+		t.Fatal(err)
+	}
+	hashes, err := loadHashes(ctx, dbClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := hashes[coffee.NoteId]; ok {
+		t.Error("the note mentioning Sam before the rejection is not read again")
+	}
+	if _, ok := hashes[lunch.NoteId]; ok {
+		t.Error("the note naming Sam while rejected is not read again")
+	}
+	if _, ok := hashes[walk.NoteId]; !ok {
+		t.Error("the note not naming Sam is read again")
+	}
+
+	for _, note := range []notes_service.Note{coffee, lunch} {
+		if err := service.read(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mentions, err := service.Mentions(ctx, sam.EntityId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 2 {
+		t.Errorf("got %d mentions of Sam, want 2", len(mentions))
+	}
+}
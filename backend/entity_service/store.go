@@ -0,0 +1,222 @@
+package entity_service
+
+import (
+	"backend/db"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Found by the model and waiting to be confirmed or rejected
+	StatusProposed  = "proposed"
+	StatusConfirmed = "confirmed"
+	// Not a person or place worth keeping. Its names are not proposed again.
+	StatusRejected = "rejected"
+)
+
+// Entity is a person or place mentioned across notes
+type Entity struct {
+	EntityId uuid.UUID `json:"EntityId"`
+	Name     string    `json:"Name"`
+	// "person" or "place"
+	Kind   string `json:"Kind"`
+	Status string `json:"Status"`
+	// Every name the entity goes by, Name included
+	Aliases  []string `json:"Aliases"`
+	Mentions int      `json:"Mentions"`
+	// Creation time of the most recent note mentioning the entity, omitted
+	// without mentions
+	LastMentioned *time.Time `json:"LastMentioned,omitempty"`
+	CreatedAt     time.Time  `json:"CreatedAt"`
+	UpdatedAt     time.Time  `json:"UpdatedAt"`
+}
+
+// Mention is a note mentioning an entity
+type Mention struct {
+	Note notes_service.Note `json:"Note"`
+	// The name the note uses
+	Name string `json:"Name"`
+	// How the writer felt about the entity in the note, from -1 to 1
+	Sentiment float64 `json:"Sentiment"`
+}
+
+// Bumped when the prompt or schema changes, so every note is read again
+const extractionVersion = "1"
+
+// aliasKey is the form names are matched in, so "Alex" and "alex " are one
+func aliasKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// loadHashes returns the content hash of every note entities were extracted
+// from
+func loadHashes(ctx context.Context, executor db_client.Executor) (map[uuid.UUID]string, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, content_hash FROM entity_extractions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[uuid.UUID]string{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var hash string
+		if err := rows.Scan(&noteId, &hash); err != nil {
+			return nil, err
+		}
+		hashes[noteId] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadEntities loads the entities matching where, a condition on the
+// entities table aliased e, the most mentioned first
+func loadEntities(ctx context.Context, executor db_client.Executor, where string, args ...any) ([]Entity, error) {
+	rows, err := executor.QueryContext(ctx, `SELECT e.id, e.name, e.kind, e.status, e.created_at, e.updated_at, COUNT(n.id),
+		(SELECT n2.created_at FROM entity_mentions m2 JOIN notes n2 ON n2.id = m2.note_id WHERE m2.entity_id = e.id
+			ORDER BY julianday(n2.created_at) DESC LIMIT 1)
+		FROM entities e LEFT JOIN entity_mentions m ON m.entity_id = e.id LEFT JOIN notes n ON n.id = m.note_id
+		WHERE `+where+` GROUP BY e.id ORDER BY COUNT(n.id) DESC, e.name COLLATE NOCASE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []Entity{}
+	indexById := map[uuid.UUID]int{}
+	for rows.Next() {
+		var entity Entity
+		var lastMentioned sql.NullTime
+		if err := rows.Scan(&entity.EntityId, &entity.Name, &entity.Kind, &entity.Status, &entity.CreatedAt, &entity.UpdatedAt, &entity.Mentions, &lastMentioned); err != nil {
+			return nil, err
+		}
+		if lastMentioned.Valid {
+			entity.LastMentioned = &lastMentioned.Time
+		}
+		entity.Aliases = []string{}
+		indexById[entity.EntityId] = len(entities)
+		entities = append(entities, entity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	aliasRows, err := executor.QueryContext(ctx, "SELECT a.entity_id, a.alias FROM entity_aliases a JOIN entities e ON e.id = a.entity_id WHERE "+where+" ORDER BY a.alias COLLATE NOCASE", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var entityId uuid.UUID
+		var alias string
+		if err := aliasRows.Scan(&entityId, &alias); err != nil {
+			return nil, err
+		}
+		if i, ok := indexById[entityId]; ok {
+			entities[i].Aliases = append(entities[i].Aliases, alias)
+		}
+	}
+	return entities, aliasRows.Err()
+}
+
+func getEntity(ctx context.Context, executor db_client.Executor, id uuid.UUID) (Entity, error) {
+	entities, err := loadEntities(ctx, executor, "e.id = ?", id)
+	if err != nil {
+		return Entity{}, err
+	}
+	if len(entities) == 0 {
+		return Entity{}, fmt.Errorf("%w: %v", ErrEntityNotFound, id)
+	}
+	return entities[0], nil
+}
+
+// findAlias returns the entity of kind going by name and its status
+func findAlias(ctx context.Context, executor db_client.Executor, kind string, name string) (uuid.UUID, string, error) {
+	var entityId uuid.UUID
+	var status string
+	err := executor.QueryRowContext(ctx, `SELECT e.id, e.status FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+		WHERE a.kind = ? AND a.alias_key = ?`, kind, aliasKey(name)).Scan(&entityId, &status)
+	return entityId, status, err
+}
+
+// createEntity creates a proposed entity going by name
+func createEntity(ctx context.Context, executor db_client.Executor, name string, kind string) (uuid.UUID, error) {
+	entityId := uuid.New()
+	now := time.Now()
+	_, err := executor.ExecContext(ctx, "INSERT INTO entities (id, name, kind, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		entityId, name, kind, StatusProposed, now, now)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	_, err = executor.ExecContext(ctx, "INSERT INTO entity_aliases (entity_id, kind, alias, alias_key) VALUES (?, ?, ?, ?)",
+		entityId, kind, name, aliasKey(name))
+	return entityId, err
+}
+
+// saveExtraction records that the entities of note were extracted from its
+// current content
+func saveExtraction(ctx context.Context, executor db_client.Executor, note notes_service.Note) error {
+	_, err := executor.ExecContext(ctx, `INSERT INTO entity_extractions (note_id, content_hash, extracted_at) VALUES (?, ?, ?)
+		ON CONFLICT (note_id) DO UPDATE SET content_hash = excluded.content_hash, extracted_at = excluded.extracted_at`,
+		note.NoteId, sweep.ContentHash(extractionVersion, note), time.Now())
+	return err
+}
+
+// forgetExtractionsNaming clears the extractions of the notes naming an
+// entity by any of its names, so they are read again. Names are found as
+// written, so these are the notes the model could have found it in.
+func forgetExtractionsNaming(ctx context.Context, executor db_client.Executor, entityId uuid.UUID) error {
+	entity, err := getEntity(ctx, executor, entityId)
+	if err != nil {
+		return err
+	}
+	keys := []string{aliasKey(entity.Name)}
+	for _, alias := range entity.Aliases {
+		keys = append(keys, aliasKey(alias))
+	}
+	rows, err := executor.QueryContext(ctx, "SELECT n.id, n.title, n.content FROM notes n JOIN entity_extractions x ON x.note_id = n.id")
+	if err != nil {
+		return err
+	}
+	noteIds := []uuid.UUID{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var title, content string
+		if err := rows.Scan(&noteId, &title, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		text := aliasKey(title + " " + content)
+		if slices.ContainsFunc(keys, func(key string) bool { return strings.Contains(text, key) }) {
+			noteIds = append(noteIds, noteId)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, noteId := range noteIds {
+		if _, err := executor.ExecContext(ctx, "DELETE FROM entity_extractions WHERE note_id = ?", noteId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUnmentioned removes proposed entities no note mentions any more,
+// e.g. after the only note mentioning them was edited or deleted
+func deleteUnmentioned(ctx context.Context, executor db_client.Executor) error {
+	_, err := executor.ExecContext(ctx, `DELETE FROM entities WHERE status = ?
+		AND NOT EXISTS (SELECT 1 FROM entity_mentions m WHERE m.entity_id = entities.id)`, StatusProposed)
+	return err
+}
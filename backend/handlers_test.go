@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/zip"
+	"backend/analysis_service"
+	"backend/backup"
+	"backend/clarity_service"
+	"backend/db"
+	"backend/digest_service"
+	"backend/entity_service"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/prompt_service"
+	"backend/resurface_service"
+	"backend/task_service"
+	"backend/title_service"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testToken = "test-token"
+
+// newTestMux serves the endpoints through registerRoutes like main, with the
+// notes kept in memory, everything else in a fresh database and a model that
+// is never ready
+func newTestMux(t *testing.T) (*http.ServeMux, notes_service.NotesService, *db_client.DBClient) {
+	t.Helper()
+	return newTestMuxWith(t, Config{}, newMemoryNotesService)
+}
+
+// newSQLiteTestMux is newTestMux with the notes in the database too, for the
+// handlers reading what is stored alongside them, such as links
+func newSQLiteTestMux(t *testing.T) (*http.ServeMux, notes_service.NotesService, *db_client.DBClient) {
+	t.Helper()
+	return newTestMuxWith(t, Config{}, func(dbClient *db_client.DBClient) notes_service.NotesService {
+		return notes_service.NewNotesServiceImpl(dbClient)
+	})
+}
+
+func newMemoryNotesService(*db_client.DBClient) notes_service.NotesService {
+	return notes_service.NewMemoryNotesService()
+}
+
+// newTestMuxWith is newTestMux with the notes service newNotesService makes
+// and the settings of config, apart from the token and origins
+func newTestMuxWith(t *testing.T, config Config, newNotesService func(*db_client.DBClient) notes_service.NotesService) (*http.ServeMux, notes_service.NotesService, *db_client.DBClient) {
+	t.Helper()
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbClient.Close(ctx) })
+
+	notesService := newNotesService(dbClient)
+	// Never started, so it reports the model as not ready
+	chatService := &lm_service.ChatServiceImpl{}
+	analysisService := analysis_service.NewService(notesService, chatService, dbClient)
+	backups, err := backup.NewManager(t.TempDir(), dbClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.APIToken = testToken
+	config.AllowedOrigins = defaultAllowedOrigins
+	mux := http.NewServeMux()
+	registerRoutes(mux, routeDeps{
+		config:           config,
+		notesService:     notesService,
+		checkInService:   notes_service.NewCheckInService(dbClient),
+		statsService:     notes_service.NewStatsService(dbClient),
+		linkService:      notes_service.NewLinkService(dbClient),
+		templateService:  notes_service.NewTemplateService(dbClient),
+		chatService:      chatService,
+		clarityService:   clarity_service.NewService(notesService, chatService, chatService.Model, dbClient),
+		analysisService:  analysisService,
+		taskService:      task_service.NewService(notesService, chatService, dbClient),
+		entityService:    entity_service.NewService(notesService, chatService, dbClient),
+		titleService:     title_service.NewService(notesService, chatService, dbClient),
+		digestService:    digest_service.NewService(notesService, chatService, dbClient),
+		resurfaceService: resurface_service.NewService(notesService, analysisService, chatService),
+		promptService:    prompt_service.NewService(notesService, chatService, dbClient),
+		backups:          backups,
+	})
+	return mux, notesService, dbClient
+}
+
+// serve sends the request to mux, checks the response against openapi.json
+// and that it has the wanted status
+func serve(t *testing.T, mux *http.ServeMux, method string, path string, body string, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	route, _, _ := strings.Cut(path, "?")
+	checkResponse(t, method, route, rec)
+	if rec.Code != want {
+		t.Fatalf("%s %s: got status %d, want %d: %s", method, path, rec.Code, want, rec.Body.String())
+	}
+	return rec
+}
+
+func TestProtectHandler(t *testing.T) {
+	config := Config{APIToken: "secret", AllowedOrigins: defaultAllowedOrigins}
+	handler := protectHandler(config, helloHandler)
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		authorization string
+		want          int
+	}{
+		{"token", http.MethodGet, "", "Bearer secret", http.StatusOK},
+		{"token from the shell", http.MethodGet, "tauri://localhost", "Bearer secret", http.StatusOK},
+		{"missing token", http.MethodGet, "tauri://localhost", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "tauri://localhost", "Bearer guess", http.StatusUnauthorized},
+		{"token without the scheme", http.MethodGet, "", "secret", http.StatusUnauthorized},
+		{"disallowed origin", http.MethodGet, "https://example.com", "Bearer secret", http.StatusForbidden},
+		{"preflight", http.MethodOptions, "http://localhost:1420", "", http.StatusOK},
+		{"preflight from a disallowed origin", http.MethodOptions, "https://example.com", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/hello", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != test.want {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, test.want, rec.Body.String())
+			}
+			allowed := rec.Header().Get("Access-Control-Allow-Origin")
+			if test.want == http.StatusForbidden && allowed != "" {
+				t.Errorf("a disallowed origin got Access-Control-Allow-Origin %q", allowed)
+			}
+			if test.want != http.StatusForbidden && allowed != test.origin {
+				t.Errorf("got Access-Control-Allow-Origin %q, want %q", allowed, test.origin)
+			}
+			if test.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("got WWW-Authenticate %q, want Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+			if test.method == http.MethodOptions && test.want == http.StatusOK &&
+				!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+				t.Errorf("the preflight does not allow the Authorization header: %q", rec.Header().Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+func TestNoteHandlers(t *testing.T) {
+	mux, _, _ := newTestMux(t)
+
+	var note notes_service.Note
+	rec := serve(t, mux, http.MethodGet, "/createnote", "", http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil {
+		t.Fatal(err)
+	}
+	noteBody := `{"NoteId":"` + note.NoteId.String() + `"}`
+
+	serve(t, mux, http.MethodPost, "/updatenote", `{"NoteId":"`+note.NoteId.String()+`","Title":"Walk","Content":"Went out with [[Sam]]","Tags":["outside"]}`, http.StatusOK)
+	rec = serve(t, mux, http.MethodPost, "/getnote", noteBody, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Title != "Walk" {
+		t.Errorf("got title %q after the update, want %q", note.Title, "Walk")
+	}
+	serve(t, mux, http.MethodGet, "/getallnotes", "", http.StatusOK)
+
+	serve(t, mux, http.MethodPost, "/checkin", `{"NoteId":"`+note.NoteId.String()+`","CheckIn":{"Mood":4,"SleepHours":7.5}}`, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/checkin", `{"NoteId":"`+note.NoteId.String()+`","CheckIn":{"Mood":40}}`, http.StatusBadRequest)
+
+	serve(t, mux, http.MethodPost, "/tasks/note", noteBody, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/analysis/note", noteBody, http.StatusNotFound)
+
+	serve(t, mux, http.MethodPost, "/deletenote", noteBody, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/getnote", noteBody, http.StatusNotFound)
+}
+
+func TestLinkHandlers(t *testing.T) {
+	mux, notesService, _ := newSQLiteTestMux(t)
+	ctx := context.Background()
+	sam, err := notesService.CreateNoteWithContent(ctx, "Sam", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk, err := notesService.CreateNoteWithContent(ctx, "Walk", "Went out with [[Sam]] and [[Nobody]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walkBody := `{"NoteId":"` + walk.NoteId.String() + `"}`
+
+	var links []notes_service.Link
+	rec := serve(t, mux, http.MethodPost, "/links/note", walkBody, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &links); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 || links[0].TargetNoteId == nil || *links[0].TargetNoteId != sam.NoteId || links[1].TargetNoteId != nil {
+		t.Errorf("got links %+v, want one to Sam and one unresolved", links)
+	}
+	serve(t, mux, http.MethodPost, "/links/backlinks", `{"NoteId":"`+sam.NoteId.String()+`"}`, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/links/unresolved", walkBody, http.StatusOK)
+
+	// Renaming Sam rewrites the walk, which the response names for reloading
+	var updated notes_service.UpdateNoteResponseDto
+	rec = serve(t, mux, http.MethodPost, "/updatenote", `{"NoteId":"`+sam.NoteId.String()+`","Title":"Samuel","Content":""}`, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(updated.RewrittenNoteIds, []uuid.UUID{walk.NoteId}) {
+		t.Errorf("got rewritten notes %v, want only %v", updated.RewrittenNoteIds, walk.NoteId)
+	}
+	rec = serve(t, mux, http.MethodPost, "/updatenote", `{"NoteId":"`+walk.NoteId.String()+`","Title":"Walk","Content":"Went out with [[Samuel]]"}`, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil || len(updated.RewrittenNoteIds) > 0 {
+		t.Errorf("got rewritten notes %v without a rename: %v", updated.RewrittenNoteIds, err)
+	}
+
+	serve(t, mux, http.MethodPost, "/deletenote", walkBody, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/links/note", walkBody, http.StatusNotFound)
+}
+
+func TestHandlersRejectBadRequests(t *testing.T) {
+	mux, _, _ := newTestMux(t)
+	missing := `{"NoteId":"` + uuid.NewString() + `"}`
+
+	serve(t, mux, http.MethodGet, "/getnote", "", http.StatusMethodNotAllowed)
+	serve(t, mux, http.MethodPost, "/getnote", `{"NoteId":"not a uuid"}`, http.StatusBadRequest)
+	serve(t, mux, http.MethodPost, "/getnote", missing, http.StatusNotFound)
+	serve(t, mux, http.MethodPost, "/updatenote", `{"NoteId":"not a uuid"}`, http.StatusBadRequest)
+	serve(t, mux, http.MethodGet, "/deletenote", "", http.StatusMethodNotAllowed)
+	serve(t, mux, http.MethodPost, "/checkin", `{"NoteId":"`+uuid.NewString()+`","CheckIn":{}}`, http.StatusNotFound)
+	serve(t, mux, http.MethodPost, "/links/backlinks", missing, http.StatusNotFound)
+	serve(t, mux, http.MethodGet, "/tasks?status=unknown", "", http.StatusBadRequest)
+	serve(t, mux, http.MethodPost, "/tasks/status", `{"TaskId":"`+uuid.NewString()+`","Status":"accepted"}`, http.StatusNotFound)
+	serve(t, mux, http.MethodGet, "/entities?kind=animal", "", http.StatusBadRequest)
+	serve(t, mux, http.MethodPost, "/entities/mentions", `{"Name":"Nobody"}`, http.StatusNotFound)
+	serve(t, mux, http.MethodPost, "/digest", `{"DigestId":"`+uuid.NewString()+`"}`, http.StatusNotFound)
+	serve(t, mux, http.MethodPost, "/stats/activity", `{"From":"2024-02-01","To":"2024-01-01"}`, http.StatusBadRequest)
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	mux, _, _ := newTestMux(t)
+
+	var template notes_service.Template
+	rec := serve(t, mux, http.MethodPost, "/templates", `{"Name":"Morning","Title":"Morning {{date}}","Content":"Today I will"}`, http.StatusOK)
+	if err := json.Unmarshal(rec.Body.Bytes(), &template); err != nil {
+		t.Fatal(err)
+	}
+	templateBody := `{"TemplateId":"` + template.TemplateId.String() + `"}`
+
+	serve(t, mux, http.MethodGet, "/templates", "", http.StatusOK)
+	serve(t, mux, http.MethodPost, "/createnote/template", templateBody, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/templates/delete", templateBody, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/createnote/template", templateBody, http.StatusNotFound)
+}
+
+func TestSummaryHandlers(t *testing.T) {
+	mux, notesService, _ := newTestMux(t)
+	if _, err := notesService.CreateNoteWithContent(context.Background(), "First", "Some words to count"); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().Format(time.DateOnly)
+	series := `{"From":"` + time.Now().AddDate(0, 0, -7).Format(time.DateOnly) + `","To":"` + today + `"}`
+
+	serve(t, mux, http.MethodGet, "/hello", "", http.StatusOK)
+	serve(t, mux, http.MethodGet, "/openapi.json", "", http.StatusOK)
+	serve(t, mux, http.MethodGet, "/stats", "", http.StatusOK)
+	serve(t, mux, http.MethodPost, "/stats/activity", series, http.StatusOK)
+	serve(t, mux, http.MethodGet, "/checkin/fields", "", http.StatusOK)
+	serve(t, mux, http.MethodPost, "/checkin/fields", `{"Name":"steps","Type":"number","Min":0}`, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/checkin/series", series, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/checkin/fields/delete", `{"Name":"steps"}`, http.StatusOK)
+	serve(t, mux, http.MethodPost, "/analysis/emotions", series, http.StatusOK)
+	serve(t, mux, http.MethodGet, "/analysis/status", "", http.StatusOK)
+	serve(t, mux, http.MethodGet, "/tasks", "", http.StatusOK)
+	serve(t, mux, http.MethodGet, "/entities?kind=person", "", http.StatusOK)
+	serve(t, mux, http.MethodGet, "/digests", "", http.StatusOK)
+}
+
+func TestExportHTMLIncludesReflections(t *testing.T) {
+	mux, notesService, dbClient := newTestMux(t)
+	ctx := context.Background()
+	if _, err := notesService.CreateNoteWithContent(ctx, "First", "Some words"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dbClient.ExecContext(ctx, "INSERT INTO digests (id, kind, period_start, period_end, content, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		uuid.New(), digest_service.KindWeekly, "2024-03-04", "2024-03-10", "A calm week", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"IncludeReflections":true}`, true},
+		{`{"IncludeReflections":true,"From":"2024-03-08T00:00:00Z","To":"2024-04-01T00:00:00Z"}`, true},
+		{`{"IncludeReflections":true,"From":"2024-04-01T00:00:00Z"}`, false},
+		{`{}`, false},
+	}
+	for _, test := range tests {
+		rec := serve(t, mux, http.MethodPost, "/export/html", test.body, http.StatusOK)
+		site, err := zip.NewReader(strings.NewReader(rec.Body.String()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := site.Open("reflections.html")
+		if test.want != (err == nil) {
+			t.Errorf("%s: got reflections page %v, want %v", test.body, err == nil, test.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var content strings.Builder
+		if _, err := io.Copy(&content, page); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(content.String(), "A calm week") {
+			t.Errorf("%s: the reflections page is missing the digest", test.body)
+		}
+	}
+}
+
+func TestExportMarkdownStaysInExportDir(t *testing.T) {
+	exportDir := t.TempDir()
+	mux, notesService, _ := newTestMuxWith(t, Config{ExportDir: exportDir}, newMemoryNotesService)
+	if _, err := notesService.CreateNoteWithContent(context.Background(), "First", "Some words"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"../escaped", "/tmp/escaped", "a/../../escaped"} {
+		serve(t, mux, http.MethodPost, "/export/markdown", `{"Directory":"`+dir+`"}`, http.StatusBadRequest)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(exportDir), "escaped")); !os.IsNotExist(err) {
+		t.Errorf("the export left the export folder: %v", err)
+	}
+
+	rec := serve(t, mux, http.MethodPost, "/export/markdown", `{"Directory":"journal"}`, http.StatusOK)
+	var result ExportMarkdownResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(exportDir, "journal"); result.Exported != 1 || result.Directory != want {
+		t.Errorf("got %+v, want 1 note in %s", result, want)
+	}
+	files, err := os.ReadDir(result.Directory)
+	if err != nil || len(files) != 1 {
+		t.Errorf("got %d files in the export folder: %v", len(files), err)
+	}
+
+	// Without an export folder the notes can only be downloaded
+	mux, notesService, _ = newTestMux(t)
+	if _, err := notesService.CreateNoteWithContent(context.Background(), "First", "Some words"); err != nil {
+		t.Fatal(err)
+	}
+	serve(t, mux, http.MethodPost, "/export/markdown", `{"Directory":"journal"}`, http.StatusBadRequest)
+	rec = serve(t, mux, http.MethodPost, "/export/markdown", `{}`, http.StatusOK)
+	if rec.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("got Content-Type %q without a Directory, want a zip", rec.Header().Get("Content-Type"))
+	}
+}
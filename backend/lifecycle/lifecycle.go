@@ -0,0 +1,121 @@
+/*
+The lifecycle package owns starting and stopping the backend. It traps
+SIGINT/SIGTERM, then runs the registered shutdown steps in order with a shared
+deadline, and reports an exit code describing how the shutdown went.
+*/
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Exit codes returned by Wait
+const (
+	// Shutdown was requested and every step completed
+	ExitOK = 0
+	// A component failed while running (e.g. the HTTP server stopped serving)
+	ExitRuntimeError = 1
+	// Shutdown was requested but a step failed or the deadline was exceeded
+	ExitShutdownError = 2
+)
+
+type shutdownStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type Lifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+
+	mu       sync.Mutex
+	steps    []shutdownStep
+	fatalErr error
+}
+
+// New creates a lifecycle that begins shutting down when the process receives
+// SIGINT or SIGTERM. Shutdown steps share a deadline of timeout.
+func New(timeout time.Duration) *Lifecycle {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return &Lifecycle{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
+	}
+}
+
+// Context is cancelled once shutdown begins. Background work should stop when
+// it is done.
+func (lifecycle *Lifecycle) Context() context.Context {
+	return lifecycle.ctx
+}
+
+// OnShutdown registers a step to run during shutdown. Steps run in the order
+// they were registered.
+func (lifecycle *Lifecycle) OnShutdown(name string, fn func(ctx context.Context) error) {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+	lifecycle.steps = append(lifecycle.steps, shutdownStep{name: name, fn: fn})
+}
+
+// Fail begins shutdown because a component stopped unexpectedly
+func (lifecycle *Lifecycle) Fail(err error) {
+	lifecycle.mu.Lock()
+	if lifecycle.fatalErr == nil {
+		lifecycle.fatalErr = err
+	}
+	lifecycle.mu.Unlock()
+	lifecycle.cancel()
+}
+
+// Wait blocks until shutdown begins, runs every shutdown step and returns the
+// exit code the process should use.
+func (lifecycle *Lifecycle) Wait() int {
+	<-lifecycle.ctx.Done()
+	lifecycle.cancel()
+
+	lifecycle.mu.Lock()
+	steps := lifecycle.steps
+	fatalErr := lifecycle.fatalErr
+	lifecycle.mu.Unlock()
+
+	if fatalErr != nil {
+		log.Printf("Shutting down after error: %v", fatalErr)
+	} else {
+		log.Println("Shutdown requested")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lifecycle.timeout)
+	defer cancel()
+
+	failed := false
+	for _, step := range steps {
+		log.Printf("Shutdown: %s", step.name)
+		if err := step.fn(ctx); err != nil {
+			log.Printf("Shutdown step %s failed: %v", step.name, err)
+			failed = true
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("Shutdown did not finish within %v", lifecycle.timeout)
+		failed = true
+	}
+
+	switch {
+	case fatalErr != nil:
+		return ExitRuntimeError
+	case failed:
+		return ExitShutdownError
+	default:
+		log.Println("Shutdown complete")
+		return ExitOK
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"backend/notes_service"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// noteLinksHandler lists the [[wiki links]] in a note, with the note each
+// resolves to
+func noteLinksHandler(w http.ResponseWriter, r *http.Request, linkService *notes_service.LinkService) {
+	handleLinksRequest(w, r, "links", func(ctx context.Context, noteId uuid.UUID) (any, error) {
+		return linkService.Links(ctx, noteId)
+	})
+}
+
+// backlinksHandler lists the links to a note from other notes
+func backlinksHandler(w http.ResponseWriter, r *http.Request, linkService *notes_service.LinkService) {
+	handleLinksRequest(w, r, "backlinks", func(ctx context.Context, noteId uuid.UUID) (any, error) {
+		return linkService.Backlinks(ctx, noteId)
+	})
+}
+
+// unresolvedLinksHandler lists the links in a note no note was found for
+func unresolvedLinksHandler(w http.ResponseWriter, r *http.Request, linkService *notes_service.LinkService) {
+	handleLinksRequest(w, r, "unresolved links", func(ctx context.Context, noteId uuid.UUID) (any, error) {
+		return linkService.Unresolved(ctx, noteId)
+	})
+}
+
+func handleLinksRequest(w http.ResponseWriter, r *http.Request, what string, load func(ctx context.Context, noteId uuid.UUID) (any, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noteId, err := uuid.Parse(req.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+
+	result, err := load(r.Context(), noteId)
+	if errors.Is(err, notes_service.ErrNoteNotFound) {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting %s of note %v: %v", what, noteId, err)
+		http.Error(w, "Failed to get "+what, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
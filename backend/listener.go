@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// Listen opens the listener the HTTP API is served on. When a socket path is
+// configured the API is served over a Unix domain socket that only the current
+// user can connect to, otherwise over TCP on the configured address.
+func Listen(config Config) (net.Listener, error) {
+	if config.SocketPath == "" {
+		return net.Listen("tcp", config.Addr)
+	}
+	return listenUnix(config.SocketPath)
+}
+
+func listenUnix(socketPath string) (net.Listener, error) {
+	// The socket is created with the default permissions and only tightened
+	// after, so it has to be in a directory nobody else can enter.
+	if err := privateDir(filepath.Dir(socketPath)); err != nil {
+		return nil, err
+	}
+
+	// A socket file left behind by a previous run would make the bind fail,
+	// but one a running backend answers on is not ours to take over
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", socketPath)
+		}
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another backend is already serving on %s", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// privateDir creates dir for only the current user, or checks that nobody
+// else can enter it when it already exists. Windows has no permission bits,
+// so there the directory's ACL is left to decide.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("socket directory %s can be entered by other users (mode %v), use one only you can access", dir, info.Mode().Perm())
+	}
+	return nil
+}
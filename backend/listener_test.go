@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	dir := filepath.Join(t.TempDir(), "athena")
+	socketPath := filepath.Join(dir, "api.sock")
+
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]os.FileMode{dir: 0700, socketPath: 0600} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Errorf("got %s with mode %v, want %v: %v", path, info.Mode().Perm(), want, err)
+		}
+	}
+
+	// The socket answers, so a second backend must not take it over
+	if second, err := listenUnix(socketPath); err == nil {
+		second.Close()
+		t.Fatal("listened on a socket another listener is serving")
+	}
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("the first listener lost its socket: %v", err)
+	}
+	conn.Close()
+
+	// A socket left behind by a backend that is gone is replaced
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if _, err := os.Lstat(socketPath); err != nil {
+		t.Fatal(err)
+	}
+	listener, err = listenUnix(socketPath)
+	if err != nil {
+		t.Fatalf("failed to replace a stale socket: %v", err)
+	}
+	listener.Close()
+}
+
+func TestListenUnixRefusesSharedDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not enforced on Windows")
+	}
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// Set after creating it, as the umask may have narrowed it
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := listenUnix(filepath.Join(dir, "api.sock")); err == nil {
+		listener.Close()
+		t.Fatal("listened in a directory other users can enter")
+	}
+
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(t.TempDir(), link); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := listenUnix(filepath.Join(link, "api.sock")); err == nil {
+		listener.Close()
+		t.Fatal("listened in a directory reached through a symlink")
+	}
+}
@@ -8,4 +8,7 @@ type ChatRequestDto struct {
 	Top_k          int     `json:"top_k"`
 	Top_p          float64 `json:"top_p"`
 	Repeat_penalty float64 `json:"repeat_penalty"`
+	// Constrains the output to JSON matching this schema. llama-server turns
+	// it into a grammar.
+	Json_schema any `json:"json_schema,omitempty"`
 }
@@ -9,7 +9,9 @@ package lm_service
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,47 +19,140 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type ChatService interface {
-	InitialiseChat(model string) error
-	BeginHealthCheck() error
+	InitialiseChat() error
+	BeginHealthCheck(ctx context.Context) error
 	Chat(sequence string) (string, error)
-	ChatStream(sequence string, callback func(chunk string)) error
+	ChatStream(ctx context.Context, sequence string, callback func(chunk string)) error
 	GetStatus() bool
-	GetClaritySummary(notes []string) (string, error)
-	GetClaritySummaryStream(notes []string, callback func(chunk string)) error
+	GetClaritySummary(ctx context.Context, entries []ClarityEntry) (string, error)
+	GetClaritySummaryStream(ctx context.Context, entries []ClarityEntry, callback func(chunk string)) error
+	AnalyseEntry(ctx context.Context, title string, content string) (EntryAnalysis, error)
+	GenerateTitle(ctx context.Context, content string) (string, error)
+	GenerateDigest(ctx context.Context, period string, entries []DigestEntry) (string, error)
+	ReflectThenAndNow(ctx context.Context, thenDate time.Time, then string, now string) (string, error)
+	GenerateJournalPrompts(ctx context.Context, entries []PromptEntry, count int) ([]string, error)
+	ExtractTasks(ctx context.Context, title string, content string) ([]ExtractedTask, error)
+	ExtractEntities(ctx context.Context, title string, content string) ([]ExtractedEntity, error)
+	Stop(ctx context.Context) error
 }
 
 type ChatServiceImpl struct {
-	Model  string
-	UseHf  bool
-	Status bool
+	Model string
+	UseHf bool
+
+	// Whether llama-server passed its last health check. Written by the
+	// health check and read by every request, so it is atomic.
+	status atomic.Bool
+
+	// Cancelled by Stop to end the health check and any in-flight generations
+	ctx    context.Context
+	cancel context.CancelFunc
+	cmd    *exec.Cmd
 }
 
-func (chatService *ChatServiceImpl) BeginHealthCheck() error {
+func (chatService *ChatServiceImpl) BeginHealthCheck(ctx context.Context) error {
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
-	for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
 		// Send a request to llama.cpp in here
 		log.Println("Checking health of llama-server")
-		resp, err := http.Get("http://127.0.0.1:8029/health")
-
-		if resp.StatusCode != 200 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:8029/health", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != 200 {
 			log.Println("Llama-server health check FAIL")
 			log.Println(err)
-			chatService.Status = false
+			chatService.status.Store(false)
 		} else {
 			log.Println("Llama-server health check SUCCESS")
-			chatService.Status = true
+			chatService.status.Store(true)
+		}
+		if resp != nil {
+			resp.Body.Close()
 		}
 	}
+}
 
-	return nil
+// Stop cancels in-flight generations, stops the health check and shuts down
+// the llama-server subprocess, killing it if it has not exited by the time ctx
+// is done.
+func (chatService *ChatServiceImpl) Stop(ctx context.Context) error {
+	if chatService.cancel != nil {
+		chatService.cancel()
+	}
+	if chatService.cmd == nil || chatService.cmd.Process == nil {
+		return nil
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- chatService.cmd.Wait()
+	}()
+
+	// Interrupt is not supported on Windows, in which case we go straight to Kill
+	if err := chatService.cmd.Process.Signal(os.Interrupt); err != nil {
+		chatService.cmd.Process.Kill()
+	}
+
+	select {
+	case <-exited:
+		log.Println("llama-server stopped")
+		return nil
+	case <-ctx.Done():
+		chatService.cmd.Process.Kill()
+		<-exited
+		return fmt.Errorf("llama-server did not exit in time and was killed")
+	}
+}
+
+// generationContext derives a context for a single completion request that is
+// cancelled when either the caller gives up or the service is stopped.
+func (chatService *ChatServiceImpl) generationContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	if chatService.ctx == nil {
+		return ctx, cancel
+	}
+	stop := context.AfterFunc(chatService.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// ErrInvalidOutput is wrapped by the errors returned when the model answers
+// but its output cannot be used, as opposed to the model being unreachable
+var ErrInvalidOutput = errors.New("invalid model output")
+
+// postCompletion sends a completion request to llama-server
+func (chatService *ChatServiceImpl) postCompletion(ctx context.Context, chatRequestDto ChatRequestDto) (*http.Response, error) {
+	jsonData, err := json.Marshal(chatRequestDto)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://127.0.0.1:8029/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
 }
 
 func (chatService *ChatServiceImpl) InitialiseChat() error {
+	chatService.ctx, chatService.cancel = context.WithCancel(context.Background())
+
 	// For now, we're spawning llama.cpp as if it existed on
 	// the system and no prebuilt binaries are included. Note the way
 	// we are doing it is not ideal and we should probably package
@@ -151,8 +246,9 @@ func (chatService *ChatServiceImpl) InitialiseChat() error {
 		}
 
 		log.Printf("Successfully started llama-server from: %s", successfulPath)
+		chatService.cmd = cmd
 
-		go chatService.BeginHealthCheck()
+		go chatService.BeginHealthCheck(chatService.ctx)
 	} else {
 		chatService.Model = "llama3.1-8b-instant"
 	}
@@ -168,7 +264,7 @@ func CreateIndividualEntrySequence(prompt string) string {
 	return fmt.Sprintf("<start_of_turn>user\nYou are a thoughtful and supportive assistant designed to help me gain deeper insights from my individual journal entries. I will provide a single personal reflection, and your task is to help me explore and understand it more deeply.\n\nYour response should:\n- Help me identify the underlying emotions and thoughts in this entry\n- Point out any patterns or themes that emerge from this reflection\n- Offer gentle, constructive perspectives that might help me see things differently\n- Suggest questions I could ask myself to explore this topic further\n- Highlight any signs of self-awareness or growth in this entry\n\nRefer to the input as \"your journal entry\" rather than \"the text.\"\nYou are not in a conversation, so DO NOT ask follow-up questions or request additional information. Respond in a calm, polite, and respectful tone. Use plain text only — no markdown formatting.\n\nHere is the journal entry to reflect on:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", prompt)
 }
 
-func (chatService *ChatServiceImpl) ChatStream(sequence string, callback func(chunk string)) error {
+func (chatService *ChatServiceImpl) ChatStream(ctx context.Context, sequence string, callback func(chunk string)) error {
 	// Determine if this is a single entry or multiple entries by checking for newlines
 	// If there are multiple newlines, it's likely multiple entries, otherwise treat as single
 	isMultipleEntries := false
@@ -200,16 +296,11 @@ func (chatService *ChatServiceImpl) ChatStream(sequence string, callback func(ch
 		Repeat_penalty: 1.0,
 	}
 
-	jsonData, err := json.Marshal(chatRequestDto)
-	if err != nil {
-		log.Println("Error marshalling chat request")
-		log.Println(err)
-		return err
-	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
 
 	log.Println("Sending chat request to llama-server")
-	log.Println(string(jsonData))
-	resp, err := http.Post("http://127.0.0.1:8029/completions", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
 	if err != nil {
 		log.Println("Error sending chat request to llama-server")
 		log.Println(err)
@@ -237,15 +328,13 @@ func (chatService *ChatServiceImpl) ChatStream(sequence string, callback func(ch
 }
 
 func (chatService *ChatServiceImpl) GetStatus() bool {
-	return chatService.Status
+	return chatService.status.Load()
 }
 
-func (chatService *ChatServiceImpl) GetClaritySummary(notes []string) (string, error) {
-	joinedNotes := ""
-	for _, note := range notes {
-		joinedNotes += note + "\n"
-	}
-	prompt := CreateClaritySequence(joinedNotes)
+// GetClaritySummary writes a summary of the entries, labelled so the
+// summary cites them, see ResolveCitations
+func (chatService *ChatServiceImpl) GetClaritySummary(ctx context.Context, entries []ClarityEntry) (string, error) {
+	prompt := CreateCitedClaritySequence(formatClarityEntries(entries))
 	chatRequestDto := ChatRequestDto{
 		Prompt:         prompt,
 		N_predict:      512,
@@ -255,11 +344,9 @@ func (chatService *ChatServiceImpl) GetClaritySummary(notes []string) (string, e
 		Top_p:          0.95,
 		Repeat_penalty: 1.0,
 	}
-	jsonData, err := json.Marshal(chatRequestDto)
-	if err != nil {
-		return "", err
-	}
-	resp, err := http.Post("http://127.0.0.1:8029/completions", "application/json", bytes.NewBuffer(jsonData))
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
 	if err != nil {
 		return "", err
 	}
@@ -273,12 +360,10 @@ func (chatService *ChatServiceImpl) GetClaritySummary(notes []string) (string, e
 	return result.Content, nil
 }
 
-func (chatService *ChatServiceImpl) GetClaritySummaryStream(notes []string, callback func(chunk string)) error {
-	joinedNotes := ""
-	for _, note := range notes {
-		joinedNotes += note + "\n"
-	}
-	prompt := CreateClaritySequence(joinedNotes)
+// GetClaritySummaryStream is GetClaritySummary streamed, calling callback with
+// every line of the completion stream
+func (chatService *ChatServiceImpl) GetClaritySummaryStream(ctx context.Context, entries []ClarityEntry, callback func(chunk string)) error {
+	prompt := CreateCitedClaritySequence(formatClarityEntries(entries))
 	chatRequestDto := ChatRequestDto{
 		Prompt:         prompt,
 		N_predict:      512,
@@ -288,11 +373,9 @@ func (chatService *ChatServiceImpl) GetClaritySummaryStream(notes []string, call
 		Top_p:          0.95,
 		Repeat_penalty: 1.0,
 	}
-	jsonData, err := json.Marshal(chatRequestDto)
-	if err != nil {
-		return err
-	}
-	resp, err := http.Post("http://127.0.0.1:8029/completions", "application/json", bytes.NewBuffer(jsonData))
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,72 @@
+package lm_service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClarityPromptVersion is bumped whenever the clarity prompt or its sampling
+// changes, so stored clarity reports are written again
+const ClarityPromptVersion = 2
+
+// ClarityEntry is one journal entry given to the model for a clarity report
+type ClarityEntry struct {
+	CreatedAt time.Time
+	Title     string
+	Content   string
+}
+
+// ClarityRef is the label the entry at index is given in the prompt and cited
+// by, e.g. "E1" for the first
+func ClarityRef(index int) string {
+	return "E" + strconv.Itoa(index+1)
+}
+
+func CreateCitedClaritySequence(entries string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou are a thoughtful and supportive assistant designed to bring clarity and insight to my journal entries. I will provide a series of personal reflections, each starting with a label in square brackets such as [E1], followed by its date and title. Your task is to synthesize them into a single, meaningful response.\n\nYour response should:\n- Highlight recurring themes or emotional patterns in my journal entries\n- Offer constructive, empathetic advice where appropriate\n- Point out signs of personal growth or reflection\n- Suggest thoughtful next steps or perspectives to consider\n\nWhenever you mention a theme, feeling or event, cite the entries it comes from by their labels in square brackets right after it, for example \"you have been worried about deadlines [E2, E5]\". Only cite labels that appear below.\n\nRefer to the input as \"your journal entries\" rather than \"the text.\"\nYou are not in a conversation, so DO NOT ask follow-up questions or request additional information. Respond in a calm, polite, and respectful tone. Use plain text only — no markdown formatting.\n\nHere are the journal entries to analyze:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", entries)
+}
+
+func formatClarityEntries(entries []ClarityEntry) string {
+	var formatted strings.Builder
+	for i, entry := range entries {
+		fmt.Fprintf(&formatted, "[%s] %s — %s\n%s\n\n", ClarityRef(i), entry.CreatedAt.Format("Monday 2 January 2006"), entry.Title, strings.TrimSpace(entry.Content))
+	}
+	return formatted.String()
+}
+
+// A citation of one or more entries, e.g. [E2] or [E2, E5]
+var clarityCitation = regexp.MustCompile(`\s*\[(E\d+(?:\s*,\s*E\d+)*)\]`)
+
+// ResolveCitations checks the citations in a clarity report written from
+// count entries. It returns the report with citations of entries that do not
+// exist dropped and the rest written the way the prompt labels them, so
+// [E01] becomes [E1], and the indexes of the entries cited, in order of first
+// citation.
+func ResolveCitations(content string, count int) (string, []int) {
+	cited := []int{}
+	seen := map[int]bool{}
+	resolved := clarityCitation.ReplaceAllStringFunc(content, func(citation string) string {
+		leading := citation[:len(citation)-len(strings.TrimLeft(citation, " \t\n"))]
+		refs := []string{}
+		for _, ref := range strings.Split(strings.Trim(strings.TrimSpace(citation), "[]"), ",") {
+			ref = strings.TrimSpace(ref)
+			index, err := strconv.Atoi(strings.TrimPrefix(ref, "E"))
+			if err != nil || index < 1 || index > count {
+				continue
+			}
+			refs = append(refs, ClarityRef(index-1))
+			if !seen[index-1] {
+				seen[index-1] = true
+				cited = append(cited, index-1)
+			}
+		}
+		if len(refs) == 0 {
+			return ""
+		}
+		return leading + "[" + strings.Join(refs, ", ") + "]"
+	})
+	return resolved, cited
+}
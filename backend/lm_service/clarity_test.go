@@ -0,0 +1,36 @@
+package lm_service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveCitations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		cited   []int
+	}{
+		{"single", "You felt rested [E1].", "You felt rested [E1].", []int{0}},
+		{"list", "Deadlines worried you [E2, E3].", "Deadlines worried you [E2, E3].", []int{1, 2}},
+		{"order of first citation", "Work [E3]. Sleep [E1, E3].", "Work [E3]. Sleep [E1, E3].", []int{2, 0}},
+		{"out of range", "Something [E0] and [E99].", "Something and.", []int{}},
+		{"mixed list", "Walks helped [E1, E42].", "Walks helped [E1].", []int{0}},
+		{"mixed list spacing", "Walks helped [E42 ,E2 , E1].", "Walks helped [E2, E1].", []int{1, 0}},
+		{"leading zeros", "You slept well [E01, E003].", "You slept well [E1, E3].", []int{0, 2}},
+		{"not a citation", "See [note] and [E] and [e1].", "See [note] and [E] and [e1].", []int{}},
+		{"none", "No citations at all.", "No citations at all.", []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, cited := ResolveCitations(test.content, 3)
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if !slices.Equal(cited, test.cited) {
+				t.Errorf("got cited %v, want %v", cited, test.cited)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DigestEntry is one journal entry given to the model for a digest
+type DigestEntry struct {
+	CreatedAt time.Time
+	Title     string
+	Content   string
+}
+
+const (
+	// Long entries are cut so a month of entries fits the model's context
+	maxDigestEntryLength = 1200
+	maxDigestPromptInput = 12000
+)
+
+func CreateDigestSequence(period string, entries string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou are a thoughtful and supportive assistant writing a digest of my journal for %s. I will provide my journal entries from that period, each starting with its date and title.\n\nYour digest should:\n- Summarise what happened and what was on my mind over the period\n- Highlight recurring themes, emotional patterns and how they changed over the period\n- Point out signs of personal growth\n- Suggest one or two things to carry into the next period\n\nRefer to the input as \"your journal entries\" rather than \"the text.\" Write in the second person, in a calm, warm tone. You are not in a conversation, so DO NOT ask follow-up questions. Use plain text only — no markdown formatting.\n\nHere are the journal entries:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", period, entries)
+}
+
+// GenerateDigest writes a digest of the entries of a period, described to the
+// model as e.g. "the week of 4 March 2024"
+func (chatService *ChatServiceImpl) GenerateDigest(ctx context.Context, period string, entries []DigestEntry) (string, error) {
+	var input strings.Builder
+	for _, entry := range entries {
+		text := fmt.Sprintf("[%s] %s\n%s\n\n", entry.CreatedAt.Format("Monday 2 January 2006"), entry.Title, truncateEntry(entry.Content, maxDigestEntryLength))
+		if input.Len()+len(text) > maxDigestPromptInput {
+			break
+		}
+		input.WriteString(text)
+	}
+
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateDigestSequence(period, input.String()),
+		N_predict:      768,
+		Stream:         false,
+		Temperature:    0.8,
+		Top_k:          64,
+		Top_p:          0.95,
+		Repeat_penalty: 1.0,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Content), nil
+}
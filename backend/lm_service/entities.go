@@ -0,0 +1,110 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	EntityPerson = "person"
+	EntityPlace  = "place"
+)
+
+// ExtractedEntity is a person or place the model found in an entry
+type ExtractedEntity struct {
+	// As written in the entry, e.g. "Alex" or "the Royal Free"
+	Name string `json:"name"`
+	// EntityPerson or EntityPlace
+	Kind string `json:"kind"`
+	// How the writer feels about them in this entry, from -1 (very
+	// negatively) to 1 (very positively)
+	Sentiment float64 `json:"sentiment"`
+}
+
+var ErrInvalidEntities = fmt.Errorf("%w: extracted entities", ErrInvalidOutput)
+
+const (
+	maxExtractedEntities = 15
+	maxEntityNameLength  = 60
+	// Only the start of long entries is read for people and places
+	maxEntityPromptLength = 6000
+)
+
+var extractedEntitiesSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"entities": map[string]any{
+			"type":     "array",
+			"maxItems": maxExtractedEntities,
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":      map[string]any{"type": "string", "minLength": 1, "maxLength": maxEntityNameLength},
+					"kind":      map[string]any{"type": "string", "enum": []string{EntityPerson, EntityPlace}},
+					"sentiment": map[string]any{"type": "number", "minimum": -1, "maximum": 1},
+				},
+				"required": []string{"name", "kind", "sentiment"},
+			},
+		},
+	},
+	"required": []string{"entities"},
+}
+
+func CreateEntityExtractionSequence(title string, content string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou find the people and places mentioned in a personal journal entry. Reply with JSON only.\n\nList up to %d specific people and places the entry mentions, each once.\n- \"name\": the name as written in the entry, such as \"Alex\", \"Dr Patel\" or \"Brighton\". Include relations used as names, such as \"mum\" or \"my sister\", but leave out the writer and vague groups such as \"people\" or \"everyone\".\n- \"kind\": \"person\" or \"place\".\n- \"sentiment\": how the writer feels about them in this entry, from -1 (very negatively) to 1 (very positively), 0 being neutral.\n\nLeave the list empty if there are none.\n\nHere is the journal entry:\n%s\n\n%s\n<end_of_turn>\n<start_of_turn>assistant\n",
+		maxExtractedEntities, title, content)
+}
+
+// ExtractEntities asks the model for the people and places in an entry. The
+// output is constrained to JSON by a schema and cleaned up before it is
+// returned.
+func (chatService *ChatServiceImpl) ExtractEntities(ctx context.Context, title string, content string) ([]ExtractedEntity, error) {
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateEntityExtractionSequence(title, truncateEntry(content, maxEntityPromptLength)),
+		N_predict:      512,
+		Stream:         false,
+		Temperature:    0.2,
+		Top_k:          40,
+		Top_p:          0.9,
+		Repeat_penalty: 1.0,
+		Json_schema:    extractedEntitiesSchema,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var output struct {
+		Entities []ExtractedEntity `json:"entities"`
+	}
+	if err := json.Unmarshal([]byte(result.Content), &output); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEntities, err)
+	}
+
+	entities := []ExtractedEntity{}
+	for _, entity := range output.Entities {
+		entity.Name = strings.Join(strings.Fields(entity.Name), " ")
+		entity.Kind = strings.ToLower(strings.TrimSpace(entity.Kind))
+		if entity.Name == "" || entity.Kind != EntityPerson && entity.Kind != EntityPlace {
+			continue
+		}
+		entity.Sentiment = clamp(entity.Sentiment, -1, 1)
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
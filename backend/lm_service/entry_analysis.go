@@ -0,0 +1,137 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Emotions the model chooses from, so results can be compared over time
+var Emotions = []string{
+	"joy", "gratitude", "calm", "hope", "pride", "love",
+	"sadness", "loneliness", "anxiety", "fear", "anger", "frustration", "guilt", "stress",
+}
+
+// EmotionScore is one emotion present in an entry, with an intensity from 0
+// (barely present) to 1 (overwhelming)
+type EmotionScore struct {
+	Emotion   string  `json:"emotion"`
+	Intensity float64 `json:"intensity"`
+}
+
+// EntryAnalysis is the model's reading of a single journal entry
+type EntryAnalysis struct {
+	Emotions []EmotionScore `json:"emotions"`
+	// Overall tone from -1 (very negative) to 1 (very positive)
+	Valence float64 `json:"valence"`
+	// A few short, lower case topics, e.g. "work" or "sleep"
+	Topics []string `json:"topics"`
+}
+
+var ErrInvalidAnalysis = fmt.Errorf("%w: entry analysis", ErrInvalidOutput)
+
+const (
+	maxAnalysisEmotions = 5
+	maxAnalysisTopics   = 5
+)
+
+var entryAnalysisSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"emotions": map[string]any{
+			"type":     "array",
+			"maxItems": maxAnalysisEmotions,
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"emotion":   map[string]any{"type": "string", "enum": Emotions},
+					"intensity": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+				},
+				"required": []string{"emotion", "intensity"},
+			},
+		},
+		"valence": map[string]any{"type": "number", "minimum": -1, "maximum": 1},
+		"topics": map[string]any{
+			"type":     "array",
+			"maxItems": maxAnalysisTopics,
+			"items":    map[string]any{"type": "string", "maxLength": 40},
+		},
+	},
+	"required": []string{"emotions", "valence", "topics"},
+}
+
+func CreateEntryAnalysisSequence(title string, content string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou analyse the emotional content of a personal journal entry. Reply with JSON only.\n\n- \"emotions\": up to %d emotions clearly present in the entry, each chosen from: %s, with an intensity from 0 (barely present) to 1 (overwhelming). Leave it empty if no emotion is expressed.\n- \"valence\": the overall tone of the entry from -1 (very negative) to 1 (very positive), 0 being neutral.\n- \"topics\": up to %d short lower case topics the entry is about, such as \"work\", \"family\" or \"sleep\".\n\nHere is the journal entry:\n%s\n\n%s\n<end_of_turn>\n<start_of_turn>assistant\n",
+		maxAnalysisEmotions, strings.Join(Emotions, ", "), maxAnalysisTopics, title, content)
+}
+
+// AnalyseEntry asks the model for the emotions, valence and topics of an
+// entry. The output is constrained to JSON by a schema and cleaned up before
+// it is returned, as small models do not always respect the ranges.
+func (chatService *ChatServiceImpl) AnalyseEntry(ctx context.Context, title string, content string) (EntryAnalysis, error) {
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateEntryAnalysisSequence(title, content),
+		N_predict:      256,
+		Stream:         false,
+		Temperature:    0.2,
+		Top_k:          40,
+		Top_p:          0.9,
+		Repeat_penalty: 1.0,
+		Json_schema:    entryAnalysisSchema,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return EntryAnalysis{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return EntryAnalysis{}, fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return EntryAnalysis{}, err
+	}
+	var analysis EntryAnalysis
+	if err := json.Unmarshal([]byte(result.Content), &analysis); err != nil {
+		return EntryAnalysis{}, fmt.Errorf("%w: %v", ErrInvalidAnalysis, err)
+	}
+	return cleanAnalysis(analysis), nil
+}
+
+// cleanAnalysis clamps values into range, drops unknown or repeated emotions
+// and normalises topics
+func cleanAnalysis(analysis EntryAnalysis) EntryAnalysis {
+	clean := EntryAnalysis{
+		Emotions: []EmotionScore{},
+		Valence:  clamp(analysis.Valence, -1, 1),
+		Topics:   []string{},
+	}
+	seen := map[string]bool{}
+	for _, score := range analysis.Emotions {
+		emotion := strings.ToLower(strings.TrimSpace(score.Emotion))
+		if !slices.Contains(Emotions, emotion) || seen[emotion] || len(clean.Emotions) == maxAnalysisEmotions {
+			continue
+		}
+		seen[emotion] = true
+		clean.Emotions = append(clean.Emotions, EmotionScore{Emotion: emotion, Intensity: clamp(score.Intensity, 0, 1)})
+	}
+	for _, topic := range analysis.Topics {
+		topic = strings.ToLower(strings.TrimSpace(topic))
+		if topic != "" && !slices.Contains(clean.Topics, topic) && len(clean.Topics) < maxAnalysisTopics {
+			clean.Topics = append(clean.Topics, topic)
+		}
+	}
+	return clean
+}
+
+func clamp(value float64, low float64, high float64) float64 {
+	return max(low, min(high, value))
+}
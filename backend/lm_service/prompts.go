@@ -0,0 +1,110 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PromptEntry is one recent journal entry given to the model as context for
+// journaling prompts
+type PromptEntry struct {
+	CreatedAt time.Time
+	Title     string
+	Content   string
+}
+
+// ErrInvalidPrompts is returned when the model does not produce usable
+// prompts
+var ErrInvalidPrompts = errors.New("invalid journal prompts")
+
+const (
+	maxPromptLength = 200
+	// Recent entries are cut so a couple of weeks of them fit the context
+	maxPromptEntryLength = 800
+	maxPromptInput       = 6000
+)
+
+func journalPromptsSchema(count int) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompts": map[string]any{
+				"type":     "array",
+				"minItems": count,
+				"maxItems": count,
+				"items":    map[string]any{"type": "string", "minLength": 1, "maxLength": maxPromptLength},
+			},
+		},
+		"required": []string{"prompts"},
+	}
+}
+
+func CreateJournalPromptsSequence(count int, entries string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou are a thoughtful and supportive assistant helping me keep a journal. Below are my most recent journal entries, each starting with its date and title.\n\nWrite %d short journaling prompts for today, each a single open question of one sentence. Draw on the themes, people and feelings in my recent entries so the prompts feel personal, but vary them: include at least one that looks forward and one that invites gratitude or rest. Write in the second person, in a calm, warm tone. Do not quote my entries. Reply with JSON only.\n\nHere are my recent journal entries:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", count, entries)
+}
+
+// GenerateJournalPrompts writes count journaling prompts tailored to the
+// recent entries, given the most recent first
+func (chatService *ChatServiceImpl) GenerateJournalPrompts(ctx context.Context, entries []PromptEntry, count int) ([]string, error) {
+	var input strings.Builder
+	for _, entry := range entries {
+		text := fmt.Sprintf("[%s] %s\n%s\n\n", entry.CreatedAt.Format("Monday 2 January 2006"), entry.Title, truncateEntry(entry.Content, maxPromptEntryLength))
+		if input.Len()+len(text) > maxPromptInput {
+			break
+		}
+		input.WriteString(text)
+	}
+
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateJournalPromptsSequence(count, input.String()),
+		N_predict:      384,
+		Stream:         false,
+		Temperature:    0.9,
+		Top_k:          64,
+		Top_p:          0.95,
+		Repeat_penalty: 1.0,
+		Json_schema:    journalPromptsSchema(count),
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var output struct {
+		Prompts []string `json:"prompts"`
+	}
+	if err := json.Unmarshal([]byte(result.Content), &output); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrompts, err)
+	}
+
+	prompts := []string{}
+	for _, prompt := range output.Prompts {
+		prompt = strings.Join(strings.Fields(prompt), " ")
+		if prompt == "" || utf8.RuneCountInString(prompt) > maxPromptLength {
+			continue
+		}
+		prompts = append(prompts, prompt)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("%w: no prompt given", ErrInvalidPrompts)
+	}
+	return prompts, nil
+}
@@ -0,0 +1,58 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Entries are cut so both fit the model's context with room to answer
+const maxReflectionEntryLength = 2000
+
+func CreateThenAndNowSequence(thenDate string, then string, now string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou are a thoughtful and supportive assistant helping me reread my journal. Below is an older journal entry of mine from %s, followed by what I wrote today.\n\nIn two or three sentences, reflect on then versus now: what has changed, what has stayed the same, and any growth you notice. Refer to them as \"your entry from then\" and \"today's entry\". Write in the second person, in a calm, warm tone. You are not in a conversation, so DO NOT ask follow-up questions. Use plain text only — no markdown formatting.\n\nYour entry from then:\n%s\n\nToday's entry:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", thenDate, then, now)
+}
+
+// truncateEntry cuts content to at most length bytes, marking the cut
+func truncateEntry(content string, length int) string {
+	content = strings.TrimSpace(content)
+	if len(content) > length {
+		content = strings.ToValidUTF8(content[:length], "") + "…"
+	}
+	return content
+}
+
+// ReflectThenAndNow writes a short reflection comparing an older entry
+// written at thenDate with what was written today
+func (chatService *ChatServiceImpl) ReflectThenAndNow(ctx context.Context, thenDate time.Time, then string, now string) (string, error) {
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateThenAndNowSequence(thenDate.Format("2 January 2006"), truncateEntry(then, maxReflectionEntryLength), truncateEntry(now, maxReflectionEntryLength)),
+		N_predict:      192,
+		Stream:         false,
+		Temperature:    0.8,
+		Top_k:          64,
+		Top_p:          0.95,
+		Repeat_penalty: 1.0,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Content), nil
+}
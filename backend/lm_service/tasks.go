@@ -0,0 +1,99 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ExtractedTask is an action item the model found in an entry
+type ExtractedTask struct {
+	// The task as a short imperative, e.g. "Call mum"
+	Task string `json:"task"`
+	// The words of the entry the task was found in, copied exactly
+	Quote string `json:"quote"`
+}
+
+var ErrInvalidTasks = fmt.Errorf("%w: extracted tasks", ErrInvalidOutput)
+
+const (
+	maxExtractedTasks = 10
+	maxTaskLength     = 120
+	// Only the start of long entries is read for tasks
+	maxTaskPromptLength = 6000
+)
+
+var extractedTasksSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"tasks": map[string]any{
+			"type":     "array",
+			"maxItems": maxExtractedTasks,
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"task":  map[string]any{"type": "string", "minLength": 1, "maxLength": maxTaskLength},
+					"quote": map[string]any{"type": "string", "minLength": 1, "maxLength": 300},
+				},
+				"required": []string{"task", "quote"},
+			},
+		},
+	},
+	"required": []string{"tasks"},
+}
+
+func CreateTaskExtractionSequence(title string, content string) string {
+	return fmt.Sprintf("<start_of_turn>user\nYou find action items in a personal journal entry. Reply with JSON only.\n\nList up to %d things the writer says they need, want or plan to do themselves, such as \"I need to call mum\" or \"should follow up with Sam\". Leave out things already done, things other people will do, and vague wishes with no action. Leave the list empty if there are none.\n\n- \"task\": the action as a short imperative in the writer's words, such as \"Call mum\".\n- \"quote\": the words of the entry the action comes from, copied exactly, at most one sentence.\n\nHere is the journal entry:\n%s\n\n%s\n<end_of_turn>\n<start_of_turn>assistant\n",
+		maxExtractedTasks, title, content)
+}
+
+// ExtractTasks asks the model for the action items in an entry. The output
+// is constrained to JSON by a schema; tasks without a usable quote are kept,
+// leaving it to the caller to place them in the entry.
+func (chatService *ChatServiceImpl) ExtractTasks(ctx context.Context, title string, content string) ([]ExtractedTask, error) {
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateTaskExtractionSequence(title, truncateEntry(content, maxTaskPromptLength)),
+		N_predict:      512,
+		Stream:         false,
+		Temperature:    0.2,
+		Top_k:          40,
+		Top_p:          0.9,
+		Repeat_penalty: 1.0,
+		Json_schema:    extractedTasksSchema,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var output struct {
+		Tasks []ExtractedTask `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(result.Content), &output); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTasks, err)
+	}
+
+	tasks := []ExtractedTask{}
+	for _, task := range output.Tasks {
+		task.Task = strings.Join(strings.Fields(task.Task), " ")
+		task.Quote = strings.TrimSpace(task.Quote)
+		if task.Task != "" && len(tasks) < maxExtractedTasks {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
@@ -0,0 +1,93 @@
+package lm_service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidTitle is returned when the model does not produce a usable title
+var ErrInvalidTitle = errors.New("invalid title")
+
+const (
+	maxTitleLength = 60
+	// Only the start of long entries is shown to the model
+	maxTitlePromptLength = 4000
+)
+
+var titleSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"title": map[string]any{"type": "string", "minLength": 1, "maxLength": maxTitleLength},
+	},
+	"required": []string{"title"},
+}
+
+func CreateTitleSequence(content string) string {
+	return fmt.Sprintf("<start_of_turn>user\nWrite a short title for this personal journal entry, as its author would: at most eight words, no quotes, no trailing full stop and no emoji. Reply with JSON only, as {\"title\": \"...\"}.\n\nHere is the journal entry:\n%s\n<end_of_turn>\n<start_of_turn>assistant\n", content)
+}
+
+// GenerateTitle asks the model for a concise title for an entry
+func (chatService *ChatServiceImpl) GenerateTitle(ctx context.Context, content string) (string, error) {
+	if len(content) > maxTitlePromptLength {
+		content = strings.ToValidUTF8(content[:maxTitlePromptLength], "")
+	}
+	chatRequestDto := ChatRequestDto{
+		Prompt:         CreateTitleSequence(content),
+		N_predict:      64,
+		Stream:         false,
+		Temperature:    0.3,
+		Top_k:          40,
+		Top_p:          0.9,
+		Repeat_penalty: 1.0,
+		Json_schema:    titleSchema,
+	}
+	ctx, cancel := chatService.generationContext(ctx)
+	defer cancel()
+	resp, err := chatService.postCompletion(ctx, chatRequestDto)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llama-server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	var output struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal([]byte(result.Content), &output); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTitle, err)
+	}
+	title := cleanTitle(output.Title)
+	if title == "" {
+		return "", fmt.Errorf("%w: the model returned an empty title", ErrInvalidTitle)
+	}
+	return title, nil
+}
+
+// cleanTitle strips what small models tend to add around a title and cuts it
+// to length at a word boundary
+func cleanTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	title = strings.Trim(title, "\"'“”‘’*#` ")
+	title = strings.TrimSuffix(title, ".")
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		runes := []rune(title)[:maxTitleLength]
+		title = string(runes)
+		if i := strings.LastIndex(title, " "); i > 0 {
+			title = title[:i]
+		}
+	}
+	return strings.TrimSpace(title)
+}
@@ -1,30 +1,52 @@
 package main
 
 import (
+	"backend/analysis_service"
+	"backend/backup"
+	"backend/clarity_service"
+	"backend/db"
+	"backend/digest_service"
+	"backend/entity_service"
+	"backend/lifecycle"
 	"backend/lm_service"
 	"backend/notes_service"
-	"database/sql"
+	"backend/prompt_service"
+	"backend/resurface_service"
+	"backend/scheduler"
+	"backend/task_service"
+	"backend/title_service"
+	"backend/vault_sync"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"time"
+	"slices"
+	"strings"
 
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-// CORS middleware function
-func enableCORS(next http.HandlerFunc) http.HandlerFunc {
+// CORS middleware function. Only origins the desktop shell loads the frontend
+// from are reflected back, so arbitrary websites cannot read the journal.
+func enableCORS(allowedOrigins []string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*") // Allow all origins, or specify your frontend URL
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			if !slices.Contains(allowedOrigins, origin) {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			// Set CORS headers
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Add("Vary", "Origin")
+		}
 
 		// Handle preflight OPTIONS request
 		if r.Method == "OPTIONS" {
@@ -37,25 +59,37 @@ func enableCORS(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-func InitialiseDBClient(dbName string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database %s: %w", dbName, err)
+// Auth middleware function. Every request must carry the per-launch token as
+// "Authorization: Bearer <token>".
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
 	}
+}
 
-	return db, nil
+// protectHandler puts an endpoint behind the CORS and token checks. CORS runs
+// first so preflight requests, which never carry credentials, can succeed.
+func protectHandler(config Config, next http.HandlerFunc) http.HandlerFunc {
+	return enableCORS(config.AllowedOrigins, requireToken(config.APIToken, next))
 }
 
 func helloHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(w, "Hello, World!")
 }
 
-func createNote(w http.ResponseWriter, _ *http.Request, notesService *notes_service.NotesServiceImpl, db *sql.DB) {
-	newNote, err := notesService.CreateNote("Untitled Note", db)
+func createNote(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
+	newNote, err := notesService.CreateNote(r.Context(), notes_service.DefaultTitle)
 	if err != nil {
 		fmt.Fprintln(w, "Error creating note: ", err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(newNote)
 	if err != nil {
 		fmt.Fprintln(w, "Error encoding note: ", err)
@@ -64,14 +98,15 @@ func createNote(w http.ResponseWriter, _ *http.Request, notesService *notes_serv
 	log.Println("Note created: ", newNote.NoteId)
 }
 
-func getAllNotes(w http.ResponseWriter, r *http.Request, notesService *notes_service.NotesServiceImpl, db *sql.DB) {
+func getAllNotes(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
 	log.Println("/getallnotes request received")
-	notes, err := notesService.GetAllNotes(db)
+	notes, err := notesService.GetAllNotes(r.Context())
 	if err != nil {
 		fmt.Fprintln(w, "Error getting notes: ", err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(notes); err != nil {
 		fmt.Fprintln(w, "Error encoding notes: ", err)
 		return
@@ -86,8 +121,12 @@ type GetNoteRequest struct {
 	NoteId string `json:"NoteId"`
 }
 
-func getNote(w http.ResponseWriter, r *http.Request, notesService *notes_service.NotesServiceImpl, db *sql.DB) {
+func getNote(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
 	log.Println("/getnote request received")
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		fmt.Fprintln(w, "Error reading request body: ", err)
@@ -100,11 +139,21 @@ func getNote(w http.ResponseWriter, r *http.Request, notesService *notes_service
 		return
 	}
 	log.Println("Note ID: ", noteRequest.NoteId)
-	note, err := notesService.GetNote(uuid.MustParse(noteRequest.NoteId), db)
+	noteId, err := uuid.Parse(noteRequest.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+	note, err := notesService.GetNote(r.Context(), noteId)
+	if errors.Is(err, notes_service.ErrNoteNotFound) {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		fmt.Fprintln(w, "Error getting note: ", err)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(note)
 	if err != nil {
 		fmt.Fprintln(w, "Error encoding note: ", err)
@@ -112,7 +161,11 @@ func getNote(w http.ResponseWriter, r *http.Request, notesService *notes_service
 	}
 }
 
-func updateNote(w http.ResponseWriter, r *http.Request, notesService *notes_service.NotesServiceImpl, db *sql.DB) {
+func updateNote(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		fmt.Fprintln(w, "Error reading request body: ", err)
@@ -125,19 +178,34 @@ func updateNote(w http.ResponseWriter, r *http.Request, notesService *notes_serv
 		return
 	}
 
+	noteId, err := uuid.Parse(updateNoteRequest.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
 	note := notes_service.Note{
-		NoteId:    uuid.MustParse(updateNoteRequest.NoteId),
+		NoteId:    noteId,
 		Title:     updateNoteRequest.Title,
 		Content:   updateNoteRequest.Content,
 		CreatedAt: updateNoteRequest.CreatedAt,
 		UpdatedAt: updateNoteRequest.UpdatedAt,
+		Tags:      updateNoteRequest.Tags,
+	}
+	rewritten, err := notesService.UpdateNote(r.Context(), note)
+	if errors.Is(err, notes_service.ErrNoteNotFound) {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
 	}
-	err = notesService.UpdateNote(note, db)
 	if err != nil {
 		fmt.Fprintln(w, "Error updating note: ", err)
 		return
 	}
 	log.Println("Note updated: ", note.NoteId)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(notes_service.UpdateNoteResponseDto{RewrittenNoteIds: rewritten})
+	if err != nil {
+		fmt.Fprintln(w, "Error encoding response: ", err)
+	}
 }
 
 func chatStream(w http.ResponseWriter, r *http.Request, chatService *lm_service.ChatServiceImpl) {
@@ -158,7 +226,7 @@ func chatStream(w http.ResponseWriter, r *http.Request, chatService *lm_service.
 		fmt.Fprintln(w, "Error unmarshalling request body: ", err)
 		return
 	}
-	chatService.ChatStream(chatRequestDto.Prompt, func(chunk string) {
+	chatService.ChatStream(r.Context(), chatRequestDto.Prompt, func(chunk string) {
 		w.Write([]byte(chunk))
 		if flusher, ok := w.(http.Flusher); ok {
 			flusher.Flush()
@@ -166,84 +234,11 @@ func chatStream(w http.ResponseWriter, r *http.Request, chatService *lm_service.
 	})
 }
 
-type ClarityRequest struct {
-	Timeframe string `json:"timeframe"`
-}
-
 type ClarityResponse struct {
 	Summary string `json:"summary"`
 }
 
-func clarityStreamHandler(w http.ResponseWriter, r *http.Request, notesService *notes_service.NotesServiceImpl, chatService *lm_service.ChatServiceImpl, db *sql.DB) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
-
-	var req ClarityRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Clarity request received for timeframe: %s", req.Timeframe)
-
-	var duration time.Duration
-	switch req.Timeframe {
-	case "3days":
-		duration = 3 * 24 * time.Hour
-	case "2weeks":
-		duration = 14 * 24 * time.Hour
-	case "3months":
-		duration = 90 * 24 * time.Hour
-	default:
-		http.Error(w, "Invalid timeframe", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Calculated duration: %v", duration)
-
-	notes, err := notesService.GetNotesWithinTimeframe(db, duration)
-	if err != nil {
-		log.Printf("Error getting notes: %v", err)
-		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
-		return
-	}
-
-	log.Printf("Found %d notes within timeframe", len(notes))
-
-	var noteContents []string
-	for _, note := range notes {
-		noteContents = append(noteContents, note.Content)
-		log.Printf("Note: %s - %s", note.Title, note.CreatedAt)
-	}
-
-	if len(noteContents) == 0 {
-		log.Printf("No notes found for timeframe %s", req.Timeframe)
-		// Send a message indicating no notes were found
-		noNotesResponse := fmt.Sprintf("data: {\"content\":\"No journal entries found for the past %s. Try creating some notes first.\"}\n\n", req.Timeframe)
-		w.Write([]byte(noNotesResponse))
-		return
-	}
-
-	err = chatService.GetClaritySummaryStream(noteContents, func(chunk string) {
-		w.Write([]byte(chunk))
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
-		}
-	})
-	if err != nil {
-		log.Printf("Error in GetClaritySummaryStream: %v", err)
-		http.Error(w, "Failed to get clarity", http.StatusInternalServerError)
-		return
-	}
-}
-
-func deleteNote(w http.ResponseWriter, r *http.Request, notesService *notes_service.NotesServiceImpl, db *sql.DB) {
+func deleteNote(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -262,7 +257,12 @@ func deleteNote(w http.ResponseWriter, r *http.Request, notesService *notes_serv
 		return
 	}
 
-	err = notesService.DeleteNote(uuid.MustParse(noteRequest.NoteId), db)
+	noteId, err := uuid.Parse(noteRequest.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+	err = notesService.DeleteNote(r.Context(), noteId)
 	if err != nil {
 		fmt.Fprintln(w, "Error deleting note: ", err)
 		return
@@ -272,6 +272,12 @@ func deleteNote(w http.ResponseWriter, r *http.Request, notesService *notes_serv
 }
 
 func main() {
+	config, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	app := lifecycle.New(config.ShutdownTimeout)
+
 	// Check if app data directory path was provided
 	// if len(os.Args) < 2 {
 	// 	fmt.Println("Usage: ./backend <app_data_directory_path>")
@@ -295,66 +301,137 @@ func main() {
 	fmt.Println("Journal backend started")
 
 	// Initialising the database
-	db, err := InitialiseDBClient(dbPath)
+	dbClient, err := db_client.NewDBClient(app.Context(), dbPath)
 	if err != nil {
 		log.Fatalf("Failed to initialise database: %v", err)
 	}
-	// err = db.Ping()
-	// if err != nil {
-	// 	log.Fatalf("Database ping failed: %v", err)
-	// }
-	db.Exec("CREATE TABLE IF NOT EXISTS notes (id TEXT PRIMARY KEY, title TEXT, content TEXT, created_at DATETIME, updated_at DATETIME)")
 
-	notesService := notes_service.NotesServiceImpl{}
+	backupDir := config.BackupDir
+	if backupDir == "" {
+		backupDir = filepath.Join(absDir, "backups")
+	}
+	backups, err := backup.NewManager(backupDir, dbClient)
+	if err != nil {
+		log.Fatalf("Failed to initialise backups: %v", err)
+	}
+	if config.BackupInterval > 0 {
+		backups.StartSchedule(app.Context(), config.BackupInterval, config.BackupKeep, config.BackupPassphrase)
+	}
+
+	var notesService notes_service.NotesService = notes_service.NewNotesServiceImpl(dbClient)
+	checkInService := notes_service.NewCheckInService(dbClient)
+	statsService := notes_service.NewStatsService(dbClient)
+	linkService := notes_service.NewLinkService(dbClient)
+	templateService := notes_service.NewTemplateService(dbClient)
+
+	// Edits from the vault go straight to the notes service, while changes
+	// made through the API schedule a sync
+	var vaultSyncer *vault_sync.Syncer
+	if config.VaultDir != "" {
+		vaultSyncer, err = vault_sync.NewSyncer(config.VaultDir, notesService, dbClient)
+		if err != nil {
+			log.Fatalf("Failed to start vault sync: %v", err)
+		}
+		notesService = vaultSyncer.NotifyOnChange(notesService)
+		log.Printf("Syncing notes with %s", config.VaultDir)
+		go vaultSyncer.Run(app.Context())
+	}
 	log.Println("Initialising chat service")
 	chatService := lm_service.ChatServiceImpl{
-		UseHf:  true,
-		Status: false,
-		Model:  "ggml-org/gemma-3-1b-it-GGUF",
+		UseHf: true,
+		Model: "ggml-org/gemma-3-1b-it-GGUF",
 	}
 	chatService.InitialiseChat()
 
-	// Initialising the server with CORS enabled
-	http.HandleFunc("/hello", helloHandler)
-	http.HandleFunc("/createnote", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		createNote(w, r, &notesService, db)
-	}))
-	http.HandleFunc("/updatenote", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		updateNote(w, r, &notesService, db)
-	}))
-	http.HandleFunc("/getallnotes", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		getAllNotes(w, r, &notesService, db)
-	}))
-
-	http.HandleFunc("/getnote", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		getNote(w, r, &notesService, db)
-	}))
+	// Edits from the vault bypass the notification and are picked up by the
+	// periodic sweep instead
+	analysisService := analysis_service.NewService(notesService, &chatService, dbClient)
+	notesService = analysisService.NotifyOnChange(notesService)
+	go analysisService.Run(app.Context())
 
-	http.HandleFunc("/chat", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("/chat request received")
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		chatStream(w, r, &chatService)
-	}))
+	taskService := task_service.NewService(notesService, &chatService, dbClient)
+	notesService = taskService.NotifyOnChange(notesService)
+	go taskService.Run(app.Context())
 
-	http.HandleFunc("/clarity", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		clarityStreamHandler(w, r, &notesService, &chatService, db)
-	}))
+	entityService := entity_service.NewService(notesService, &chatService, dbClient)
+	notesService = entityService.NotifyOnChange(notesService)
+	go entityService.Run(app.Context())
 
-	http.HandleFunc("/deletenote", enableCORS(func(w http.ResponseWriter, r *http.Request) {
-		deleteNote(w, r, &notesService, db)
-	}))
+	titleService := title_service.NewService(notesService, &chatService, dbClient)
+	go titleService.Run(app.Context())
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	resurfaceService := resurface_service.NewService(notesService, analysisService, &chatService)
+
+	promptService := prompt_service.NewService(notesService, &chatService, dbClient)
+	go promptService.Run(app.Context())
+
+	clarityService := clarity_service.NewService(notesService, &chatService, chatService.Model, dbClient)
+
+	digestService := digest_service.NewService(notesService, &chatService, dbClient)
+	jobs := scheduler.New()
+	for _, job := range digestService.Jobs() {
+		jobs.Add(job)
+	}
+	jobs.Start(app.Context())
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, routeDeps{
+		config:           config,
+		notesService:     notesService,
+		checkInService:   checkInService,
+		statsService:     statsService,
+		linkService:      linkService,
+		templateService:  templateService,
+		chatService:      &chatService,
+		clarityService:   clarityService,
+		analysisService:  analysisService,
+		taskService:      taskService,
+		entityService:    entityService,
+		titleService:     titleService,
+		digestService:    digestService,
+		resurfaceService: resurfaceService,
+		promptService:    promptService,
+		backups:          backups,
+		vaultSyncer:      vaultSyncer,
+	})
+	server := &http.Server{Handler: mux}
+
+	// The shell reads the token from this line when it did not supply one
+	if config.TokenGenerated {
+		fmt.Printf("ATHENA_API_TOKEN=%s\n", config.APIToken)
+	}
+	listener, err := Listen(config)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	if config.SocketPath != "" {
+		// Printed so the shell knows where to connect
+		fmt.Printf("ATHENA_SOCKET=%s\n", config.SocketPath)
+		log.Printf("Server starting on unix socket %s", config.SocketPath)
+	} else {
+		log.Printf("Server starting on %s", config.Addr)
+	}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			app.Fail(fmt.Errorf("server stopped: %w", err))
+		}
+	}()
+
+	// Generations are cancelled first so streaming handlers return and the
+	// server can drain, then everything the handlers depend on is torn down.
+	app.OnShutdown("cancel generations and stop llama-server", chatService.Stop)
+	app.OnShutdown("drain http requests", server.Shutdown)
+	if vaultSyncer != nil {
+		app.OnShutdown("stop vault sync", vaultSyncer.Close)
+	}
+	app.OnShutdown("stop entry analysis", analysisService.Close)
+	app.OnShutdown("stop task extraction", taskService.Close)
+	app.OnShutdown("stop people and places extraction", entityService.Close)
+	app.OnShutdown("stop title generation", titleService.Close)
+	app.OnShutdown("stop journal prompt writing", promptService.Close)
+	app.OnShutdown("stop scheduled jobs", jobs.Close)
+	app.OnShutdown("finish running backup", backups.Close)
+	app.OnShutdown("close database", dbClient.Close)
+
+	os.Exit(app.Wait())
 }
@@ -0,0 +1,379 @@
+package notes_service
+
+import (
+	"backend/db"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CheckIn is the structured data recorded with an entry. Every value is
+// optional. Custom fields are defined with CheckInService.DefineField and
+// hold numbers, or 0 and 1 for boolean fields.
+type CheckIn struct {
+	Mood       *int               `json:"Mood,omitempty" yaml:"mood,omitempty"`
+	Energy     *int               `json:"Energy,omitempty" yaml:"energy,omitempty"`
+	SleepHours *float64           `json:"SleepHours,omitempty" yaml:"sleep_hours,omitempty"`
+	Fields     map[string]float64 `json:"Fields,omitempty" yaml:"fields,omitempty"`
+}
+
+const (
+	CheckInNumber  = "number"
+	CheckInBoolean = "boolean"
+)
+
+// CheckInField describes a check-in value and the range it may take
+type CheckInField struct {
+	Name  string `json:"Name"`
+	Label string `json:"Label"`
+	// CheckInNumber or CheckInBoolean
+	Type string   `json:"Type"`
+	Min  *float64 `json:"Min,omitempty"`
+	Max  *float64 `json:"Max,omitempty"`
+	// Built-in fields cannot be changed or deleted
+	BuiltIn bool `json:"BuiltIn"`
+}
+
+var (
+	ErrInvalidCheckIn       = errors.New("invalid check-in")
+	ErrCheckInFieldNotFound = errors.New("check-in field not found")
+)
+
+// Names under which the built-in values are stored next to custom fields
+const (
+	moodField       = "mood"
+	energyField     = "energy"
+	sleepHoursField = "sleep_hours"
+)
+
+var builtInCheckInFields = []CheckInField{
+	{Name: moodField, Label: "Mood", Type: CheckInNumber, Min: ptr(1.0), Max: ptr(5.0), BuiltIn: true},
+	{Name: energyField, Label: "Energy", Type: CheckInNumber, Min: ptr(1.0), Max: ptr(5.0), BuiltIn: true},
+	{Name: sleepHoursField, Label: "Sleep hours", Type: CheckInNumber, Min: ptr(0.0), Max: ptr(24.0), BuiltIn: true},
+}
+
+var checkInFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+// IsEmpty reports whether no value is set
+func (checkIn CheckIn) IsEmpty() bool {
+	return checkIn.Mood == nil && checkIn.Energy == nil && checkIn.SleepHours == nil && len(checkIn.Fields) == 0
+}
+
+// values flattens the check-in to field name and value, as stored
+func (checkIn CheckIn) values() map[string]float64 {
+	values := maps.Clone(checkIn.Fields)
+	if values == nil {
+		values = map[string]float64{}
+	}
+	if checkIn.Mood != nil {
+		values[moodField] = float64(*checkIn.Mood)
+	}
+	if checkIn.Energy != nil {
+		values[energyField] = float64(*checkIn.Energy)
+	}
+	if checkIn.SleepHours != nil {
+		values[sleepHoursField] = *checkIn.SleepHours
+	}
+	return values
+}
+
+// set stores one value read back from the database
+func (checkIn *CheckIn) set(field string, value float64) {
+	switch field {
+	case moodField:
+		checkIn.Mood = ptr(int(value))
+	case energyField:
+		checkIn.Energy = ptr(int(value))
+	case sleepHoursField:
+		checkIn.SleepHours = ptr(value)
+	default:
+		if checkIn.Fields == nil {
+			checkIn.Fields = map[string]float64{}
+		}
+		checkIn.Fields[field] = value
+	}
+}
+
+func loadCheckIns(ctx context.Context, executor db_client.Executor, notes []Note, query string, args ...any) error {
+	indexById := map[uuid.UUID]int{}
+	for i, note := range notes {
+		indexById[note.NoteId] = i
+	}
+
+	rows, err := executor.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var noteId uuid.UUID
+		var field string
+		var value float64
+		if err := rows.Scan(&noteId, &field, &value); err != nil {
+			return err
+		}
+		i, ok := indexById[noteId]
+		if !ok {
+			continue
+		}
+		if notes[i].CheckIn == nil {
+			notes[i].CheckIn = &CheckIn{}
+		}
+		notes[i].CheckIn.set(field, value)
+	}
+	return rows.Err()
+}
+
+func replaceCheckIn(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, checkIn CheckIn) error {
+	if _, err := executor.ExecContext(ctx, "DELETE FROM note_checkins WHERE note_id = ?", noteId); err != nil {
+		return err
+	}
+	for field, value := range checkIn.values() {
+		if _, err := executor.ExecContext(ctx, "INSERT INTO note_checkins (note_id, field, value) VALUES (?, ?, ?)", noteId, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckInService manages custom check-in fields and aggregates check-ins
+// over time. Check-ins themselves are stored with their note.
+type CheckInService struct {
+	dbClient *db_client.DBClient
+}
+
+func NewCheckInService(dbClient *db_client.DBClient) *CheckInService {
+	return &CheckInService{dbClient: dbClient}
+}
+
+// Fields returns the built-in fields followed by the custom fields by name
+func (checkInService *CheckInService) Fields(ctx context.Context) ([]CheckInField, error) {
+	return checkInFields(ctx, checkInService.dbClient)
+}
+
+func checkInFields(ctx context.Context, executor db_client.Executor) ([]CheckInField, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT name, label, type, min, max FROM checkin_fields ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := slices.Clone(builtInCheckInFields)
+	for rows.Next() {
+		var field CheckInField
+		if err := rows.Scan(&field.Name, &field.Label, &field.Type, &field.Min, &field.Max); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, rows.Err()
+}
+
+// DefineField creates a custom field or updates its label and range. Values
+// already recorded are kept even if they fall outside a new range.
+func (checkInService *CheckInService) DefineField(ctx context.Context, field CheckInField) (CheckInField, error) {
+	if !checkInFieldName.MatchString(field.Name) {
+		return field, fmt.Errorf("%w: field names are lower case letters, digits and underscores", ErrInvalidCheckIn)
+	}
+	if isBuiltInField(field.Name) {
+		return field, fmt.Errorf("%w: %s is a built-in field", ErrInvalidCheckIn, field.Name)
+	}
+	switch field.Type {
+	case CheckInNumber:
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			return field, fmt.Errorf("%w: minimum is above maximum", ErrInvalidCheckIn)
+		}
+	case CheckInBoolean:
+		field.Min, field.Max = nil, nil
+	default:
+		return field, fmt.Errorf("%w: type must be %q or %q", ErrInvalidCheckIn, CheckInNumber, CheckInBoolean)
+	}
+	if field.Label == "" {
+		field.Label = field.Name
+	}
+	field.BuiltIn = false
+
+	_, err := checkInService.dbClient.ExecContext(ctx, `INSERT INTO checkin_fields (name, label, type, min, max) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET label = excluded.label, type = excluded.type, min = excluded.min, max = excluded.max`,
+		field.Name, field.Label, field.Type, field.Min, field.Max)
+	return field, err
+}
+
+// DeleteField removes a custom field along with every value recorded for it
+func (checkInService *CheckInService) DeleteField(ctx context.Context, name string) error {
+	if isBuiltInField(name) {
+		return fmt.Errorf("%w: %s is a built-in field", ErrInvalidCheckIn, name)
+	}
+	return checkInService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM checkin_fields WHERE name = ?", name)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return fmt.Errorf("%w: %s", ErrCheckInFieldNotFound, name)
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM note_checkins WHERE field = ?", name)
+		return err
+	})
+}
+
+// Validate checks every value against its field's type and range. Notes are
+// checked the same way when they are stored, so it is for telling the user
+// before anything is written.
+func (checkInService *CheckInService) Validate(ctx context.Context, checkIn CheckIn) error {
+	return validateCheckIn(ctx, checkInService.dbClient, checkIn)
+}
+
+func validateCheckIn(ctx context.Context, executor db_client.Executor, checkIn CheckIn) error {
+	fields, err := checkInFields(ctx, executor)
+	if err != nil {
+		return err
+	}
+	byName := map[string]CheckInField{}
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	for name := range checkIn.Fields {
+		if isBuiltInField(name) {
+			return fmt.Errorf("%w: %s is set with its own property, not in Fields", ErrInvalidCheckIn, name)
+		}
+	}
+	for name, value := range checkIn.values() {
+		field, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown field %s", ErrInvalidCheckIn, name)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%w: %s is not a number", ErrInvalidCheckIn, field.Label)
+		}
+		if field.Type == CheckInBoolean && value != 0 && value != 1 {
+			return fmt.Errorf("%w: %s must be 0 or 1", ErrInvalidCheckIn, field.Label)
+		}
+		if field.Min != nil && value < *field.Min || field.Max != nil && value > *field.Max {
+			return fmt.Errorf("%w: %s must be between %v and %v", ErrInvalidCheckIn, field.Label, valueOr(field.Min, math.Inf(-1)), valueOr(field.Max, math.Inf(1)))
+		}
+	}
+	return nil
+}
+
+func isBuiltInField(name string) bool {
+	return slices.ContainsFunc(builtInCheckInFields, func(field CheckInField) bool { return field.Name == name })
+}
+
+func valueOr(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+// CheckInAggregate summarises one field's values on one day. For a boolean
+// field Mean is the share of entries where it was set.
+type CheckInAggregate struct {
+	Count int     `json:"Count"`
+	Mean  float64 `json:"Mean"`
+	Min   float64 `json:"Min"`
+	Max   float64 `json:"Max"`
+}
+
+// CheckInDay holds the aggregates of a calendar day, keyed by field name
+type CheckInDay struct {
+	// The day in the local time zone, e.g. 2024-01-05
+	Date string `json:"Date"`
+	// Entries with at least one check-in value
+	Entries int                         `json:"Entries"`
+	Fields  map[string]CheckInAggregate `json:"Fields"`
+}
+
+// Longest range DailySeries accepts, about ten years
+const maxSeriesDays = 3660
+
+// DailySeries aggregates check-ins per calendar day from the day of from to
+// the day of to, inclusive. Every day in the range is returned, days without
+// check-ins having no fields, so the series can be charted directly. When
+// names is empty all fields are included.
+func (checkInService *CheckInService) DailySeries(ctx context.Context, from time.Time, to time.Time, names []string) ([]CheckInDay, error) {
+	from = startOfDay(from)
+	end := startOfDay(to).AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return nil, fmt.Errorf("%w: the range ends before it starts", ErrInvalidCheckIn)
+	}
+
+	days := []CheckInDay{}
+	indexByDate := map[string]int{}
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if len(days) == maxSeriesDays {
+			return nil, fmt.Errorf("%w: the range is longer than %d days", ErrInvalidCheckIn, maxSeriesDays)
+		}
+		date := day.Format(time.DateOnly)
+		indexByDate[date] = len(days)
+		days = append(days, CheckInDay{Date: date, Fields: map[string]CheckInAggregate{}})
+	}
+
+	rows, err := checkInService.dbClient.QueryContext(ctx, `SELECT n.id, n.created_at, c.field, c.value FROM note_checkins c
+		JOIN notes n ON n.id = c.note_id WHERE julianday(n.created_at) >= julianday(?) AND julianday(n.created_at) < julianday(?)`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var createdAt time.Time
+		var field string
+		var value float64
+		if err := rows.Scan(&noteId, &createdAt, &field, &value); err != nil {
+			return nil, err
+		}
+		i, ok := indexByDate[createdAt.Local().Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		if !seen[noteId] {
+			seen[noteId] = true
+			days[i].Entries++
+		}
+		if len(names) > 0 && !slices.Contains(names, field) {
+			continue
+		}
+		aggregate, ok := days[i].Fields[field]
+		if !ok {
+			aggregate.Min, aggregate.Max = value, value
+		}
+		// Mean holds the sum until every row is read
+		aggregate.Count++
+		aggregate.Mean += value
+		aggregate.Min = min(aggregate.Min, value)
+		aggregate.Max = max(aggregate.Max, value)
+		days[i].Fields[field] = aggregate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, day := range days {
+		for field, aggregate := range day.Fields {
+			aggregate.Mean /= float64(aggregate.Count)
+			day.Fields[field] = aggregate
+		}
+	}
+	return days, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Local().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,168 @@
+package notes_service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDateRange = errors.New("invalid date range")
+
+// DateField is the timestamp of a note a DateRange applies to
+type DateField string
+
+const (
+	CreatedAt DateField = "created_at"
+	UpdatedAt DateField = "updated_at"
+)
+
+// ParseDateField parses "created_at" or "updated_at", defaulting to
+// created_at when empty
+func ParseDateField(field string) (DateField, error) {
+	switch DateField(field) {
+	case "", CreatedAt:
+		return CreatedAt, nil
+	case UpdatedAt:
+		return UpdatedAt, nil
+	default:
+		return "", fmt.Errorf("%w: date field must be %q or %q", ErrInvalidDateRange, CreatedAt, UpdatedAt)
+	}
+}
+
+// DateRange selects the notes whose Field falls at or after From and before
+// To. A zero bound is open.
+type DateRange struct {
+	From  time.Time
+	To    time.Time
+	Field DateField
+}
+
+func (dateRange DateRange) Matches(note Note) bool {
+	t := note.CreatedAt
+	if dateRange.Field == UpdatedAt {
+		t = note.UpdatedAt
+	}
+	if !dateRange.From.IsZero() && t.Before(dateRange.From) {
+		return false
+	}
+	return dateRange.To.IsZero() || t.Before(dateRange.To)
+}
+
+var (
+	rollingTimeframe = regexp.MustCompile(`^(\d+)(days|weeks|months)$`)
+	quarterTimeframe = regexp.MustCompile(`^(\d{4})-Q([1-4])$|^Q([1-4]) (\d{4})$`)
+	weekTimeframe    = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+)
+
+// ResolveTimeframe turns a named timeframe into the range of time it covers
+// in now's location, along with a description such as "last month". It
+// accepts
+//   - rolling timeframes ending now: "3days", "2weeks", "3months"
+//   - calendar periods relative to now: "today", "yesterday", and "this_" or
+//     "last_" followed by "week", "month", "quarter" or "year"
+//   - given periods: a year "2026", a quarter "2026-Q2" or "Q2 2026", a
+//     month "2026-05", an ISO week "2026-W19" or a day "2026-05-14"
+//
+// Weeks start on Monday.
+func ResolveTimeframe(timeframe string, now time.Time) (time.Time, time.Time, string, error) {
+	loc := now.Location()
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	// Weekday counts from Sunday
+	thisWeek := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	thisMonth := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	thisQuarter := time.Date(year, month-(month-1)%3, 1, 0, 0, 0, 0, loc)
+	thisYear := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+
+	timeframe = strings.TrimSpace(timeframe)
+	switch strings.ToLower(timeframe) {
+	case "today":
+		return today, today.AddDate(0, 0, 1), "today", nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, "yesterday", nil
+	case "this_week":
+		return thisWeek, thisWeek.AddDate(0, 0, 7), "this week", nil
+	case "last_week":
+		return thisWeek.AddDate(0, 0, -7), thisWeek, "last week", nil
+	case "this_month":
+		return thisMonth, thisMonth.AddDate(0, 1, 0), "this month", nil
+	case "last_month":
+		return thisMonth.AddDate(0, -1, 0), thisMonth, "last month", nil
+	case "this_quarter":
+		return thisQuarter, thisQuarter.AddDate(0, 3, 0), "this quarter", nil
+	case "last_quarter":
+		return thisQuarter.AddDate(0, -3, 0), thisQuarter, "last quarter", nil
+	case "this_year":
+		return thisYear, thisYear.AddDate(1, 0, 0), "this year", nil
+	case "last_year":
+		return thisYear.AddDate(-1, 0, 0), thisYear, "last year", nil
+	}
+
+	if match := rollingTimeframe.FindStringSubmatch(timeframe); match != nil {
+		count, err := strconv.Atoi(match[1])
+		if err != nil || count == 0 {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("%w: timeframe %q", ErrInvalidDateRange, timeframe)
+		}
+		var from time.Time
+		switch match[2] {
+		case "days":
+			from = now.AddDate(0, 0, -count)
+		case "weeks":
+			from = now.AddDate(0, 0, -7*count)
+		case "months":
+			from = now.AddDate(0, -count, 0)
+		}
+		return from, now, fmt.Sprintf("the past %d %s", count, match[2]), nil
+	}
+	if match := quarterTimeframe.FindStringSubmatch(timeframe); match != nil {
+		yearText, quarterText := match[1], match[2]
+		if yearText == "" {
+			yearText, quarterText = match[4], match[3]
+		}
+		year, _ := strconv.Atoi(yearText)
+		quarter, _ := strconv.Atoi(quarterText)
+		from := time.Date(year, time.Month(3*quarter-2), 1, 0, 0, 0, 0, loc)
+		return from, from.AddDate(0, 3, 0), fmt.Sprintf("Q%d %d", quarter, year), nil
+	}
+	if match := weekTimeframe.FindStringSubmatch(timeframe); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		week, _ := strconv.Atoi(match[2])
+		// The first ISO week is the one containing 4 January
+		jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+		from := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+7*(week-1))
+		if _, isoWeek := from.ISOWeek(); week < 1 || isoWeek != week {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("%w: %d has no week %d", ErrInvalidDateRange, year, week)
+		}
+		return from, from.AddDate(0, 0, 7), "the week of " + from.Format("2 January 2006"), nil
+	}
+	if from, err := time.ParseInLocation(time.DateOnly, timeframe, loc); err == nil {
+		return from, from.AddDate(0, 0, 1), from.Format("2 January 2006"), nil
+	}
+	if from, err := time.ParseInLocation("2006-01", timeframe, loc); err == nil {
+		return from, from.AddDate(0, 1, 0), from.Format("January 2006"), nil
+	}
+	if from, err := time.ParseInLocation("2006", timeframe, loc); err == nil {
+		return from, from.AddDate(1, 0, 0), from.Format("2006"), nil
+	}
+	return time.Time{}, time.Time{}, "", fmt.Errorf("%w: unknown timeframe %q", ErrInvalidDateRange, timeframe)
+}
+
+// ParseRangeBound parses an explicit range bound, either a day such as
+// 2026-05-14 in loc or an RFC 3339 timestamp. A day given as the end of a
+// range is included, so it resolves to the start of the next day.
+func ParseRangeBound(bound string, loc *time.Location, end bool) (time.Time, error) {
+	if day, err := time.ParseInLocation(time.DateOnly, bound, loc); err == nil {
+		if end {
+			return day.AddDate(0, 0, 1), nil
+		}
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, bound)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is neither a day nor an RFC 3339 timestamp", ErrInvalidDateRange, bound)
+	}
+	return t.In(loc), nil
+}
@@ -0,0 +1,98 @@
+package notes_service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolveTimeframe(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	// A Monday
+	now := time.Date(2026, time.October, 19, 15, 4, 0, 0, loc)
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		timeframe   string
+		from        time.Time
+		to          time.Time
+		description string
+	}{
+		{"today", day(2026, 10, 19), day(2026, 10, 20), "today"},
+		{"yesterday", day(2026, 10, 18), day(2026, 10, 19), "yesterday"},
+		{"this_week", day(2026, 10, 19), day(2026, 10, 26), "this week"},
+		{"last_week", day(2026, 10, 12), day(2026, 10, 19), "last week"},
+		{"LAST_MONTH", day(2026, 9, 1), day(2026, 10, 1), "last month"},
+		{"this_quarter", day(2026, 10, 1), day(2027, 1, 1), "this quarter"},
+		{"last_quarter", day(2026, 7, 1), day(2026, 10, 1), "last quarter"},
+		{"last_year", day(2025, 1, 1), day(2026, 1, 1), "last year"},
+		{"3days", now.AddDate(0, 0, -3), now, "the past 3 days"},
+		{"2weeks", now.AddDate(0, 0, -14), now, "the past 2 weeks"},
+		{"1months", now.AddDate(0, -1, 0), now, "the past 1 months"},
+		{"2026-Q2", day(2026, 4, 1), day(2026, 7, 1), "Q2 2026"},
+		{"Q2 2026", day(2026, 4, 1), day(2026, 7, 1), "Q2 2026"},
+		{"2025-Q4", day(2025, 10, 1), day(2026, 1, 1), "Q4 2025"},
+		// 4 January 2026 is a Sunday, so the first week starts in 2025
+		{"2026-W01", day(2025, 12, 29), day(2026, 1, 5), "the week of 29 December 2025"},
+		// 2026 starts on a Thursday, so it has 53 weeks
+		{"2026-W53", day(2026, 12, 28), day(2027, 1, 4), "the week of 28 December 2026"},
+		{"2026-05-14", day(2026, 5, 14), day(2026, 5, 15), "14 May 2026"},
+		{"2026-05", day(2026, 5, 1), day(2026, 6, 1), "May 2026"},
+		{" 2026 ", day(2026, 1, 1), day(2027, 1, 1), "2026"},
+	}
+	for _, test := range tests {
+		t.Run(test.timeframe, func(t *testing.T) {
+			from, to, description, err := ResolveTimeframe(test.timeframe, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !from.Equal(test.from) || !to.Equal(test.to) || description != test.description {
+				t.Errorf("got %v to %v %q, want %v to %v %q", from, to, description, test.from, test.to, test.description)
+			}
+			if from.Location() != loc || to.Location() != loc {
+				t.Errorf("got %v to %v, want both in %v", from, to, loc)
+			}
+		})
+	}
+
+	for _, timeframe := range []string{"0days", "0weeks", "2026-W00", "2025-W53", "2026-W54", "2026-Q5", "Q0 2026", "2026-13", "fortnight", ""} {
+		if _, _, _, err := ResolveTimeframe(timeframe, now); !errors.Is(err, ErrInvalidDateRange) {
+			t.Errorf("%q: got %v, want ErrInvalidDateRange", timeframe, err)
+		}
+	}
+}
+
+func TestParseRangeBound(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	tests := []struct {
+		bound string
+		end   bool
+		want  time.Time
+	}{
+		{"2026-05-14", false, time.Date(2026, 5, 14, 0, 0, 0, 0, loc)},
+		// A day ending a range is included
+		{"2026-05-14", true, time.Date(2026, 5, 15, 0, 0, 0, 0, loc)},
+		{"2026-12-31", true, time.Date(2027, 1, 1, 0, 0, 0, 0, loc)},
+		// Timestamps are taken as they are, whichever end they are
+		{"2026-05-14T12:30:00Z", false, time.Date(2026, 5, 14, 7, 30, 0, 0, loc)},
+		{"2026-05-14T12:30:00+02:00", true, time.Date(2026, 5, 14, 5, 30, 0, 0, loc)},
+	}
+	for _, test := range tests {
+		got, err := ParseRangeBound(test.bound, loc, test.end)
+		if err != nil {
+			t.Errorf("%q: %v", test.bound, err)
+			continue
+		}
+		if !got.Equal(test.want) || got.Location() != loc {
+			t.Errorf("%q with end %v: got %v, want %v", test.bound, test.end, got, test.want)
+		}
+	}
+
+	for _, bound := range []string{"", "yesterday", "2026-5-14", "2026-05-14 12:30"} {
+		if _, err := ParseRangeBound(bound, loc, false); !errors.Is(err, ErrInvalidDateRange) {
+			t.Errorf("%q: got %v, want ErrInvalidDateRange", bound, err)
+		}
+	}
+}
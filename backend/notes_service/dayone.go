@@ -0,0 +1,194 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The subset of a Day One JSON export that maps onto notes. An export is
+// either a single journal JSON file or a zip holding one JSON file per journal
+// alongside photos/, videos/ etc.
+type dayOneExport struct {
+	Entries []dayOneEntry `json:"entries"`
+}
+
+type dayOneEntry struct {
+	UUID         string          `json:"uuid"`
+	CreationDate time.Time       `json:"creationDate"`
+	ModifiedDate time.Time       `json:"modifiedDate"`
+	TimeZone     string          `json:"timeZone"`
+	Text         string          `json:"text"`
+	Tags         []string        `json:"tags"`
+	Starred      bool            `json:"starred"`
+	Location     *dayOneLocation `json:"location"`
+	Photos       []dayOneMoment  `json:"photos"`
+	Videos       []dayOneMoment  `json:"videos"`
+	Audios       []dayOneMoment  `json:"audios"`
+	PDFs         []dayOneMoment  `json:"pdfAttachments"`
+}
+
+type dayOneLocation struct {
+	PlaceName          string `json:"placeName"`
+	LocalityName       string `json:"localityName"`
+	AdministrativeArea string `json:"administrativeArea"`
+	Country            string `json:"country"`
+}
+
+type dayOneMoment struct {
+	Identifier string `json:"identifier"`
+}
+
+// Tag given to entries that were starred in Day One
+const dayOneStarredTag = "starred"
+
+// Namespace for ids derived from Day One uuids that are not valid UUIDs
+var dayOneNamespace = uuid.MustParse("5d0c1e0a-3b5f-4c39-9a51-8d0f8f4a6a11")
+
+// Embedded media, e.g. ![](dayone-moment://0A1B...) or ![](dayone-moment:/video/0A1B...)
+var dayOneMomentLink = regexp.MustCompile(`!\[[^\]]*\]\(dayone-moment:/*[^)]*\)\n?`)
+
+// ReadDayOneZip parses every journal JSON file in a Day One export archive
+func ReadDayOneZip(data []byte) ([]Note, []ImportSkip, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	notes := []Note{}
+	skipped := []ImportSkip{}
+	journals := 0
+	for _, file := range archive.File {
+		// Media files are referenced from the entries and reported there
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".json") {
+			continue
+		}
+		journal, err := readZipFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		journalNotes, journalSkipped, err := ReadDayOneJSON(journal)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		notes = append(notes, journalNotes...)
+		skipped = append(skipped, journalSkipped...)
+		journals++
+	}
+	if journals == 0 {
+		return nil, nil, fmt.Errorf("no Day One journal found in archive")
+	}
+	return notes, skipped, nil
+}
+
+// ReadDayOneJSON parses a single Day One journal JSON file. Entries that
+// cannot be mapped onto a note, and media that notes cannot hold, are
+// reported as skipped.
+func ReadDayOneJSON(data []byte) ([]Note, []ImportSkip, error) {
+	var export dayOneExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, nil, fmt.Errorf("invalid Day One export: %w", err)
+	}
+
+	notes := []Note{}
+	skipped := []ImportSkip{}
+	for i, entry := range export.Entries {
+		name := entry.UUID
+		if name == "" {
+			name = fmt.Sprintf("entry %d", i+1)
+		}
+		note, err := dayOneNote(entry)
+		if err != nil {
+			skipped = append(skipped, ImportSkip{Name: name, Reason: err.Error()})
+			continue
+		}
+		if media := len(entry.Photos) + len(entry.Videos) + len(entry.Audios) + len(entry.PDFs); media > 0 {
+			skipped = append(skipped, ImportSkip{Name: name, Reason: fmt.Sprintf("%d attached media file(s) not imported", media)})
+		}
+		notes = append(notes, note)
+	}
+	return notes, skipped, nil
+}
+
+// dayOneNote maps an entry onto a note. The id is derived from the entry's
+// uuid so importing the same export twice updates the same notes.
+func dayOneNote(entry dayOneEntry) (Note, error) {
+	if entry.UUID == "" {
+		return Note{}, fmt.Errorf("entry has no uuid")
+	}
+	if entry.CreationDate.IsZero() {
+		return Note{}, fmt.Errorf("entry has no creation date")
+	}
+	text := strings.TrimSpace(dayOneMomentLink.ReplaceAllString(entry.Text, ""))
+	if text == "" {
+		return Note{}, fmt.Errorf("entry has no text")
+	}
+
+	id, err := uuid.Parse(entry.UUID)
+	if err != nil {
+		id = uuid.NewSHA1(dayOneNamespace, []byte(entry.UUID))
+	}
+
+	// Keep the wall clock time the entry was written at
+	createdAt, updatedAt := entry.CreationDate, entry.ModifiedDate
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	if location, err := time.LoadLocation(entry.TimeZone); entry.TimeZone != "" && err == nil {
+		createdAt, updatedAt = createdAt.In(location), updatedAt.In(location)
+	}
+
+	title, content := dayOneTitle(text)
+	if place := entry.Location.String(); place != "" {
+		content = strings.TrimRight(content, "\n") + "\n\n_" + place + "_\n"
+	}
+
+	tags := append([]string{}, entry.Tags...)
+	if entry.Starred {
+		tags = append(tags, dayOneStarredTag)
+	}
+
+	return Note{
+		NoteId:    id,
+		Title:     title,
+		Content:   content,
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+		Tags:      NormaliseTags(tags),
+	}, nil
+}
+
+// dayOneTitle uses the first line of an entry as its title, as Day One does.
+// A first line too long for a title is shortened and kept in the content.
+func dayOneTitle(text string) (string, string) {
+	firstLine, rest, _ := strings.Cut(text, "\n")
+	title := strings.TrimSpace(strings.TrimLeft(firstLine, "# "))
+	title = strings.ReplaceAll(title, `\`, "")
+	if title == "" {
+		return DefaultTitle, text
+	}
+	if runes := []rune(title); len(runes) > 80 {
+		return strings.TrimSpace(string(runes[:80])) + "…", text
+	}
+	return title, strings.TrimLeft(rest, "\n")
+}
+
+func (location *dayOneLocation) String() string {
+	if location == nil {
+		return ""
+	}
+	parts := []string{}
+	for _, part := range []string{location.PlaceName, location.LocalityName, location.AdministrativeArea, location.Country} {
+		if part != "" && (len(parts) == 0 || parts[len(parts)-1] != part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,25 @@
+package notes_service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDayOneTitle(t *testing.T) {
+	long := strings.Repeat("word ", 20) + "end"
+	tests := []struct {
+		text    string
+		title   string
+		content string
+	}{
+		{"# Morning\n\nCoffee first", "Morning", "Coffee first"},
+		{"\nNo title here", DefaultTitle, "\nNo title here"},
+		{long + "\nSecond line", strings.TrimSpace(long[:80]) + "…", long + "\nSecond line"},
+	}
+	for _, test := range tests {
+		title, content := dayOneTitle(test.text)
+		if title != test.title || content != test.content {
+			t.Errorf("dayOneTitle(%q) = %q, %q, want %q, %q", test.text, title, content, test.title, test.content)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package notes_service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnexImporter reads an Evernote ENEX export. Note bodies are ENML, a subset
+// of XHTML, and are converted to Markdown. Attachments are not imported.
+type EnexImporter struct{}
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Mime string `xml:"mime"`
+}
+
+// ENEX timestamps are UTC, e.g. 20240105T091400Z
+const enexTimeFormat = "20060102T150405Z"
+
+var enexNamespace = uuid.MustParse("9b3f3c1e-7d6a-4f0e-8a43-6f2b8d51c7a0")
+
+func (EnexImporter) Name() string { return "enex" }
+
+func (EnexImporter) Accepts(fileName string, data []byte) bool {
+	return strings.EqualFold(path.Ext(fileName), ".enex") || bytes.Contains(data[:min(len(data), 1024)], []byte("<en-export"))
+}
+
+func (EnexImporter) Parse(_ string, data []byte) ([]Note, []ImportSkip, error) {
+	notes := []Note{}
+	skipped := []ImportSkip{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// ENEX files declare an external DTD that is not needed to read them
+	decoder.Strict = false
+
+	sawExport := false
+	for count := 0; ; {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ENEX file: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "en-export":
+			sawExport = true
+		case "note":
+			count++
+			var entry enexNote
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return nil, nil, fmt.Errorf("invalid ENEX note %d: %w", count, err)
+			}
+			name := entry.Title
+			if name == "" {
+				name = fmt.Sprintf("note %d", count)
+			}
+			note, err := enexToNote(entry)
+			if err != nil {
+				skipped = append(skipped, ImportSkip{Name: name, Reason: err.Error()})
+				continue
+			}
+			if len(entry.Resources) > 0 {
+				skipped = append(skipped, ImportSkip{Name: name, Reason: fmt.Sprintf("%d attachment(s) not imported", len(entry.Resources))})
+			}
+			notes = append(notes, note)
+		}
+	}
+	if !sawExport {
+		return nil, nil, fmt.Errorf("not an ENEX file")
+	}
+	return notes, skipped, nil
+}
+
+func enexToNote(entry enexNote) (Note, error) {
+	createdAt, err := time.Parse(enexTimeFormat, entry.Created)
+	if err != nil {
+		return Note{}, fmt.Errorf("invalid creation date %q", entry.Created)
+	}
+	updatedAt, err := time.Parse(enexTimeFormat, entry.Updated)
+	if err != nil {
+		updatedAt = createdAt
+	}
+	content, err := ENMLToMarkdown(entry.Content)
+	if err != nil {
+		return Note{}, err
+	}
+
+	title := strings.TrimSpace(entry.Title)
+	if title == "" {
+		title = DefaultTitle
+	}
+	return Note{
+		// Evernote does not export note ids
+		NoteId:    uuid.NewSHA1(enexNamespace, []byte(createdAt.Format(time.RFC3339)+"\n"+title)),
+		Title:     title,
+		Content:   content,
+		CreatedAt: createdAt.Local(),
+		UpdatedAt: updatedAt.Local(),
+		Tags:      NormaliseTags(entry.Tags),
+	}, nil
+}
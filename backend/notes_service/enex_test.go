@@ -0,0 +1,67 @@
+package notes_service
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnexImporter(t *testing.T) {
+	export := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export3.dtd">
+<en-export export-date="20240110T120000Z" application="Evernote">
+  <note>
+    <title>Groceries</title>
+    <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?><en-note><ul><li>Eggs</li></ul><en-media type="image/png" hash="abc"/></en-note>]]></content>
+    <created>20240105T091400Z</created>
+    <updated>20240106T101500Z</updated>
+    <tag>home</tag>
+    <tag>#lists</tag>
+    <resource><mime>image/png</mime></resource>
+  </note>
+  <note>
+    <title></title>
+    <content><![CDATA[<en-note><div>No title</div></en-note>]]></content>
+    <created>20240107T080000Z</created>
+  </note>
+  <note>
+    <title>Broken</title>
+    <content><![CDATA[<en-note>Lost</en-note>]]></content>
+    <created>yesterday</created>
+  </note>
+</en-export>`
+	if !(EnexImporter{}).Accepts("export.xml", []byte(export)) {
+		t.Fatal("the export was not recognised")
+	}
+	notes, skipped, err := EnexImporter{}.Parse("export.enex", []byte(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("got %d notes, want 2", len(notes))
+	}
+
+	groceries := notes[0]
+	if groceries.Title != "Groceries" || groceries.Content != "- Eggs\n" || !slices.Equal(groceries.Tags, []string{"home", "lists"}) {
+		t.Errorf("got %q %q tagged %v", groceries.Title, groceries.Content, groceries.Tags)
+	}
+	if !groceries.CreatedAt.Equal(time.Date(2024, 1, 5, 9, 14, 0, 0, time.UTC)) || !groceries.UpdatedAt.Equal(time.Date(2024, 1, 6, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("got created %v and updated %v", groceries.CreatedAt, groceries.UpdatedAt)
+	}
+	untitled := notes[1]
+	if untitled.Title != DefaultTitle || !untitled.UpdatedAt.Equal(untitled.CreatedAt) {
+		t.Errorf("got %q updated %v", untitled.Title, untitled.UpdatedAt)
+	}
+
+	wantSkipped := []ImportSkip{
+		{Name: "Groceries", Reason: "1 attachment(s) not imported"},
+		{Name: "Broken", Reason: `invalid creation date "yesterday"`},
+	}
+	if !slices.Equal(skipped, wantSkipped) {
+		t.Errorf("got skipped %+v, want %+v", skipped, wantSkipped)
+	}
+
+	if _, _, err := (EnexImporter{}).Parse("export.enex", []byte(`<notes></notes>`)); err == nil {
+		t.Error("a file without en-export was accepted")
+	}
+}
@@ -0,0 +1,304 @@
+package notes_service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var collapsibleSpace = regexp.MustCompile(`[ \t\r\n]+`)
+
+// ENMLToMarkdown converts an Evernote note body to Markdown. Formatting
+// without a Markdown equivalent is dropped and media is left out.
+func ENMLToMarkdown(enml string) (string, error) {
+	// The XML prolog and DOCTYPE are not HTML, the parser would keep them as
+	// text
+	if i := strings.Index(enml, "<en-note"); i >= 0 {
+		enml = enml[i:]
+	}
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(enml), body)
+	if err != nil {
+		return "", fmt.Errorf("invalid note content: %w", err)
+	}
+
+	converter := &enmlConverter{}
+	for _, node := range nodes {
+		converter.node(node)
+	}
+	markdown := strings.TrimSpace(tidyLines(converter.out.String()))
+	if markdown == "" {
+		return "", nil
+	}
+	return markdown + "\n", nil
+}
+
+type enmlConverter struct {
+	out strings.Builder
+	// Prefix of every line in the current block, e.g. "> " in a quote
+	prefix string
+	// Enclosing lists, true for ordered lists, and their item counters
+	lists   []bool
+	counter []int
+	pre     bool
+}
+
+func (converter *enmlConverter) write(text string) {
+	converter.out.WriteString(text)
+}
+
+// newline ends the current line, starting the next one with the block prefix
+func (converter *enmlConverter) newline() {
+	converter.write("\n" + converter.prefix)
+}
+
+func (converter *enmlConverter) atLineStart() bool {
+	written := converter.out.String()
+	return written == "" || strings.HasSuffix(written, "\n"+converter.prefix)
+}
+
+func (converter *enmlConverter) blankLine() {
+	converter.newline()
+	converter.newline()
+}
+
+func (converter *enmlConverter) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		converter.node(child)
+	}
+}
+
+// wrap writes the children between marker, e.g. ** for bold
+func (converter *enmlConverter) wrap(node *html.Node, marker string) {
+	converter.write(marker)
+	converter.children(node)
+	converter.write(marker)
+}
+
+func (converter *enmlConverter) node(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		if converter.pre {
+			converter.write(strings.ReplaceAll(node.Data, "\n", "\n"+converter.prefix))
+			return
+		}
+		text := collapsibleSpace.ReplaceAllString(node.Data, " ")
+		if converter.atLineStart() {
+			text = strings.TrimLeft(text, " ")
+		}
+		converter.write(text)
+		return
+	case html.ElementNode:
+	default:
+		converter.children(node)
+		return
+	}
+
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		converter.blankLine()
+		converter.write(strings.Repeat("#", int(node.Data[1]-'0')) + " ")
+		converter.children(node)
+		converter.blankLine()
+	case "p", "div", "en-note", "center", "section":
+		converter.newline()
+		converter.children(node)
+		converter.newline()
+	case "br":
+		converter.newline()
+	case "hr":
+		converter.blankLine()
+		converter.write("---")
+		converter.blankLine()
+	case "b", "strong":
+		converter.wrap(node, "**")
+	case "i", "em":
+		converter.wrap(node, "*")
+	case "s", "strike", "del":
+		converter.wrap(node, "~~")
+	case "code":
+		if converter.pre {
+			converter.children(node)
+		} else {
+			converter.wrap(node, "`")
+		}
+	case "pre":
+		converter.blankLine()
+		converter.write("```")
+		converter.newline()
+		converter.pre = true
+		converter.children(node)
+		converter.pre = false
+		converter.newline()
+		converter.write("```")
+		converter.blankLine()
+	case "a":
+		href := attr(node, "href")
+		if href == "" {
+			converter.children(node)
+			return
+		}
+		converter.write("[")
+		converter.children(node)
+		converter.write("](" + href + ")")
+	case "blockquote":
+		previous := converter.prefix
+		converter.prefix += "> "
+		converter.blankLine()
+		converter.children(node)
+		converter.prefix = previous
+		converter.blankLine()
+	case "ul", "ol":
+		converter.lists = append(converter.lists, node.Data == "ol")
+		converter.counter = append(converter.counter, 0)
+		converter.children(node)
+		converter.lists = converter.lists[:len(converter.lists)-1]
+		converter.counter = converter.counter[:len(converter.counter)-1]
+		converter.newline()
+	case "li":
+		converter.listItem(node)
+	case "en-todo":
+		// Outside a list the to-do needs a marker to be a task list item
+		if len(converter.lists) == 0 {
+			converter.write("- ")
+		}
+		if attr(node, "checked") == "true" {
+			converter.write("[x] ")
+		} else {
+			converter.write("[ ] ")
+		}
+		// The parser does not know en-todo is empty and nests what follows
+		converter.children(node)
+	case "table":
+		converter.table(node)
+	case "en-media":
+		// Attachments cannot be carried over. Like en-todo, the parser nests
+		// what follows inside it.
+		converter.children(node)
+	case "en-crypt", "img", "script", "style":
+		// Encrypted text cannot be carried over
+	default:
+		converter.children(node)
+	}
+}
+
+func (converter *enmlConverter) listItem(node *html.Node) {
+	depth := len(converter.lists)
+	marker := "- "
+	if depth > 0 && converter.lists[depth-1] {
+		converter.counter[depth-1]++
+		marker = fmt.Sprintf("%d. ", converter.counter[depth-1])
+	}
+	converter.newline()
+	converter.write(marker)
+
+	// Nested blocks inside the item are indented to line up with its text
+	previous := converter.prefix
+	converter.prefix += strings.Repeat(" ", len(marker))
+	converter.inline(node)
+	converter.prefix = previous
+}
+
+// inline writes the children of a block whose paragraphs should not be
+// separated by blank lines, such as a list item
+func (converter *enmlConverter) inline(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.Data == "div" || child.Data == "p") {
+			converter.inline(child)
+			continue
+		}
+		converter.node(child)
+	}
+}
+
+// table writes a table as a Markdown table, treating the first row as its
+// header
+func (converter *enmlConverter) table(node *html.Node) {
+	rows := [][]string{}
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			row := []string{}
+			for cell := n.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+					cellConverter := &enmlConverter{}
+					cellConverter.inline(cell)
+					text := strings.TrimSpace(strings.ReplaceAll(cellConverter.out.String(), "\n", " "))
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			rows = append(rows, row)
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	if len(rows) == 0 {
+		return
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	converter.blankLine()
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		converter.write("| " + strings.Join(row, " | ") + " |")
+		converter.newline()
+		if i == 0 {
+			converter.write(strings.TrimSuffix(strings.Repeat("| --- ", columns), " ") + " |")
+			converter.newline()
+		}
+	}
+	converter.newline()
+}
+
+func attr(node *html.Node, name string) string {
+	for _, attribute := range node.Attr {
+		if attribute.Key == name {
+			return attribute.Val
+		}
+	}
+	return ""
+}
+
+// tidyLines strips trailing spaces and collapses runs of blank lines, which
+// the converter writes freely between blocks. A run keeps a quote's blank
+// line ("> ") only when it does not end the quote.
+func tidyLines(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	tidy := []string{}
+	run := []string{}
+	flushRun := func() {
+		if len(run) == 0 {
+			return
+		}
+		blank := run[0]
+		for _, line := range run {
+			if line == "" {
+				blank = ""
+			}
+		}
+		tidy = append(tidy, blank)
+		run = run[:0]
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.Trim(line, "> ") == "" {
+			run = append(run, line)
+			continue
+		}
+		flushRun()
+		tidy = append(tidy, line)
+	}
+	flushRun()
+	return strings.Join(tidy, "\n")
+}
@@ -0,0 +1,54 @@
+package notes_service
+
+import "testing"
+
+func TestENMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		enml string
+		want string
+	}{
+		{
+			"lists",
+			`<?xml version="1.0" encoding="UTF-8"?><!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">` +
+				`<en-note><div>Shopping</div><ul><li>Milk</li><li>Bread<ul><li>Rye</li></ul></li></ul><ol><li>First</li><li>Second</li></ol></en-note>`,
+			"Shopping\n\n- Milk\n- Bread\n  - Rye\n\n1. First\n2. Second\n",
+		},
+		{
+			"checkboxes",
+			`<en-note><div><en-todo checked="true"/>Call mum</div><div><en-todo checked="false"/>Pay rent</div><ul><li><en-todo/>Listed</li></ul></en-note>`,
+			"- [x] Call mum\n\n- [ ] Pay rent\n\n- [ ] Listed\n",
+		},
+		{
+			"links",
+			`<en-note><div>See <a href="https://example.com/a">the site</a> and <a>no link</a>.</div></en-note>`,
+			"See [the site](https://example.com/a) and no link.\n",
+		},
+		{
+			"media",
+			`<en-note><div>Before</div><en-media type="image/png" hash="abc"/><div>After <en-media type="application/pdf" hash="def"></en-media>text</div></en-note>`,
+			"Before\n\nAfter text\n",
+		},
+		{
+			"formatting",
+			`<en-note><div><b>Bold</b> <i>it</i> <s>gone</s></div><blockquote><div>Quoted</div><div>Two</div></blockquote><pre>code  here` + "\n" + `line</pre></en-note>`,
+			"**Bold** *it* ~~gone~~\n\n> Quoted\n>\n> Two\n\n```\ncode  here\nline\n```\n",
+		},
+		{
+			"table",
+			`<en-note><table><tr><td>Day</td><td>Miles</td></tr><tr><td>Mon</td><td>3|4</td></tr></table></en-note>`,
+			"| Day | Miles |\n| --- | --- |\n| Mon | 3\\|4 |\n",
+		},
+		{"empty", `<en-note><div><br/></div></en-note>`, ""},
+	}
+	for _, test := range tests {
+		got, err := ENMLToMarkdown(test.enml)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Templates for every page of the HTML export and its stylesheet
+//
+//go:embed html_export.tmpl
+var htmlExportTemplates string
+
+var htmlTemplates = template.Must(template.New("html_export").Funcs(template.FuncMap{
+	"date":     func(t time.Time) string { return t.Local().Format("Monday 2 January 2006") },
+	"time":     func(t time.Time) string { return t.Local().Format("15:04") },
+	"datetime": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(htmlExportTemplates))
+
+// Raw HTML in notes is dropped and unsafe links (e.g. javascript:) are not
+// rendered, so the export is safe to open in a browser.
+var markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// HTMLExportOptions configures the static site written by WriteHTMLZip
+type HTMLExportOptions struct {
+	// Shown as the site's heading and page titles
+	Title string
+	// Shown in order on a page of their own, linked from the index
+	Reflections []HTMLReflection
+}
+
+// HTMLReflection is a stored clarity report or digest added to the export
+type HTMLReflection struct {
+	Title     string
+	CreatedAt time.Time
+	// Markdown
+	Content string
+}
+
+type htmlReflection struct {
+	Title     string
+	CreatedAt time.Time
+	Body      template.HTML
+}
+
+type htmlNote struct {
+	Id        uuid.UUID
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      template.HTML
+	Tags      []htmlLink
+	// Link to the note on its month page
+	Href string
+}
+
+type htmlLink struct {
+	Name  string
+	Href  string
+	Count int
+}
+
+type htmlMonth struct {
+	htmlLink
+	Notes []*htmlNote
+}
+
+// htmlPage is the data every page template is executed with
+type htmlPage struct {
+	SiteTitle string
+	Title     string
+	// Relative path from the page back to the root of the site
+	Root        string
+	Months      []htmlMonth
+	Tags        []htmlLink
+	Notes       []*htmlNote
+	Reflections []htmlReflection
+}
+
+// WriteHTMLZip renders the notes as a self-contained static website and writes
+// it to w as a zip. The site has a chronological index, a page per month
+// holding that month's notes in full and a page per tag, and a page of
+// reflections when there are any.
+func WriteHTMLZip(w io.Writer, notes []Note, options HTMLExportOptions) error {
+	if options.Title == "" {
+		options.Title = "Athena Journal"
+	}
+
+	sorted := append([]Note{}, notes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	tagFiles := map[string]string{}
+	usedTagFiles := map[string]bool{}
+	tagNotes := map[string][]*htmlNote{}
+	tagNames := []string{}
+	months := []htmlMonth{}
+	for _, note := range sorted {
+		var body bytes.Buffer
+		if err := markdownRenderer.Convert([]byte(note.Content), &body); err != nil {
+			return fmt.Errorf("failed to render note %v: %w", note.NoteId, err)
+		}
+
+		// Notes keep the offset they were written in, so they are grouped in
+		// the local time zone, where the sorted months follow each other
+		createdAt := note.CreatedAt.Local()
+		monthName := createdAt.Format("January 2006")
+		if len(months) == 0 || months[len(months)-1].Name != monthName {
+			months = append(months, htmlMonth{htmlLink: htmlLink{
+				Name: monthName,
+				Href: "months/" + createdAt.Format("2006-01") + ".html",
+			}})
+		}
+		month := &months[len(months)-1]
+
+		rendered := &htmlNote{
+			Id:        note.NoteId,
+			Title:     note.Title,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+			Body:      template.HTML(body.String()),
+			Href:      month.Href + "#note-" + note.NoteId.String(),
+		}
+		for _, tag := range note.Tags {
+			if _, ok := tagFiles[tag]; !ok {
+				tagFiles[tag] = uniqueTagFile(tag, usedTagFiles)
+				usedTagFiles[tagFiles[tag]] = true
+				tagNames = append(tagNames, tag)
+			}
+			rendered.Tags = append(rendered.Tags, htmlLink{Name: tag, Href: tagFiles[tag]})
+			tagNotes[tag] = append(tagNotes[tag], rendered)
+		}
+		month.Notes = append(month.Notes, rendered)
+		month.Count++
+	}
+
+	sort.Strings(tagNames)
+	tags := []htmlLink{}
+	for _, tag := range tagNames {
+		tags = append(tags, htmlLink{Name: tag, Href: tagFiles[tag], Count: len(tagNotes[tag])})
+	}
+
+	reflections := []htmlReflection{}
+	for _, reflection := range options.Reflections {
+		var body bytes.Buffer
+		if err := markdownRenderer.Convert([]byte(reflection.Content), &body); err != nil {
+			return fmt.Errorf("failed to render reflection %q: %w", reflection.Title, err)
+		}
+		reflections = append(reflections, htmlReflection{
+			Title:     reflection.Title,
+			CreatedAt: reflection.CreatedAt,
+			Body:      template.HTML(body.String()),
+		})
+	}
+
+	site := zip.NewWriter(w)
+	page := func(name string, templateName string, data htmlPage) error {
+		data.SiteTitle = options.Title
+		data.Months = months
+		data.Tags = tags
+		data.Reflections = reflections
+		file, err := site.Create(name)
+		if err != nil {
+			return err
+		}
+		return htmlTemplates.ExecuteTemplate(file, templateName, data)
+	}
+
+	if err := page("index.html", "index", htmlPage{Title: options.Title}); err != nil {
+		return err
+	}
+	for _, month := range months {
+		if err := page(month.Href, "month", htmlPage{Title: month.Name, Root: "../", Notes: month.Notes}); err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		if err := page(tag.Href, "tag", htmlPage{Title: "#" + tag.Name, Root: "../", Notes: tagNotes[tag.Name]}); err != nil {
+			return err
+		}
+	}
+	if len(reflections) > 0 {
+		if err := page("reflections.html", "reflections", htmlPage{Title: "Reflections"}); err != nil {
+			return err
+		}
+	}
+	style, err := site.Create("style.css")
+	if err != nil {
+		return err
+	}
+	if err := htmlTemplates.ExecuteTemplate(style, "style", nil); err != nil {
+		return err
+	}
+	return site.Close()
+}
+
+// uniqueTagFile names a tag's page, keeping tags whose slugs collide apart
+func uniqueTagFile(tag string, used map[string]bool) string {
+	slug := slugify(tag, "tag")
+	name := "tags/" + slug + ".html"
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("tags/%s-%d.html", slug, i)
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestHTMLExportGroupsMonthsInLocalTime exports notes written in other time
+// zones around the turn of a month
+func TestHTMLExportGroupsMonthsInLocalTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	notes := []Note{
+		// Still 31 March in UTC
+		{NoteId: uuid.New(), Title: "Late flight", CreatedAt: at("2026-04-01T00:30:00+02:00")},
+		{NoteId: uuid.New(), Title: "End of March", CreatedAt: at("2026-03-31T23:45:00Z")},
+		{NoteId: uuid.New(), Title: "April begins", CreatedAt: at("2026-04-01T10:00:00Z")},
+	}
+	var archive bytes.Buffer
+	if err := WriteHTMLZip(&archive, notes, HTMLExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := map[string]string{}
+	for _, file := range reader.File {
+		if _, ok := pages[file.Name]; ok {
+			t.Errorf("%s is in the export twice", file.Name)
+		}
+		opened, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(opened)
+		opened.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages[file.Name] = string(data)
+	}
+
+	want := map[string][]string{
+		"months/2026-03.html": {"Late flight", "End of March", "Tuesday 31 March 2026"},
+		"months/2026-04.html": {"April begins"},
+	}
+	for name, titles := range want {
+		page, ok := pages[name]
+		if !ok {
+			t.Errorf("the export has no %s", name)
+			continue
+		}
+		for _, title := range titles {
+			if !strings.Contains(page, title) {
+				t.Errorf("%s is missing %q", name, title)
+			}
+		}
+	}
+	if strings.Contains(pages["months/2026-04.html"], "Late flight") {
+		t.Error("a note from 31 March is on the April page")
+	}
+}
@@ -0,0 +1,278 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Importer reads an export from another journaling app into notes. Importers
+// give every note a stable id derived from the source, so importing the same
+// export twice updates the notes rather than duplicating them.
+type Importer interface {
+	// Name identifies the format, e.g. "jrnl"
+	Name() string
+	// Accepts reports whether an uploaded file looks like this format
+	Accepts(fileName string, data []byte) bool
+	// Parse reads an uploaded file. Entries that cannot be imported are
+	// reported as skipped rather than failing the whole import.
+	Parse(fileName string, data []byte) ([]Note, []ImportSkip, error)
+}
+
+// Importers lists every supported format. Formats are tried in this order
+// when detecting the format of an upload.
+var Importers = []Importer{
+	DayOneImporter{},
+	EnexImporter{},
+	MarkdownImporter{},
+	JrnlImporter{},
+}
+
+// FindImporter returns the importer for the named format, or detects the
+// format from the upload when name is empty.
+func FindImporter(name string, fileName string, data []byte) (Importer, error) {
+	for _, importer := range Importers {
+		if name == "" && importer.Accepts(fileName, data) || name != "" && importer.Name() == name {
+			return importer, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("unrecognised import format for %s", fileName)
+	}
+	return nil, fmt.Errorf("unknown import format %q", name)
+}
+
+// ImportResult summarises an import
+type ImportResult struct {
+	Created int          `json:"Created"`
+	Updated int          `json:"Updated"`
+	Skipped []ImportSkip `json:"Skipped"`
+}
+
+// ImportDuplicate is an imported note that looks like an existing note with a
+// different id
+type ImportDuplicate struct {
+	Title          string    `json:"Title"`
+	CreatedAt      time.Time `json:"CreatedAt"`
+	ExistingNoteId uuid.UUID `json:"ExistingNoteId"`
+}
+
+// ImportPreview describes what an import would do without changing anything
+type ImportPreview struct {
+	Format string `json:"Format"`
+	// Notes that would be created and notes that would be updated by id
+	New     int `json:"New"`
+	Updates int `json:"Updates"`
+	// Creation dates of the oldest and newest imported notes
+	From       time.Time         `json:"From"`
+	To         time.Time         `json:"To"`
+	Duplicates []ImportDuplicate `json:"Duplicates"`
+	Skipped    []ImportSkip      `json:"Skipped"`
+}
+
+// PreviewImport reports what importing notes would do. Notes whose check-in
+// checkIns rejects are reported as skipped, as ImportNotes skips them.
+func PreviewImport(ctx context.Context, notesService NotesService, checkIns *CheckInService, format string, notes []Note, skipped []ImportSkip) (ImportPreview, error) {
+	preview := ImportPreview{
+		Format:     format,
+		Duplicates: []ImportDuplicate{},
+		Skipped:    append([]ImportSkip{}, skipped...),
+	}
+	existing, err := notesService.GetAllNotes(ctx)
+	if err != nil {
+		return preview, err
+	}
+	index := newDuplicateIndex(existing)
+
+	for _, note := range notes {
+		if note.CheckIn != nil {
+			err := checkIns.Validate(ctx, *note.CheckIn)
+			if errors.Is(err, ErrInvalidCheckIn) {
+				preview.Skipped = append(preview.Skipped, ImportSkip{Name: note.Title, Reason: err.Error()})
+				continue
+			}
+			if err != nil {
+				return preview, err
+			}
+		}
+		if preview.From.IsZero() || note.CreatedAt.Before(preview.From) {
+			preview.From = note.CreatedAt
+		}
+		if note.CreatedAt.After(preview.To) {
+			preview.To = note.CreatedAt
+		}
+		switch duplicateOf, exists := index.match(note); {
+		case exists:
+			preview.Updates++
+		case duplicateOf != uuid.Nil:
+			preview.Duplicates = append(preview.Duplicates, ImportDuplicate{Title: note.Title, CreatedAt: note.CreatedAt, ExistingNoteId: duplicateOf})
+		default:
+			preview.New++
+		}
+	}
+	return preview, nil
+}
+
+// ImportNotes upserts each note by id, so importing the same notes twice
+// leaves the journal unchanged. Notes that only look like an existing note
+// are imported too, PreviewImport reports them beforehand. Notes with an
+// invalid check-in, such as a mood out of range, are skipped.
+func ImportNotes(ctx context.Context, notesService NotesService, notes []Note, skipped []ImportSkip) (ImportResult, error) {
+	return ImportNotesWithProgress(ctx, notesService, notes, skipped, nil)
+}
+
+// ImportNotesWithProgress is ImportNotes calling onProgress, when not nil,
+// after every note.
+func ImportNotesWithProgress(ctx context.Context, notesService NotesService, notes []Note, skipped []ImportSkip, onProgress func(done int, total int)) (ImportResult, error) {
+	result := ImportResult{Skipped: append([]ImportSkip{}, skipped...)}
+	for i, note := range notes {
+		created, err := notesService.UpsertNote(ctx, note)
+		switch {
+		case errors.Is(err, ErrInvalidCheckIn):
+			result.Skipped = append(result.Skipped, ImportSkip{Name: note.Title, Reason: err.Error()})
+		case err != nil:
+			return result, err
+		case created:
+			result.Created++
+		default:
+			result.Updated++
+		}
+		if onProgress != nil {
+			onProgress(i+1, len(notes))
+		}
+	}
+	return result, nil
+}
+
+// duplicateIndex finds existing notes an imported note is the same as: by id,
+// or failing that by title and minute of creation or by identical content.
+// The last two are guesses, so they are only reported by PreviewImport.
+type duplicateIndex struct {
+	ids       map[uuid.UUID]bool
+	byTitle   map[string]uuid.UUID
+	byContent map[string]uuid.UUID
+}
+
+func newDuplicateIndex(notes []Note) *duplicateIndex {
+	index := &duplicateIndex{
+		ids:       map[uuid.UUID]bool{},
+		byTitle:   map[string]uuid.UUID{},
+		byContent: map[string]uuid.UUID{},
+	}
+	for _, note := range notes {
+		index.add(note)
+	}
+	return index
+}
+
+func (index *duplicateIndex) add(note Note) {
+	index.ids[note.NoteId] = true
+	index.byTitle[titleKey(note)] = note.NoteId
+	if content := strings.TrimSpace(note.Content); content != "" {
+		index.byContent[content] = note.NoteId
+	}
+}
+
+// match reports whether a note with the same id exists and, if not, the id of
+// a note it duplicates
+func (index *duplicateIndex) match(note Note) (uuid.UUID, bool) {
+	if index.ids[note.NoteId] {
+		return note.NoteId, true
+	}
+	if id, ok := index.byTitle[titleKey(note)]; ok {
+		return id, false
+	}
+	if content := strings.TrimSpace(note.Content); content != "" {
+		return index.byContent[content], false
+	}
+	return uuid.Nil, false
+}
+
+func titleKey(note Note) string {
+	return strings.ToLower(strings.TrimSpace(note.Title)) + "\n" + note.CreatedAt.UTC().Truncate(time.Minute).Format(time.RFC3339)
+}
+
+// MarkdownImporter reads a Markdown file or a zip of them, as written by the
+// Markdown export
+type MarkdownImporter struct{}
+
+func (MarkdownImporter) Name() string { return "markdown" }
+
+func (MarkdownImporter) Accepts(fileName string, data []byte) bool {
+	ext := strings.ToLower(path.Ext(fileName))
+	return ext == ".md" || ext == ".markdown" || ext == ".zip" && isZip(data)
+}
+
+func (MarkdownImporter) Parse(fileName string, data []byte) ([]Note, []ImportSkip, error) {
+	if isZip(data) {
+		return ReadMarkdownZip(data)
+	}
+	note, err := UnmarshalMarkdown(fileName, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []Note{note}, []ImportSkip{}, nil
+}
+
+// DayOneImporter reads a Day One JSON export, zipped or not
+type DayOneImporter struct{}
+
+func (DayOneImporter) Name() string { return "dayone" }
+
+func (DayOneImporter) Accepts(fileName string, data []byte) bool {
+	if isZip(data) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return false
+		}
+		for _, file := range archive.File {
+			if strings.EqualFold(path.Ext(file.Name), ".json") {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.EqualFold(path.Ext(fileName), ".json")
+}
+
+func (DayOneImporter) Parse(_ string, data []byte) ([]Note, []ImportSkip, error) {
+	if isZip(data) {
+		return ReadDayOneZip(data)
+	}
+	return ReadDayOneJSON(data)
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// Largest file read from an imported zip, so a small archive cannot expand
+// into more than the journal could hold
+const maxZipFileSize = 64 << 20
+
+var errZipFileTooLarge = fmt.Errorf("larger than %d MiB", maxZipFileSize>>20)
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// The sizes in the archive are not trusted, the data is counted instead
+	data, err := io.ReadAll(io.LimitReader(reader, maxZipFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxZipFileSize {
+		return nil, fmt.Errorf("%s: %w", file.Name, errZipFileTooLarge)
+	}
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"backend/db"
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestNotesService(t *testing.T) *NotesServiceImpl {
+	t.Helper()
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbClient.Close(ctx) })
+	return NewNotesServiceImpl(dbClient)
+}
+
+// TestMarkdownRoundTrip restores a Markdown export into an empty journal,
+// including notes that only differ by id
+func TestMarkdownRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newTestNotesService(t)
+	createdAt := time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC)
+	originals := []Note{
+		{NoteId: uuid.New(), Title: DefaultTitle, Content: "", CreatedAt: createdAt, UpdatedAt: createdAt, Tags: []string{}},
+		{NoteId: uuid.New(), Title: DefaultTitle, Content: "", CreatedAt: createdAt.Add(20 * time.Second), UpdatedAt: createdAt, Tags: []string{}},
+		{NoteId: uuid.New(), Title: "Twice", Content: "Same words\n", CreatedAt: createdAt, UpdatedAt: createdAt, Tags: []string{"copy"}},
+		{NoteId: uuid.New(), Title: "Again", Content: "Same words\n", CreatedAt: createdAt.Add(time.Hour), UpdatedAt: createdAt, Tags: []string{}},
+	}
+	for _, note := range originals {
+		if _, err := source.UpsertNote(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exported, err := source.GetAllNotes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := WriteMarkdownZip(&archive, exported); err != nil {
+		t.Fatal(err)
+	}
+
+	target := newTestNotesService(t)
+	notes, skipped, err := MarkdownImporter{}.Parse("export.zip", archive.Bytes())
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("got %d skipped: %v", len(skipped), err)
+	}
+	preview, err := PreviewImport(ctx, target, NewCheckInService(target.dbClient), "markdown", notes, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.New != len(originals) || len(preview.Duplicates) > 0 {
+		t.Errorf("got preview %+v, want %d new notes", preview, len(originals))
+	}
+	result, err := ImportNotes(ctx, target, notes, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != len(originals) || len(result.Skipped) > 0 {
+		t.Errorf("got result %+v, want %d created", result, len(originals))
+	}
+
+	for _, original := range originals {
+		restored, err := target.GetNote(ctx, original.NoteId)
+		if err != nil {
+			t.Errorf("note %q at %v was not restored: %v", original.Title, original.CreatedAt, err)
+			continue
+		}
+		if restored.Title != original.Title || restored.Content != original.Content || !restored.CreatedAt.Equal(original.CreatedAt) {
+			t.Errorf("got %q %q at %v, want %q %q at %v", restored.Title, restored.Content, restored.CreatedAt, original.Title, original.Content, original.CreatedAt)
+		}
+	}
+
+	// Importing the export again only updates the notes
+	if result, err := ImportNotes(ctx, target, notes, skipped); err != nil || result.Updated != len(originals) {
+		t.Errorf("got result %+v from importing again: %v", result, err)
+	}
+}
+
+func TestImportSkipsInvalidCheckIns(t *testing.T) {
+	ctx := context.Background()
+	notesService := newTestNotesService(t)
+	createdAt := time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC)
+	valid := Note{NoteId: uuid.New(), Title: "Calm", CreatedAt: createdAt, UpdatedAt: createdAt, CheckIn: &CheckIn{Mood: ptr(3)}}
+	invalid := Note{NoteId: uuid.New(), Title: "Elated", CreatedAt: createdAt, UpdatedAt: createdAt, CheckIn: &CheckIn{Mood: ptr(9)}}
+	var archive bytes.Buffer
+	if err := WriteMarkdownZip(&archive, []Note{valid, invalid}); err != nil {
+		t.Fatal(err)
+	}
+	notes, skipped, err := MarkdownImporter{}.Parse("export.zip", archive.Bytes())
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("got %d notes: %v", len(notes), err)
+	}
+
+	preview, err := PreviewImport(ctx, notesService, NewCheckInService(notesService.dbClient), "markdown", notes, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.New != 1 || len(preview.Skipped) != 1 || preview.Skipped[0].Name != "Elated" {
+		t.Errorf("got preview %+v, want Elated skipped", preview)
+	}
+	result, err := ImportNotes(ctx, notesService, notes, skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 1 || len(result.Skipped) != 1 || result.Skipped[0].Name != "Elated" {
+		t.Errorf("got result %+v, want Elated skipped", result)
+	}
+	if _, err := notesService.GetNote(ctx, invalid.NoteId); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("the note with an invalid check-in was stored: %v", err)
+	}
+
+	// Updates are checked too, and leave the note as it was
+	valid.Content = "Changed"
+	valid.CheckIn = &CheckIn{Fields: map[string]float64{"unknown": 1}}
+	if _, err := notesService.UpdateNote(ctx, valid); !errors.Is(err, ErrInvalidCheckIn) {
+		t.Errorf("got %v updating with an unknown field, want ErrInvalidCheckIn", err)
+	}
+	stored, err := notesService.GetNote(ctx, valid.NoteId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Content != "" || stored.CheckIn == nil || *stored.CheckIn.Mood != 3 {
+		t.Errorf("got %q with check-in %+v after a rejected update", stored.Content, stored.CheckIn)
+	}
+}
+
+func TestReadZipFileLimitsSize(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	file, err := writer.Create("bomb.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Compresses to well under the size of the upload
+	if _, err := file.Write(make([]byte, maxZipFileSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = ReadMarkdownZip(archive.Bytes())
+	if !errors.Is(err, errZipFileTooLarge) {
+		t.Errorf("got %v, want errZipFileTooLarge", err)
+	}
+}
@@ -0,0 +1,139 @@
+package notes_service
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JrnlImporter reads a jrnl plain-text journal, where every entry starts with
+// a bracketed date followed by its title:
+//
+//	[2024-01-05 09:14] Long walk. Went along the river with @sam.
+//	The rest of the entry...
+//
+// The title is the first sentence. @tags become note tags and a starred
+// entry is tagged "starred".
+type JrnlImporter struct{}
+
+var (
+	jrnlEntryHeader = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2}[ T][^\]]+)\] ?(.*)$`)
+	jrnlTag         = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_-]+)`)
+	jrnlSentenceEnd = regexp.MustCompile(`[.!?](\s|$)`)
+	jrnlTimeFormats = []string{
+		"2006-01-02 15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 03:04 PM",
+		"2006-01-02 03:04:05 PM",
+		"2006-01-02T15:04:05",
+	}
+	jrnlNamespace = uuid.MustParse("4e5c2a96-0c0b-4a54-9d52-1f5f0b6c1d2e")
+)
+
+func (JrnlImporter) Name() string { return "jrnl" }
+
+func (JrnlImporter) Accepts(fileName string, data []byte) bool {
+	firstLine, _, _ := bytes.Cut(bytes.TrimLeft(data, "\ufeff\r\n\t "), []byte("\n"))
+	ext := strings.ToLower(path.Ext(fileName))
+	return (ext == ".txt" || ext == "") && jrnlEntryHeader.Match(bytes.TrimRight(firstLine, "\r"))
+}
+
+func (JrnlImporter) Parse(_ string, data []byte) ([]Note, []ImportSkip, error) {
+	notes := []Note{}
+	skipped := []ImportSkip{}
+
+	var header string
+	var body []string
+	lineNumber, headerLine := 0, 0
+	flush := func() {
+		if header == "" {
+			return
+		}
+		note, err := jrnlNote(header, body)
+		if err != nil {
+			skipped = append(skipped, ImportSkip{Name: fmt.Sprintf("line %d", headerLine), Reason: err.Error()})
+		} else {
+			notes = append(notes, note)
+		}
+	}
+
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lineNumber++
+		if jrnlEntryHeader.MatchString(line) {
+			flush()
+			header, body, headerLine = line, nil, lineNumber
+			continue
+		}
+		if header == "" {
+			if strings.TrimSpace(line) != "" {
+				return nil, nil, fmt.Errorf("line %d: expected an entry starting with [date]", lineNumber)
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	if len(notes) == 0 && len(skipped) == 0 {
+		return nil, nil, fmt.Errorf("no jrnl entries found")
+	}
+	return notes, skipped, nil
+}
+
+func jrnlNote(header string, body []string) (Note, error) {
+	match := jrnlEntryHeader.FindStringSubmatch(header)
+	createdAt, err := parseJrnlTime(match[1])
+	if err != nil {
+		return Note{}, err
+	}
+
+	firstLine := strings.TrimSpace(match[2])
+	tags := []string{}
+	if strings.HasPrefix(firstLine, "*") || strings.HasSuffix(firstLine, " *") {
+		tags = append(tags, "starred")
+		firstLine = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(firstLine, "*"), " *"))
+	}
+
+	// The title is the first sentence, the rest of the line starts the content
+	title, rest := firstLine, ""
+	if loc := jrnlSentenceEnd.FindStringIndex(firstLine); loc != nil {
+		title, rest = firstLine[:loc[0]+1], strings.TrimSpace(firstLine[loc[1]:])
+	}
+	content := strings.TrimSpace(strings.Join(append([]string{rest}, body...), "\n"))
+	if content != "" {
+		content += "\n"
+	}
+
+	for _, source := range []string{title, content} {
+		for _, tag := range jrnlTag.FindAllStringSubmatch(source, -1) {
+			tags = append(tags, tag[1])
+		}
+	}
+	if title == "" {
+		title = DefaultTitle
+	}
+
+	return Note{
+		NoteId:    uuid.NewSHA1(jrnlNamespace, []byte(createdAt.Format(time.RFC3339)+"\n"+title)),
+		Title:     title,
+		Content:   content,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+		Tags:      NormaliseTags(tags),
+	}, nil
+}
+
+// parseJrnlTime reads the entry date in the local time zone, as jrnl writes it
+func parseJrnlTime(value string) (time.Time, error) {
+	for _, format := range jrnlTimeFormats {
+		if t, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
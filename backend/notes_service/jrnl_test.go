@@ -0,0 +1,62 @@
+package notes_service
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestJrnlImporter(t *testing.T) {
+	journal := "\ufeff[2024-01-05 09:14] Long walk. Went along the river with @sam.\r\n" +
+		"Saw a heron.\r\n" +
+		"\r\n" +
+		"[2024-01-06 21:00] * Starred day\n" +
+		"Tagged @work-life in the body.\n" +
+		"[2024-01-07 07:30 PM] No punctuation here\n" +
+		"[2024-13-40 10:00] Bad date\n" +
+		"[2024-01-08T06:05:09] Seconds!\n"
+	if !(JrnlImporter{}).Accepts("journal.txt", []byte(journal)) {
+		t.Fatal("the journal was not recognised")
+	}
+	notes, skipped, err := JrnlImporter{}.Parse("journal.txt", []byte(journal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		title     string
+		content   string
+		createdAt time.Time
+		tags      []string
+	}{
+		{"Long walk.", "Went along the river with @sam.\nSaw a heron.\n", time.Date(2024, 1, 5, 9, 14, 0, 0, time.Local), []string{"sam"}},
+		{"Starred day", "Tagged @work-life in the body.\n", time.Date(2024, 1, 6, 21, 0, 0, 0, time.Local), []string{"starred", "work-life"}},
+		{"No punctuation here", "", time.Date(2024, 1, 7, 19, 30, 0, 0, time.Local), []string{}},
+		{"Seconds!", "", time.Date(2024, 1, 8, 6, 5, 9, 0, time.Local), []string{}},
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("got %d notes, want %d", len(notes), len(want))
+	}
+	for i, note := range notes {
+		if note.Title != want[i].title || note.Content != want[i].content || !note.CreatedAt.Equal(want[i].createdAt) || !slices.Equal(note.Tags, want[i].tags) {
+			t.Errorf("note %d: got %q %q at %v tagged %v, want %q %q at %v tagged %v", i,
+				note.Title, note.Content, note.CreatedAt, note.Tags, want[i].title, want[i].content, want[i].createdAt, want[i].tags)
+		}
+	}
+	if len(skipped) != 1 || skipped[0].Name != "line 7" {
+		t.Errorf("got skipped %+v, want the entry on line 7", skipped)
+	}
+
+	// Ids are derived from the entry, so importing again updates the notes
+	again, _, err := JrnlImporter{}.Parse("journal.txt", []byte(journal))
+	if err != nil || again[0].NoteId != notes[0].NoteId {
+		t.Errorf("got id %v then %v: %v", notes[0].NoteId, again[0].NoteId, err)
+	}
+
+	if _, _, err := (JrnlImporter{}).Parse("notes.txt", []byte("Just some text\n")); err == nil {
+		t.Error("text without an entry header was accepted")
+	}
+	if (JrnlImporter{}).Accepts("notes.md", []byte(journal)) {
+		t.Error("a Markdown file was taken for jrnl")
+	}
+}
@@ -0,0 +1,411 @@
+package notes_service
+
+import (
+	"backend/db"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Link is a [[wiki link]] in the content of a note, written [[Target]] or
+// [[Target|label]]. The target is the title of another note or a date such
+// as 2026-10-01, ignoring case and extra whitespace.
+type Link struct {
+	// The target as written, e.g. "Another entry" or "2026-10-01"
+	Target string `json:"Target"`
+	// The text shown for the link instead of the target, empty for none
+	Label string `json:"Label"`
+	// Where the link starts and ends in the note's content, brackets included,
+	// in characters
+	StartOffset int `json:"StartOffset"`
+	EndOffset   int `json:"EndOffset"`
+	// The note the link resolves to, nil while no note does
+	TargetNoteId *uuid.UUID `json:"TargetNoteId"`
+	TargetTitle  string     `json:"TargetTitle,omitempty"`
+}
+
+// Backlink is a link to a note from another note
+type Backlink struct {
+	NoteId        uuid.UUID `json:"NoteId"`
+	NoteTitle     string    `json:"NoteTitle"`
+	NoteCreatedAt time.Time `json:"NoteCreatedAt"`
+	// The target as written in the linking note
+	Target string `json:"Target"`
+	// The line of the linking note the link is on, shortened around the link
+	// when long
+	Excerpt     string `json:"Excerpt"`
+	StartOffset int    `json:"StartOffset"`
+	EndOffset   int    `json:"EndOffset"`
+}
+
+// LinkService reads the links between notes from note_links, which the notes
+// service keeps up to date
+type LinkService struct {
+	dbClient *db_client.DBClient
+}
+
+func NewLinkService(dbClient *db_client.DBClient) *LinkService {
+	return &LinkService{dbClient: dbClient}
+}
+
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+type parsedLink struct {
+	target string
+	label  string
+	// In characters, as stored
+	start int
+	end   int
+	// In bytes, for rewriting the link
+	byteStart int
+	byteEnd   int
+}
+
+func parseLinks(content string) []parsedLink {
+	links := []parsedLink{}
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(content, -1) {
+		target, label, _ := strings.Cut(content[match[2]:match[3]], "|")
+		target = strings.Join(strings.Fields(target), " ")
+		if target == "" {
+			continue
+		}
+		start := utf8.RuneCountInString(content[:match[0]])
+		links = append(links, parsedLink{
+			target:    target,
+			label:     strings.TrimSpace(label),
+			start:     start,
+			end:       start + utf8.RuneCountInString(content[match[0]:match[1]]),
+			byteStart: match[0],
+			byteEnd:   match[1],
+		})
+	}
+	return links
+}
+
+// linkKey is the form link targets and titles are matched in, so
+// "Another entry" and "another  entry" are one
+func linkKey(target string) string {
+	return strings.ToLower(strings.Join(strings.Fields(target), " "))
+}
+
+// refreshLinks records the links of a note as it is stored and resolves the
+// links waiting for a note like it. previousTitle is the title the note had
+// before it was written, empty for a new note; links to the note by that
+// title are changed to the new one in the notes they are in, whose ids are
+// returned. Those notes are marked as updated at updatedAt.
+func refreshLinks(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, previousTitle string, updatedAt time.Time) ([]uuid.UUID, error) {
+	var title, content string
+	var createdAt time.Time
+	err := executor.QueryRowContext(ctx, "SELECT title, content, created_at FROM notes WHERE id = ?", noteId).Scan(&title, &content, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	titleKey := linkKey(title)
+	day := createdAt.Local().Format(time.DateOnly)
+	_, err = executor.ExecContext(ctx, `INSERT INTO note_link_keys (note_id, title_key, day) VALUES (?, ?, ?)
+		ON CONFLICT (note_id) DO UPDATE SET title_key = excluded.title_key, day = excluded.day`, noteId, titleKey, day)
+	if err != nil {
+		return nil, err
+	}
+	if err := replaceLinks(ctx, executor, noteId, content); err != nil {
+		return nil, err
+	}
+	rewritten := []uuid.UUID{}
+	if previousTitle != "" && linkKey(previousTitle) != titleKey {
+		rewritten, err = retitleLinks(ctx, executor, noteId, previousTitle, title, updatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Links resolved to the note by a title or day it no longer has look for
+	// another note
+	_, err = executor.ExecContext(ctx, "UPDATE note_links SET target_id = NULL WHERE target_id = ? AND target_key NOT IN (?, ?)", noteId, titleKey, day)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveLinks(ctx, executor); err != nil {
+		return nil, err
+	}
+	return rewritten, nil
+}
+
+// replaceLinks records the links in content as those of a note. Links to a
+// target the note already linked to keep the note they resolved to, so they
+// are not moved by other notes being given the same title.
+func replaceLinks(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, content string) error {
+	rows, err := executor.QueryContext(ctx, "SELECT target_key, target_id FROM note_links WHERE source_id = ? AND target_id IS NOT NULL ORDER BY position", noteId)
+	if err != nil {
+		return err
+	}
+	resolved := map[string]uuid.UUID{}
+	for rows.Next() {
+		var key string
+		var targetId uuid.UUID
+		if err := rows.Scan(&key, &targetId); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := resolved[key]; !ok {
+			resolved[key] = targetId
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := executor.ExecContext(ctx, "DELETE FROM note_links WHERE source_id = ?", noteId); err != nil {
+		return err
+	}
+	for position, link := range parseLinks(content) {
+		key := linkKey(link.target)
+		var targetId *uuid.UUID
+		if id, ok := resolved[key]; ok {
+			targetId = &id
+		}
+		_, err := executor.ExecContext(ctx, `INSERT INTO note_links (source_id, position, target, target_key, label, start_offset, end_offset, target_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, noteId, position, link.target, key, link.label, link.start, link.end, targetId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retitleLinks rewrites the links to a note by its previous title in the
+// notes linking to it, so they keep pointing at the note once it is renamed.
+// Labels are kept. Titles that cannot be written in a link, such as ones with
+// brackets, leave the links to be resolved again by their old title. It
+// returns the ids of the other notes it rewrote, which are marked as updated
+// at updatedAt.
+func retitleLinks(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, from string, to string, updatedAt time.Time) ([]uuid.UUID, error) {
+	to, ok := linkableTitle(to)
+	if !ok {
+		return []uuid.UUID{}, nil
+	}
+	fromKey := linkKey(from)
+	rows, err := executor.QueryContext(ctx, "SELECT DISTINCT source_id FROM note_links WHERE target_id = ? AND target_key = ?", noteId, fromKey)
+	if err != nil {
+		return nil, err
+	}
+	sourceIds := []uuid.UUID{}
+	for rows.Next() {
+		var sourceId uuid.UUID
+		if err := rows.Scan(&sourceId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sourceIds = append(sourceIds, sourceId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rewrittenIds := []uuid.UUID{}
+	for _, sourceId := range sourceIds {
+		var content string
+		if err := executor.QueryRowContext(ctx, "SELECT content FROM notes WHERE id = ?", sourceId).Scan(&content); err != nil {
+			return nil, err
+		}
+		rewritten := rewriteLinks(content, fromKey, to)
+		_, err := executor.ExecContext(ctx, "UPDATE notes SET content = ?, updated_at = ? WHERE id = ?", rewritten, updatedAt, sourceId)
+		if err != nil {
+			return nil, err
+		}
+		if err := refreshNoteStats(ctx, executor, sourceId); err != nil {
+			return nil, err
+		}
+		// Moved to the new title first so the rewritten links keep the note
+		_, err = executor.ExecContext(ctx, "UPDATE note_links SET target = ?, target_key = ? WHERE source_id = ? AND target_id = ? AND target_key = ?",
+			to, linkKey(to), sourceId, noteId, fromKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := replaceLinks(ctx, executor, sourceId, rewritten); err != nil {
+			return nil, err
+		}
+		if sourceId != noteId {
+			rewrittenIds = append(rewrittenIds, sourceId)
+		}
+	}
+	return rewrittenIds, nil
+}
+
+// linkableTitle returns title as it is written in a link, or false when it
+// cannot be written in one, such as when it has brackets
+func linkableTitle(title string) (string, bool) {
+	title = strings.Join(strings.Fields(title), " ")
+	return title, title != "" && !strings.ContainsAny(title, "[]|")
+}
+
+// rewriteLinks changes the links in content whose target has fromKey to link
+// to, keeping their labels
+func rewriteLinks(content string, fromKey string, to string) string {
+	var rewritten strings.Builder
+	last := 0
+	for _, link := range parseLinks(content) {
+		if linkKey(link.target) != fromKey {
+			continue
+		}
+		rewritten.WriteString(content[last:link.byteStart])
+		rewritten.WriteString("[[" + to)
+		if _, label, ok := strings.Cut(content[link.byteStart+2:link.byteEnd-2], "|"); ok {
+			rewritten.WriteString("|" + label)
+		}
+		rewritten.WriteString("]]")
+		last = link.byteEnd
+	}
+	rewritten.WriteString(content[last:])
+	return rewritten.String()
+}
+
+// resolveLinks resolves the links no note was found for yet, to the oldest
+// other note titled as their target or, for a date, created on that day. A
+// title is preferred, so a note titled "2026-10-01" wins over the entries of
+// that day.
+func resolveLinks(ctx context.Context, executor db_client.Executor) error {
+	_, err := executor.ExecContext(ctx, `UPDATE note_links SET target_id = COALESCE(
+		(SELECT k.note_id FROM note_link_keys k JOIN notes n ON n.id = k.note_id
+			WHERE k.title_key = note_links.target_key AND k.note_id != note_links.source_id ORDER BY julianday(n.created_at), n.id LIMIT 1),
+		(SELECT k.note_id FROM note_link_keys k JOIN notes n ON n.id = k.note_id
+			WHERE k.day = note_links.target_key AND k.note_id != note_links.source_id ORDER BY julianday(n.created_at), n.id LIMIT 1))
+		WHERE target_id IS NULL`)
+	return err
+}
+
+// backfillLinks records the links of notes that have none recorded, those
+// written before links were kept or restored from an older backup
+func (linkService *LinkService) backfillLinks(ctx context.Context) error {
+	rows, err := linkService.dbClient.QueryContext(ctx, "SELECT n.id FROM notes n LEFT JOIN note_link_keys k ON k.note_id = n.id WHERE k.note_id IS NULL")
+	if err != nil {
+		return err
+	}
+	missing := []uuid.UUID{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		if err := rows.Scan(&noteId); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, noteId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(missing) == 0 {
+		return err
+	}
+	return linkService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		for _, noteId := range missing {
+			if _, err := refreshLinks(ctx, tx, noteId, "", time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Links returns the links in a note in the order they appear
+func (linkService *LinkService) Links(ctx context.Context, noteId uuid.UUID) ([]Link, error) {
+	return linkService.loadLinks(ctx, noteId, "")
+}
+
+// Unresolved returns the links in a note no note was found for, e.g. to an
+// entry not written yet
+func (linkService *LinkService) Unresolved(ctx context.Context, noteId uuid.UUID) ([]Link, error) {
+	return linkService.loadLinks(ctx, noteId, " AND l.target_id IS NULL")
+}
+
+func (linkService *LinkService) loadLinks(ctx context.Context, noteId uuid.UUID, condition string) ([]Link, error) {
+	if err := linkService.backfillLinks(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := getNote(ctx, linkService.dbClient, noteId); err != nil {
+		return nil, err
+	}
+	rows, err := linkService.dbClient.QueryContext(ctx, `SELECT l.target, l.label, l.start_offset, l.end_offset, l.target_id, COALESCE(n.title, '')
+		FROM note_links l LEFT JOIN notes n ON n.id = l.target_id WHERE l.source_id = ?`+condition+" ORDER BY l.position", noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []Link{}
+	for rows.Next() {
+		var link Link
+		if err := rows.Scan(&link.Target, &link.Label, &link.StartOffset, &link.EndOffset, &link.TargetNoteId, &link.TargetTitle); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Backlinks returns the links to a note from other notes, the newest notes
+// first
+func (linkService *LinkService) Backlinks(ctx context.Context, noteId uuid.UUID) ([]Backlink, error) {
+	if err := linkService.backfillLinks(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := getNote(ctx, linkService.dbClient, noteId); err != nil {
+		return nil, err
+	}
+	rows, err := linkService.dbClient.QueryContext(ctx, `SELECT n.id, n.title, n.created_at, n.content, l.target, l.start_offset, l.end_offset
+		FROM note_links l JOIN notes n ON n.id = l.source_id WHERE l.target_id = ? ORDER BY julianday(n.created_at) DESC, n.id, l.position`, noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backlinks := []Backlink{}
+	for rows.Next() {
+		var backlink Backlink
+		var content string
+		err := rows.Scan(&backlink.NoteId, &backlink.NoteTitle, &backlink.NoteCreatedAt, &content, &backlink.Target, &backlink.StartOffset, &backlink.EndOffset)
+		if err != nil {
+			return nil, err
+		}
+		backlink.Excerpt = excerpt(content, backlink.StartOffset, backlink.EndOffset)
+		backlinks = append(backlinks, backlink)
+	}
+	return backlinks, rows.Err()
+}
+
+const maxExcerptLength = 200
+
+// excerpt returns the line of content from start to end in, in characters,
+// cut down to the words around them when long
+func excerpt(content string, start int, end int) string {
+	runes := []rune(content)
+	if start < 0 || end > len(runes) || start > end {
+		return ""
+	}
+	lineStart, lineEnd := start, end
+	for lineStart > 0 && runes[lineStart-1] != '\n' {
+		lineStart--
+	}
+	for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+		lineEnd++
+	}
+	prefix, suffix := "", ""
+	if lineEnd-lineStart > maxExcerptLength {
+		around := max(0, (maxExcerptLength-(end-start))/2)
+		if start-around > lineStart {
+			lineStart = start - around
+			prefix = "…"
+		}
+		if end+around < lineEnd {
+			lineEnd = end + around
+			suffix = "…"
+		}
+	}
+	return prefix + strings.TrimSpace(string(runes[lineStart:lineEnd])) + suffix
+}
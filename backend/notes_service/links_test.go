@@ -0,0 +1,90 @@
+package notes_service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRenameRewritesLinks(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		testRenameRewritesLinks(t, newTestNotesService(t))
+	})
+	t.Run("memory", func(t *testing.T) {
+		testRenameRewritesLinks(t, NewMemoryNotesService())
+	})
+}
+
+func testRenameRewritesLinks(t *testing.T, inner NotesService) {
+	ctx := context.Background()
+	changes := 0
+	notesService := NotifyOnChange(inner, func() { changes++ })
+
+	target, err := notesService.CreateNoteWithContent(ctx, "Garden", "Tomatoes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	linking, err := notesService.CreateNoteWithContent(ctx, "Monday", "Watered the [[garden|beds]]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notesService.CreateNoteWithContent(ctx, "Tuesday", "Nothing to link"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Automatic renames edit no other note, so links by the old title are
+	// resolved by it again
+	changes = 0
+	renamed, err := notesService.RenameNote(ctx, target.NoteId, "Garden", "Allotment")
+	if err != nil || !renamed {
+		t.Fatalf("got renamed %v: %v", renamed, err)
+	}
+	if changes != 1 {
+		t.Errorf("got %d notifications, want 1", changes)
+	}
+	stored, err := notesService.GetNote(ctx, linking.NoteId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Content != linking.Content || !stored.UpdatedAt.Equal(linking.UpdatedAt) {
+		t.Errorf("the rename changed the linking note to %q at %v", stored.Content, stored.UpdatedAt)
+	}
+	if stored, err := notesService.GetNote(ctx, target.NoteId); err != nil || !stored.UpdatedAt.Equal(target.UpdatedAt) {
+		t.Errorf("the rename moved updated_at from %v to %v: %v", target.UpdatedAt, stored.UpdatedAt, err)
+	}
+
+	// A rename that lost the race to another one changes nothing
+	renamed, err = notesService.RenameNote(ctx, target.NoteId, "Garden", "Plot")
+	if err != nil || renamed || changes != 1 {
+		t.Errorf("got renamed %v and %d notifications: %v", renamed, changes, err)
+	}
+
+	// Renaming it back keeps the links resolved, so the user's rename
+	// rewrites them
+	if renamed, err := notesService.RenameNote(ctx, target.NoteId, "Allotment", "Garden"); err != nil || !renamed {
+		t.Fatalf("got renamed %v: %v", renamed, err)
+	}
+	target.Title = "Plot"
+	rewritten, err := notesService.UpdateNote(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rewritten, []uuid.UUID{linking.NoteId}) {
+		t.Errorf("got rewritten %v from UpdateNote, want only %v", rewritten, linking.NoteId)
+	}
+	stored, err = notesService.GetNote(ctx, linking.NoteId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Content != "Watered the [[Plot|beds]]" || !stored.UpdatedAt.After(linking.UpdatedAt) {
+		t.Errorf("got %q updated at %v after a rename by the user", stored.Content, stored.UpdatedAt)
+	}
+
+	// Editing the content alone rewrites nothing
+	target.Content = "Tomatoes and beans"
+	if rewritten, err = notesService.UpdateNote(ctx, target); err != nil || len(rewritten) > 0 {
+		t.Errorf("got rewritten %v: %v", rewritten, err)
+	}
+}
@@ -0,0 +1,273 @@
+package notes_service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Notes are exported as Markdown files with YAML front matter carrying
+// everything that is not part of the content, so that importing an export
+// restores the notes exactly.
+type markdownFrontMatter struct {
+	Id        string    `yaml:"id"`
+	Title     string    `yaml:"title"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+	Tags      []string  `yaml:"tags"`
+	CheckIn   *CheckIn  `yaml:"checkin,omitempty"`
+}
+
+// ImportSkip records a file or entry that could not be imported
+type ImportSkip struct {
+	Name   string `json:"Name"`
+	Reason string `json:"Reason"`
+}
+
+const frontMatterDelimiter = "---"
+
+// MarshalMarkdown renders a note as a Markdown document with front matter
+func MarshalMarkdown(note Note) ([]byte, error) {
+	frontMatter, err := yaml.Marshal(markdownFrontMatter{
+		Id:        note.NoteId.String(),
+		Title:     note.Title,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+		Tags:      note.Tags,
+		CheckIn:   note.CheckIn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.Write(frontMatter)
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.WriteString(note.Content)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMarkdown parses a Markdown document into a note. Documents without
+// front matter (or with only some fields) are accepted: the title falls back
+// to the file name, timestamps to now and the id to a new one.
+func UnmarshalMarkdown(name string, data []byte) (Note, error) {
+	frontMatter, content, err := splitFrontMatter(string(data))
+	if err != nil {
+		return Note{}, err
+	}
+
+	var meta markdownFrontMatter
+	if err := yaml.Unmarshal([]byte(frontMatter), &meta); err != nil {
+		return Note{}, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	note := Note{
+		NoteId:    uuid.New(),
+		Title:     meta.Title,
+		Content:   content,
+		CreatedAt: meta.CreatedAt,
+		UpdatedAt: meta.UpdatedAt,
+		Tags:      NormaliseTags(meta.Tags),
+		CheckIn:   meta.CheckIn,
+	}
+	if meta.Id != "" {
+		id, err := uuid.Parse(meta.Id)
+		if err != nil {
+			return Note{}, fmt.Errorf("invalid id %q: %w", meta.Id, err)
+		}
+		note.NoteId = id
+	}
+	if note.Title == "" {
+		note.Title = titleFromFileName(name)
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = note.CreatedAt
+	}
+	return note, nil
+}
+
+// MarkdownNoteId reads the note id from a document's front matter, reporting
+// false when it has none.
+func MarkdownNoteId(data []byte) (uuid.UUID, bool) {
+	frontMatter, _, err := splitFrontMatter(string(data))
+	if err != nil {
+		return uuid.UUID{}, false
+	}
+	var meta markdownFrontMatter
+	if err := yaml.Unmarshal([]byte(frontMatter), &meta); err != nil {
+		return uuid.UUID{}, false
+	}
+	id, err := uuid.Parse(meta.Id)
+	return id, err == nil
+}
+
+// splitFrontMatter separates the YAML front matter from the content. The
+// content is returned exactly as written after the closing delimiter.
+func splitFrontMatter(document string) (string, string, error) {
+	firstLine, rest, found := strings.Cut(document, "\n")
+	if !found || strings.TrimRight(firstLine, "\r") != frontMatterDelimiter {
+		return "", document, nil
+	}
+
+	offset := 0
+	for offset < len(rest) {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		next := offset + len(line) + 1
+		if strings.TrimRight(line, "\r") == frontMatterDelimiter {
+			if next > len(rest) {
+				next = len(rest)
+			}
+			return rest[:offset], rest[next:], nil
+		}
+		offset = next
+	}
+	return "", "", fmt.Errorf("front matter is not closed")
+}
+
+var (
+	unsafeFileNameChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	datePrefix          = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-?`)
+)
+
+// MarkdownFileName names an exported note by its creation date and title,
+// e.g. 2026-10-01-weekly-review.md
+func MarkdownFileName(note Note) string {
+	return note.CreatedAt.Format("2006-01-02") + "-" + slugify(note.Title, "untitled") + ".md"
+}
+
+// slugify turns text into a lower case, dash separated name that is safe to
+// use in file names and URLs
+func slugify(text string, fallback string) string {
+	slug := strings.Trim(unsafeFileNameChars.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if runes := []rune(slug); len(runes) > 60 {
+		slug = strings.TrimRight(string(runes[:60]), "-")
+	}
+	if slug == "" {
+		return fallback
+	}
+	return slug
+}
+
+func titleFromFileName(name string) string {
+	base := strings.TrimSuffix(path.Base(filepath.ToSlash(name)), path.Ext(name))
+	base = datePrefix.ReplaceAllString(base, "")
+	base = strings.TrimSpace(strings.ReplaceAll(base, "-", " "))
+	if base == "" {
+		return DefaultTitle
+	}
+	return strings.ToUpper(base[:1]) + base[1:]
+}
+
+// markdownFiles pairs every note with a unique file name, oldest first
+func markdownFiles(notes []Note) map[string]Note {
+	sorted := append([]Note{}, notes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	files := map[string]Note{}
+	for _, note := range sorted {
+		name := MarkdownFileName(note)
+		if _, taken := files[name]; taken {
+			name = strings.TrimSuffix(name, ".md") + "-" + note.NoteId.String()[:8] + ".md"
+		}
+		files[name] = note
+	}
+	return files
+}
+
+// WriteMarkdownZip writes the notes to w as a zip of Markdown files
+func WriteMarkdownZip(w io.Writer, notes []Note) error {
+	archive := zip.NewWriter(w)
+	for name, note := range markdownFiles(notes) {
+		data, err := MarshalMarkdown(note)
+		if err != nil {
+			return err
+		}
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: note.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// WriteMarkdownDir writes the notes into dir as Markdown files
+func WriteMarkdownDir(dir string, notes []Note) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for name, note := range markdownFiles(notes) {
+		data, err := MarshalMarkdown(note)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadMarkdownZip parses every Markdown file in a zip archive. Files that are
+// not Markdown or cannot be parsed are reported as skipped.
+func ReadMarkdownZip(data []byte) ([]Note, []ImportSkip, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	notes := []Note{}
+	skipped := []ImportSkip{}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if !strings.EqualFold(path.Ext(file.Name), ".md") {
+			skipped = append(skipped, ImportSkip{Name: file.Name, Reason: "not a Markdown file"})
+			continue
+		}
+		note, err := readZipMarkdown(file)
+		// An archive this large is not an export, so it is not read further
+		if errors.Is(err, errZipFileTooLarge) {
+			return nil, nil, err
+		}
+		if err != nil {
+			skipped = append(skipped, ImportSkip{Name: file.Name, Reason: err.Error()})
+			continue
+		}
+		notes = append(notes, note)
+	}
+	return notes, skipped, nil
+}
+
+func readZipMarkdown(file *zip.File) (Note, error) {
+	data, err := readZipFile(file)
+	if err != nil {
+		return Note{}, err
+	}
+	return UnmarshalMarkdown(file.Name, data)
+}
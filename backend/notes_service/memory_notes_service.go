@@ -0,0 +1,182 @@
+package notes_service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryNotesService is an in-memory NotesService for exercising handlers
+// without a database. Links are matched to notes by title alone when a note
+// is renamed, and check-ins are stored without being validated, as there are
+// no custom fields to check them against.
+type MemoryNotesService struct {
+	mu    sync.Mutex
+	notes map[uuid.UUID]Note
+}
+
+func NewMemoryNotesService(notes ...Note) *MemoryNotesService {
+	service := &MemoryNotesService{notes: map[uuid.UUID]Note{}}
+	for _, note := range notes {
+		service.notes[note.NoteId] = note
+	}
+	return service
+}
+
+func (notesService *MemoryNotesService) CreateNote(ctx context.Context, title string) (Note, error) {
+	return notesService.CreateNoteWithContent(ctx, title, "")
+}
+
+func (notesService *MemoryNotesService) CreateNoteWithContent(_ context.Context, title string, content string) (Note, error) {
+	return notesService.create(title, content, ""), nil
+}
+
+func (notesService *MemoryNotesService) CreateNoteWithPrompt(_ context.Context, prompt NotePrompt) (Note, error) {
+	return notesService.create(DefaultTitle, "", prompt.Text), nil
+}
+
+func (notesService *MemoryNotesService) create(title string, content string, prompt string) Note {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	now := time.Now()
+	note := Note{
+		NoteId:    uuid.New(),
+		Title:     title,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Tags:      []string{},
+		Prompt:    prompt,
+	}
+	notesService.notes[note.NoteId] = note
+	return note
+}
+
+func (notesService *MemoryNotesService) GetAllNotes(_ context.Context) ([]Note, error) {
+	return notesService.filter(func(Note) bool { return true }), nil
+}
+
+func (notesService *MemoryNotesService) GetNote(_ context.Context, id uuid.UUID) (Note, error) {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	note, ok := notesService.notes[id]
+	if !ok {
+		return Note{}, fmt.Errorf("%w: %v", ErrNoteNotFound, id)
+	}
+	return note, nil
+}
+
+func (notesService *MemoryNotesService) UpdateNote(_ context.Context, note Note) ([]uuid.UUID, error) {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	existing, ok := notesService.notes[note.NoteId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNoteNotFound, note.NoteId)
+	}
+	now := time.Now()
+	rewritten := notesService.retitleLinks(note.NoteId, existing.Title, note.Title, now)
+	existing.Title = note.Title
+	existing.Content = note.Content
+	existing.UpdatedAt = now
+	if note.Tags != nil {
+		existing.Tags = NormaliseTags(note.Tags)
+	}
+	if note.CheckIn != nil {
+		existing.CheckIn = memoryCheckIn(*note.CheckIn)
+	}
+	notesService.notes[note.NoteId] = existing
+	return rewritten, nil
+}
+
+func (notesService *MemoryNotesService) UpsertNote(_ context.Context, note Note) (bool, error) {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	existing, exists := notesService.notes[note.NoteId]
+	if exists {
+		notesService.retitleLinks(note.NoteId, existing.Title, note.Title, time.Now())
+	}
+	note.Tags = NormaliseTags(note.Tags)
+	if note.CheckIn == nil {
+		note.CheckIn = existing.CheckIn
+	} else {
+		note.CheckIn = memoryCheckIn(*note.CheckIn)
+	}
+	notesService.notes[note.NoteId] = note
+	return !exists, nil
+}
+
+func (notesService *MemoryNotesService) RenameNote(_ context.Context, id uuid.UUID, from string, to string) (bool, error) {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	note, ok := notesService.notes[id]
+	if !ok || note.Title != from {
+		return false, nil
+	}
+	note.Title = to
+	notesService.notes[id] = note
+	return true, nil
+}
+
+// retitleLinks rewrites the links to the title from in the other notes to
+// link to instead, marking them as updated at updatedAt, and returns their
+// ids. The caller holds mu.
+func (notesService *MemoryNotesService) retitleLinks(id uuid.UUID, from string, to string, updatedAt time.Time) []uuid.UUID {
+	rewritten := []uuid.UUID{}
+	to, ok := linkableTitle(to)
+	if !ok || linkKey(from) == linkKey(to) {
+		return rewritten
+	}
+	for _, note := range notesService.notes {
+		if note.NoteId == id {
+			continue
+		}
+		content := rewriteLinks(note.Content, linkKey(from), to)
+		if content == note.Content {
+			continue
+		}
+		note.Content = content
+		note.UpdatedAt = updatedAt
+		notesService.notes[note.NoteId] = note
+		rewritten = append(rewritten, note.NoteId)
+	}
+	return rewritten
+}
+
+func (notesService *MemoryNotesService) GetNotesInRange(_ context.Context, dateRange DateRange) ([]Note, error) {
+	return notesService.filter(dateRange.Matches), nil
+}
+
+func (notesService *MemoryNotesService) DeleteNote(_ context.Context, id uuid.UUID) error {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	delete(notesService.notes, id)
+	return nil
+}
+
+// filter returns the matching notes ordered by creation time
+func (notesService *MemoryNotesService) filter(keep func(Note) bool) []Note {
+	notesService.mu.Lock()
+	defer notesService.mu.Unlock()
+	notes := []Note{}
+	for _, note := range notesService.notes {
+		if keep(note) {
+			notes = append(notes, note)
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].CreatedAt.Before(notes[j].CreatedAt)
+	})
+	return notes
+}
+
+// memoryCheckIn stores an empty check-in as none, as the database does
+func memoryCheckIn(checkIn CheckIn) *CheckIn {
+	if checkIn.IsEmpty() {
+		return nil
+	}
+	return &checkIn
+}
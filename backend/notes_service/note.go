@@ -2,13 +2,30 @@ package notes_service
 
 import (
 	"time"
+
 	"github.com/google/uuid"
 )
 
+// DefaultTitle is given to new notes and to imported entries without a title
+const DefaultTitle = "Untitled Note"
+
 type Note struct {
-	NoteId uuid.UUID
-	Title string
-	Content string
+	NoteId    uuid.UUID
+	Title     string
+	Content   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
-}
\ No newline at end of file
+	Tags      []string
+	// Nil when the note has no check-in
+	CheckIn *CheckIn
+	// Set while the title is one generated by the model
+	TitleGenerated bool
+	// The journaling prompt the note was started from, empty for none
+	Prompt string
+}
+
+// NotePrompt is a journaling prompt a note is started from
+type NotePrompt struct {
+	PromptId uuid.UUID
+	Text     string
+}
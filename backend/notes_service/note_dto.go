@@ -1,6 +1,10 @@
 package notes_service
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type UpdateNoteRequestDto struct {
 	NoteId    string    `json:"NoteId"`
@@ -8,4 +12,12 @@ type UpdateNoteRequestDto struct {
 	Content   string    `json:"Content"`
 	UpdatedAt time.Time `json:"UpdatedAt"`
 	CreatedAt time.Time `json:"CreatedAt"`
+	// Replaces the note's tags when present. Omit to leave them unchanged.
+	Tags []string `json:"Tags"`
+}
+
+type UpdateNoteResponseDto struct {
+	// The other notes whose links to the note were rewritten to a new title,
+	// which readers holding them should load again
+	RewrittenNoteIds []uuid.UUID `json:"RewrittenNoteIds"`
 }
@@ -0,0 +1,49 @@
+package notes_service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteFilter selects a subset of notes. Zero fields match everything.
+type NoteFilter struct {
+	// Notes created at or after From
+	From time.Time `json:"From"`
+	// Notes created before To
+	To time.Time `json:"To"`
+	// Notes carrying at least one of these tags
+	Tags []string `json:"Tags"`
+	// Only these notes
+	NoteIds []uuid.UUID `json:"NoteIds"`
+}
+
+func (filter NoteFilter) Matches(note Note) bool {
+	if !filter.From.IsZero() && note.CreatedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !note.CreatedAt.Before(filter.To) {
+		return false
+	}
+	if len(filter.NoteIds) > 0 && !slices.Contains(filter.NoteIds, note.NoteId) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !slices.ContainsFunc(NormaliseTags(filter.Tags), func(tag string) bool {
+		return slices.Contains(note.Tags, tag)
+	}) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the notes matching the filter, keeping their order
+func (filter NoteFilter) Apply(notes []Note) []Note {
+	matching := []Note{}
+	for _, note := range notes {
+		if filter.Matches(note) {
+			matching = append(matching, note)
+		}
+	}
+	return matching
+}
@@ -1,136 +1,364 @@
 package notes_service
 
 import (
+	"backend/db"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrNoteNotFound = errors.New("note not found")
+
 // Interface for the NotesService
 type NotesService interface {
-	CreateNote(title string, db *sql.DB) (Note, error)
-	GetAllNotes(id uuid.UUID, db *sql.DB) ([]Note, error)
-	GetNote(id uuid.UUID, db *sql.DB) (Note, error)
-	UpdateNote(note Note, db *sql.DB) error
-	GetNotesWithinTimeframe(db *sql.DB, duration time.Duration) ([]Note, error)
-	DeleteNote(id uuid.UUID, db *sql.DB) error
+	CreateNote(ctx context.Context, title string) (Note, error)
+	// CreateNoteWithContent creates a note with a title and content, e.g. from
+	// a template
+	CreateNoteWithContent(ctx context.Context, title string, content string) (Note, error)
+	// CreateNoteWithPrompt creates an empty note started from a journaling
+	// prompt, which is kept with the note
+	CreateNoteWithPrompt(ctx context.Context, prompt NotePrompt) (Note, error)
+	GetAllNotes(ctx context.Context) ([]Note, error)
+	GetNote(ctx context.Context, id uuid.UUID) (Note, error)
+	// UpdateNote stores the note's title and content. When the title changes,
+	// [[wiki links]] to the note by its old title are rewritten in the notes
+	// they are in, and the ids of those other notes are returned, as they
+	// changed too. It fails with ErrInvalidCheckIn, storing nothing, when the
+	// check-in has a value its field does not allow.
+	UpdateNote(ctx context.Context, note Note) ([]uuid.UUID, error)
+	// UpsertNote stores note as is, keeping its id and timestamps. It reports
+	// whether a new note was created. The check-in is checked like
+	// UpdateNote's.
+	UpsertNote(ctx context.Context, note Note) (bool, error)
+	// RenameNote changes the title only if it is still from, leaving the rest
+	// of the note alone, and reports whether the note was renamed. It is meant
+	// for automatic renames, so the note does not count as updated and, unlike
+	// UpdateNote, no other note is rewritten: links to the old title are
+	// resolved again by it.
+	RenameNote(ctx context.Context, id uuid.UUID, from string, to string) (bool, error)
+	// GetNotesInRange returns the notes whose created or updated time falls
+	// within dateRange
+	GetNotesInRange(ctx context.Context, dateRange DateRange) ([]Note, error)
+	DeleteNote(ctx context.Context, id uuid.UUID) error
+}
+
+type NotesServiceImpl struct {
+	dbClient *db_client.DBClient
 }
 
-type NotesServiceImpl struct{}
+func NewNotesServiceImpl(dbClient *db_client.DBClient) *NotesServiceImpl {
+	return &NotesServiceImpl{dbClient: dbClient}
+}
+
+// A title counts as generated while it matches the generated one
+const selectNoteColumns = `SELECT n.id, n.title, n.content, n.created_at, n.updated_at, COALESCE(g.title = n.title AND NOT g.reverted, 0), COALESCE(p.prompt, '')
+	FROM notes n LEFT JOIN generated_titles g ON g.note_id = n.id LEFT JOIN note_prompts p ON p.note_id = n.id`
 
 // Implementation of the NotesService methods
-func (notesService *NotesServiceImpl) CreateNote(title string, db *sql.DB) (Note, error) {
+func (notesService *NotesServiceImpl) CreateNote(ctx context.Context, title string) (Note, error) {
+	return notesService.CreateNoteWithContent(ctx, title, "")
+}
+
+func (notesService *NotesServiceImpl) CreateNoteWithContent(ctx context.Context, title string, content string) (Note, error) {
+	return notesService.createNote(ctx, title, content, nil)
+}
+
+func (notesService *NotesServiceImpl) CreateNoteWithPrompt(ctx context.Context, prompt NotePrompt) (Note, error) {
+	return notesService.createNote(ctx, DefaultTitle, "", &prompt)
+}
+
+// createNote inserts a note and, when prompt is not nil, the prompt it was
+// started from in the same transaction
+func (notesService *NotesServiceImpl) createNote(ctx context.Context, title string, content string, prompt *NotePrompt) (Note, error) {
+	now := time.Now()
 	newNote := Note{
 		NoteId:    uuid.New(),
 		Title:     title,
-		Content:   "",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	sqlStatement := "INSERT INTO notes (id, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
-	fmt.Println(sqlStatement)
-	_, err := db.Exec(sqlStatement, newNote.NoteId, title, "", time.Now(), time.Now())
-	if err != nil {
-		fmt.Printf("Error executing statement: %v\n", err)
-		return newNote, err
-	}
-
-	// Verify the note exists and is queryable
-	verifyNote, err := notesService.GetNote(newNote.NoteId, db)
-	if err != nil {
-		return newNote, fmt.Errorf("note was inserted but could not be verified: %v", err)
-	}
-	return verifyNote, nil
-}
+	err := notesService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		sqlStatement := "INSERT INTO notes (id, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?)"
+		_, err := tx.ExecContext(ctx, sqlStatement, newNote.NoteId, newNote.Title, newNote.Content, newNote.CreatedAt, newNote.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if err := refreshNoteStats(ctx, tx, newNote.NoteId); err != nil {
+			return err
+		}
+		if _, err := refreshLinks(ctx, tx, newNote.NoteId, "", now); err != nil {
+			return err
+		}
+		if prompt != nil {
+			_, err := tx.ExecContext(ctx, "INSERT INTO note_prompts (note_id, prompt_id, prompt) VALUES (?, ?, ?)", newNote.NoteId, prompt.PromptId, prompt.Text)
+			if err != nil {
+				return err
+			}
+		}
 
-func (notesService *NotesServiceImpl) GetNote(id uuid.UUID, db *sql.DB) (Note, error) {
-	sqlStatement := "SELECT id, title, content, created_at, updated_at FROM notes WHERE id = ?"
-	sql_result, err := db.Query(sqlStatement, id)
+		// Verify the note exists and is queryable
+		newNote, err = getNote(ctx, tx, newNote.NoteId)
+		if err != nil {
+			return fmt.Errorf("note was inserted but could not be verified: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		fmt.Printf("Error executing statement: %v\n", err)
 		return Note{}, err
 	}
+	return newNote, nil
+}
 
-	var note Note
-	if !sql_result.Next() {
-		sql_result.Close()
-		return Note{}, fmt.Errorf("no note found with id: %v", id)
-	}
+func (notesService *NotesServiceImpl) GetNote(ctx context.Context, id uuid.UUID) (Note, error) {
+	return getNote(ctx, notesService.dbClient, id)
+}
 
-	err = sql_result.Scan(&note.NoteId, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt)
+func getNote(ctx context.Context, executor db_client.Executor, id uuid.UUID) (Note, error) {
+	notes, err := queryNotes(ctx, executor, selectNoteColumns+" WHERE n.id = ?", id)
 	if err != nil {
-		sql_result.Close()
-		fmt.Printf("Error scanning row: %v\n", err)
 		return Note{}, err
 	}
+	if len(notes) == 0 {
+		return Note{}, fmt.Errorf("%w: %v", ErrNoteNotFound, id)
+	}
+	return notes[0], nil
+}
 
-	sql_result.Close()
-	return note, nil
+func (notesService *NotesServiceImpl) GetAllNotes(ctx context.Context) ([]Note, error) {
+	return queryNotes(ctx, notesService.dbClient, selectNoteColumns)
 }
 
-func (notesService *NotesServiceImpl) GetAllNotes(db *sql.DB) ([]Note, error) {
-	sqlStatement := "SELECT id, title, content, created_at, updated_at FROM notes"
-	sql_result, err := db.Query(sqlStatement)
+func (notesService *NotesServiceImpl) UpdateNote(ctx context.Context, note Note) ([]uuid.UUID, error) {
+	rewritten := []uuid.UUID{}
+	err := notesService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		var previousTitle string
+		err := tx.QueryRowContext(ctx, "SELECT title FROM notes WHERE id = ?", note.NoteId).Scan(&previousTitle)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %v", ErrNoteNotFound, note.NoteId)
+		}
+		if err != nil {
+			return err
+		}
+		if note.CheckIn != nil {
+			if err := validateCheckIn(ctx, tx, *note.CheckIn); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		sqlStatement := "UPDATE notes SET title = ?, content = ?, updated_at = ? WHERE id = ?"
+		if _, err := tx.ExecContext(ctx, sqlStatement, note.Title, note.Content, now, note.NoteId); err != nil {
+			return err
+		}
+		if err := refreshNoteStats(ctx, tx, note.NoteId); err != nil {
+			return err
+		}
+		rewritten, err = refreshLinks(ctx, tx, note.NoteId, previousTitle, now)
+		if err != nil {
+			return err
+		}
+		if note.Tags != nil {
+			if err := replaceTags(ctx, tx, note.NoteId, note.Tags); err != nil {
+				return err
+			}
+		}
+		if note.CheckIn == nil {
+			return nil
+		}
+		return replaceCheckIn(ctx, tx, note.NoteId, *note.CheckIn)
+	})
 	if err != nil {
-		fmt.Printf("Error executing statement: %v\n", err)
 		return nil, err
 	}
+	return rewritten, nil
+}
 
-	notes := []Note{}
+func (notesService *NotesServiceImpl) UpsertNote(ctx context.Context, note Note) (bool, error) {
+	created := false
+	err := notesService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		var previousTitle string
+		err := tx.QueryRowContext(ctx, "SELECT title FROM notes WHERE id = ?", note.NoteId).Scan(&previousTitle)
+		created = errors.Is(err, sql.ErrNoRows)
+		if err != nil && !created {
+			return err
+		}
+		if note.CheckIn != nil {
+			if err := validateCheckIn(ctx, tx, *note.CheckIn); err != nil {
+				return err
+			}
+		}
 
-	for sql_result.Next() {
-		var note Note
-		err = sql_result.Scan(&note.NoteId, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt)
+		sqlStatement := `INSERT INTO notes (id, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET title = excluded.title, content = excluded.content,
+			created_at = excluded.created_at, updated_at = excluded.updated_at`
+		_, err = tx.ExecContext(ctx, sqlStatement, note.NoteId, note.Title, note.Content, note.CreatedAt, note.UpdatedAt)
 		if err != nil {
-			fmt.Printf("Error scanning row: %v\n", err)
-			return nil, err
+			return err
 		}
-		notes = append(notes, note)
-	}
+		if err := refreshNoteStats(ctx, tx, note.NoteId); err != nil {
+			return err
+		}
+		if _, err := refreshLinks(ctx, tx, note.NoteId, previousTitle, time.Now()); err != nil {
+			return err
+		}
+		if err := replaceTags(ctx, tx, note.NoteId, note.Tags); err != nil {
+			return err
+		}
+		// Sources without check-ins, such as most imports, leave them unchanged
+		if note.CheckIn == nil {
+			return nil
+		}
+		return replaceCheckIn(ctx, tx, note.NoteId, *note.CheckIn)
+	})
+	return created, err
+}
 
-	return notes, nil
+func (notesService *NotesServiceImpl) RenameNote(ctx context.Context, id uuid.UUID, from string, to string) (bool, error) {
+	renamed := false
+	err := notesService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		sqlStatement := "UPDATE notes SET title = ? WHERE id = ? AND title = ?"
+		result, err := tx.ExecContext(ctx, sqlStatement, to, id, from)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+		if _, err := refreshLinks(ctx, tx, id, "", time.Time{}); err != nil {
+			return err
+		}
+		renamed = true
+		return nil
+	})
+	return renamed, err
 }
 
-func (notesService *NotesServiceImpl) UpdateNote(note Note, db *sql.DB) error {
-	sqlStatement := "UPDATE notes SET title = ?, content = ?, updated_at = ? WHERE id = ?"
-	_, err := db.Exec(sqlStatement, note.Title, note.Content, time.Now(), note.NoteId)
-	if err != nil {
-		fmt.Printf("Error executing statement: %v\n", err)
-		return err
+func (notesService *NotesServiceImpl) GetNotesInRange(ctx context.Context, dateRange DateRange) ([]Note, error) {
+	column := "n.created_at"
+	if dateRange.Field == UpdatedAt {
+		column = "n.updated_at"
 	}
-	return nil
+	// Timestamps are stored as text with the offset they were written in, so
+	// they are compared as Julian days rather than as strings
+	conditions := []string{}
+	args := []any{}
+	if !dateRange.From.IsZero() {
+		conditions = append(conditions, "julianday("+column+") >= julianday(?)")
+		args = append(args, dateRange.From)
+	}
+	if !dateRange.To.IsZero() {
+		conditions = append(conditions, "julianday("+column+") < julianday(?)")
+		args = append(args, dateRange.To)
+	}
+	query := selectNoteColumns
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return queryNotes(ctx, notesService.dbClient, query+" ORDER BY julianday(n.created_at)", args...)
 }
 
-func (notesService *NotesServiceImpl) GetNotesWithinTimeframe(db *sql.DB, duration time.Duration) ([]Note, error) {
-	cutoff := time.Now().Add(-duration)
-	sqlStatement := "SELECT id, title, content, created_at, updated_at FROM notes WHERE created_at >= ?"
-	sql_result, err := db.Query(sqlStatement, cutoff)
+func (notesService *NotesServiceImpl) DeleteNote(ctx context.Context, id uuid.UUID) error {
+	return notesService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM notes WHERE id = ?", id); err != nil {
+			return err
+		}
+		// Links to the note look for another note
+		return resolveLinks(ctx, tx)
+	})
+}
+
+// queryNotes runs a query selecting the note columns, scans every row and
+// attaches each note's tags and check-in
+func queryNotes(ctx context.Context, executor db_client.Executor, query string, args ...any) ([]Note, error) {
+	rows, err := executor.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer sql_result.Close()
+	defer rows.Close()
 
 	notes := []Note{}
-	for sql_result.Next() {
+	for rows.Next() {
 		var note Note
-		err = sql_result.Scan(&note.NoteId, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt)
-		if err != nil {
+		if err := rows.Scan(&note.NoteId, &note.Title, &note.Content, &note.CreatedAt, &note.UpdatedAt, &note.TitleGenerated, &note.Prompt); err != nil {
 			return nil, err
 		}
+		note.Tags = []string{}
 		notes = append(notes, note)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(notes) == 1 {
+		if err := loadTags(ctx, executor, notes, "SELECT note_id, tag FROM note_tags WHERE note_id = ? ORDER BY tag", notes[0].NoteId); err != nil {
+			return nil, err
+		}
+		return notes, loadCheckIns(ctx, executor, notes, "SELECT note_id, field, value FROM note_checkins WHERE note_id = ?", notes[0].NoteId)
+	}
+	if len(notes) > 1 {
+		if err := loadTags(ctx, executor, notes, "SELECT note_id, tag FROM note_tags ORDER BY tag"); err != nil {
+			return nil, err
+		}
+		return notes, loadCheckIns(ctx, executor, notes, "SELECT note_id, field, value FROM note_checkins")
+	}
 	return notes, nil
 }
 
-func (notesService *NotesServiceImpl) DeleteNote(id uuid.UUID, db *sql.DB) error {
-	sqlStatement := "DELETE FROM notes WHERE id = ?"
-	_, err := db.Exec(sqlStatement, id)
+func loadTags(ctx context.Context, executor db_client.Executor, notes []Note, query string, args ...any) error {
+	indexById := map[uuid.UUID]int{}
+	for i, note := range notes {
+		indexById[note.NoteId] = i
+	}
+
+	rows, err := executor.QueryContext(ctx, query, args...)
 	if err != nil {
-		fmt.Printf("Error executing delete statement: %v\n", err)
 		return err
 	}
+	defer rows.Close()
+	for rows.Next() {
+		var noteId uuid.UUID
+		var tag string
+		if err := rows.Scan(&noteId, &tag); err != nil {
+			return err
+		}
+		if i, ok := indexById[noteId]; ok {
+			notes[i].Tags = append(notes[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+func replaceTags(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, tags []string) error {
+	if _, err := executor.ExecContext(ctx, "DELETE FROM note_tags WHERE note_id = ?", noteId); err != nil {
+		return err
+	}
+	for _, tag := range NormaliseTags(tags) {
+		if _, err := executor.ExecContext(ctx, "INSERT INTO note_tags (note_id, tag) VALUES (?, ?)", noteId, tag); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// NormaliseTags trims whitespace and a leading '#' from each tag, drops empty
+// tags and removes duplicates
+func NormaliseTags(tags []string) []string {
+	normalised := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" && !slices.Contains(normalised, tag) {
+			normalised = append(normalised, tag)
+		}
+	}
+	slices.Sort(normalised)
+	return normalised
+}
@@ -0,0 +1,77 @@
+package notes_service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// notifyingNotesService calls onChange after every change made through it
+type notifyingNotesService struct {
+	NotesService
+	onChange func()
+}
+
+// NotifyOnChange wraps notesService so that onChange is called after every
+// successful create, update, upsert, rename or delete made through it,
+// including those that rewrote links in other notes. Background jobs use it
+// to learn about edits made by the HTTP handlers.
+func NotifyOnChange(notesService NotesService, onChange func()) NotesService {
+	return &notifyingNotesService{NotesService: notesService, onChange: onChange}
+}
+
+func (notesService *notifyingNotesService) CreateNote(ctx context.Context, title string) (Note, error) {
+	note, err := notesService.NotesService.CreateNote(ctx, title)
+	if err == nil {
+		notesService.onChange()
+	}
+	return note, err
+}
+
+func (notesService *notifyingNotesService) CreateNoteWithContent(ctx context.Context, title string, content string) (Note, error) {
+	note, err := notesService.NotesService.CreateNoteWithContent(ctx, title, content)
+	if err == nil {
+		notesService.onChange()
+	}
+	return note, err
+}
+
+func (notesService *notifyingNotesService) CreateNoteWithPrompt(ctx context.Context, prompt NotePrompt) (Note, error) {
+	note, err := notesService.NotesService.CreateNoteWithPrompt(ctx, prompt)
+	if err == nil {
+		notesService.onChange()
+	}
+	return note, err
+}
+
+func (notesService *notifyingNotesService) UpdateNote(ctx context.Context, note Note) ([]uuid.UUID, error) {
+	rewritten, err := notesService.NotesService.UpdateNote(ctx, note)
+	if err == nil {
+		notesService.onChange()
+	}
+	return rewritten, err
+}
+
+func (notesService *notifyingNotesService) UpsertNote(ctx context.Context, note Note) (bool, error) {
+	created, err := notesService.NotesService.UpsertNote(ctx, note)
+	if err == nil {
+		notesService.onChange()
+	}
+	return created, err
+}
+
+func (notesService *notifyingNotesService) RenameNote(ctx context.Context, id uuid.UUID, from string, to string) (bool, error) {
+	renamed, err := notesService.NotesService.RenameNote(ctx, id, from, to)
+	if renamed {
+		notesService.onChange()
+	}
+	return renamed, err
+}
+
+func (notesService *notifyingNotesService) DeleteNote(ctx context.Context, id uuid.UUID) error {
+	err := notesService.NotesService.DeleteNote(ctx, id)
+	if err == nil {
+		notesService.onChange()
+	}
+	return err
+}
@@ -0,0 +1,268 @@
+package notes_service
+
+import (
+	"backend/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// WritingStats summarises the journal. Notes without any words, such as
+// freshly created ones, do not count as entries.
+type WritingStats struct {
+	Entries           int     `json:"Entries"`
+	Words             int     `json:"Words"`
+	AverageWords      float64 `json:"AverageWords"`
+	AverageCharacters float64 `json:"AverageCharacters"`
+	// Days with at least one entry
+	ActiveDays int `json:"ActiveDays"`
+	// Averages over the days from the first entry to today
+	EntriesPerDay  float64 `json:"EntriesPerDay"`
+	EntriesPerWeek float64 `json:"EntriesPerWeek"`
+	// The streak ending today or yesterday, as it can still be continued today
+	CurrentStreak Streak `json:"CurrentStreak"`
+	LongestStreak Streak `json:"LongestStreak"`
+	// Entries by local hour of the day, from 0 to 23
+	ByHour []int `json:"ByHour"`
+	// Entries by day of the week, Sunday first
+	ByWeekday []int `json:"ByWeekday"`
+	// The hour with the most entries, omitted without entries
+	MostActiveHour *int `json:"MostActiveHour,omitempty"`
+}
+
+// Streak is a run of consecutive days with entries
+type Streak struct {
+	Days int `json:"Days"`
+	// First and last day, omitted for an empty streak
+	Start string `json:"Start,omitempty"`
+	End   string `json:"End,omitempty"`
+}
+
+// ActivityBucket counts the entries of a day or week, for charts and the
+// calendar heatmap
+type ActivityBucket struct {
+	// The day, or the Monday starting the week
+	Start   string `json:"Start"`
+	Entries int    `json:"Entries"`
+	Words   int    `json:"Words"`
+}
+
+// StatsService computes writing statistics from note_stats, which the notes
+// service keeps up to date
+type StatsService struct {
+	dbClient *db_client.DBClient
+}
+
+func NewStatsService(dbClient *db_client.DBClient) *StatsService {
+	return &StatsService{dbClient: dbClient}
+}
+
+// refreshNoteStats records the statistics of a note as it is stored
+func refreshNoteStats(ctx context.Context, executor db_client.Executor, noteId uuid.UUID) error {
+	var content string
+	var createdAt time.Time
+	err := executor.QueryRowContext(ctx, "SELECT content, created_at FROM notes WHERE id = ?", noteId).Scan(&content, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	created := createdAt.Local()
+	sqlStatement := `INSERT INTO note_stats (note_id, day, hour, weekday, words, characters) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (note_id) DO UPDATE SET day = excluded.day, hour = excluded.hour, weekday = excluded.weekday,
+		words = excluded.words, characters = excluded.characters`
+	_, err = executor.ExecContext(ctx, sqlStatement, noteId, created.Format(time.DateOnly), created.Hour(), int(created.Weekday()),
+		len(strings.Fields(content)), utf8.RuneCountInString(content))
+	return err
+}
+
+// backfillNoteStats records the statistics of notes that have none, those
+// written before statistics were kept or restored from an older backup
+func (statsService *StatsService) backfillNoteStats(ctx context.Context) error {
+	rows, err := statsService.dbClient.QueryContext(ctx, "SELECT n.id FROM notes n LEFT JOIN note_stats s ON s.note_id = n.id WHERE s.note_id IS NULL")
+	if err != nil {
+		return err
+	}
+	missing := []uuid.UUID{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		if err := rows.Scan(&noteId); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, noteId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(missing) == 0 {
+		return err
+	}
+	return statsService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		for _, noteId := range missing {
+			if err := refreshNoteStats(ctx, tx, noteId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Summary computes the statistics of the whole journal as of today
+func (statsService *StatsService) Summary(ctx context.Context, today time.Time) (WritingStats, error) {
+	if err := statsService.backfillNoteStats(ctx); err != nil {
+		return WritingStats{}, err
+	}
+	stats := WritingStats{ByHour: make([]int, 24), ByWeekday: make([]int, 7)}
+
+	var characters int
+	err := statsService.dbClient.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(words), 0), COALESCE(SUM(characters), 0) FROM note_stats WHERE words > 0").
+		Scan(&stats.Entries, &stats.Words, &characters)
+	if err != nil || stats.Entries == 0 {
+		return stats, err
+	}
+	stats.AverageWords = float64(stats.Words) / float64(stats.Entries)
+	stats.AverageCharacters = float64(characters) / float64(stats.Entries)
+
+	rows, err := statsService.dbClient.QueryContext(ctx, "SELECT hour, weekday, COUNT(*) FROM note_stats WHERE words > 0 GROUP BY hour, weekday")
+	if err != nil {
+		return WritingStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hour, weekday, count int
+		if err := rows.Scan(&hour, &weekday, &count); err != nil {
+			return WritingStats{}, err
+		}
+		stats.ByHour[hour] += count
+		stats.ByWeekday[weekday] += count
+	}
+	if err := rows.Err(); err != nil {
+		return WritingStats{}, err
+	}
+	mostActive := 0
+	for hour, count := range stats.ByHour {
+		if count > stats.ByHour[mostActive] {
+			mostActive = hour
+		}
+	}
+	stats.MostActiveHour = &mostActive
+
+	days, err := statsService.activeDays(ctx)
+	if err != nil {
+		return WritingStats{}, err
+	}
+	stats.ActiveDays = len(days)
+	today = startOfDay(today)
+	span := int(today.Sub(days[0]).Hours()/24+0.5) + 1
+	stats.EntriesPerDay = float64(stats.Entries) / float64(max(span, 1))
+	stats.EntriesPerWeek = stats.EntriesPerDay * 7
+	stats.CurrentStreak, stats.LongestStreak = streaks(days, today)
+	return stats, nil
+}
+
+// activeDays returns the days with entries, in order
+func (statsService *StatsService) activeDays(ctx context.Context) ([]time.Time, error) {
+	rows, err := statsService.dbClient.QueryContext(ctx, "SELECT DISTINCT day FROM note_stats WHERE words > 0 ORDER BY day")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	days := []time.Time{}
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		date, err := time.ParseInLocation(time.DateOnly, day, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, date)
+	}
+	return days, rows.Err()
+}
+
+// streaks finds the current and longest runs of consecutive days in days,
+// which are in order
+func streaks(days []time.Time, today time.Time) (Streak, Streak) {
+	var current, longest Streak
+	runStart := 0
+	for i := range days {
+		// AddDate rather than 24 hours, as days around daylight saving
+		// changes are longer or shorter
+		if i > 0 && !days[i-1].AddDate(0, 0, 1).Equal(days[i]) {
+			runStart = i
+		}
+		if i == len(days)-1 || !days[i].AddDate(0, 0, 1).Equal(days[i+1]) {
+			run := Streak{Days: i - runStart + 1, Start: days[runStart].Format(time.DateOnly), End: days[i].Format(time.DateOnly)}
+			if run.Days > longest.Days {
+				longest = run
+			}
+			if days[i].Equal(today) || days[i].Equal(today.AddDate(0, 0, -1)) {
+				current = run
+			}
+		}
+	}
+	return current, longest
+}
+
+// Activity counts entries per day or week from the day of from to the day of
+// to, inclusive. Every day or week in the range is returned, so a year of
+// days gives the calendar heatmap.
+func (statsService *StatsService) Activity(ctx context.Context, from time.Time, to time.Time, bucket string) ([]ActivityBucket, error) {
+	if err := statsService.backfillNoteStats(ctx); err != nil {
+		return nil, err
+	}
+	from = startOfDay(from)
+	end := startOfDay(to).AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return nil, fmt.Errorf("%w: the range ends before it starts", ErrInvalidDateRange)
+	}
+	step := 1
+	switch bucket {
+	case "", "day":
+	case "week":
+		// Weeks start on Monday, Weekday counts from Sunday
+		from = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+		step = 7
+	default:
+		return nil, fmt.Errorf("%w: bucket must be \"day\" or \"week\"", ErrInvalidDateRange)
+	}
+
+	buckets := []ActivityBucket{}
+	indexByDay := map[string]int{}
+	for start := from; start.Before(end); start = start.AddDate(0, 0, step) {
+		if len(buckets) == maxSeriesDays {
+			return nil, fmt.Errorf("%w: the range is longer than %d days", ErrInvalidDateRange, maxSeriesDays)
+		}
+		for day := start; day.Before(start.AddDate(0, 0, step)); day = day.AddDate(0, 0, 1) {
+			indexByDay[day.Format(time.DateOnly)] = len(buckets)
+		}
+		buckets = append(buckets, ActivityBucket{Start: start.Format(time.DateOnly)})
+	}
+
+	rows, err := statsService.dbClient.QueryContext(ctx, `SELECT day, COUNT(*), SUM(words) FROM note_stats
+		WHERE words > 0 AND day >= ? AND day < ? GROUP BY day`, from.Format(time.DateOnly), end.AddDate(0, 0, step).Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var entries, words int
+		if err := rows.Scan(&day, &entries, &words); err != nil {
+			return nil, err
+		}
+		if index, ok := indexByDay[day]; ok {
+			buckets[index].Entries += entries
+			buckets[index].Words += words
+		}
+	}
+	return buckets, rows.Err()
+}
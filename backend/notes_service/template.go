@@ -0,0 +1,207 @@
+package notes_service
+
+import (
+	"backend/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template is the title and content new notes can start from. Both may
+// contain variables such as {{date}}, filled in when a note is created.
+type Template struct {
+	TemplateId uuid.UUID `json:"TemplateId"`
+	Name       string    `json:"Name"`
+	Title      string    `json:"Title"`
+	Content    string    `json:"Content"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+}
+
+var (
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrTemplateNotFound = errors.New("template not found")
+)
+
+// Variables are written as {{name}}, with optional spaces inside the braces
+var templateVariable = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// templateVariables fill in the variables from the time a note is created
+var templateVariables = map[string]func(now time.Time) string{
+	"date":      func(now time.Time) string { return now.Format(time.DateOnly) },
+	"long_date": func(now time.Time) string { return now.Format("Monday 2 January 2006") },
+	"time":      func(now time.Time) string { return now.Format("15:04") },
+	"weekday":   func(now time.Time) string { return now.Weekday().String() },
+	"month":     func(now time.Time) string { return now.Month().String() },
+	"year":      func(now time.Time) string { return strconv.Itoa(now.Year()) },
+	// The ISO week number, weeks starting on Monday
+	"week": func(now time.Time) string {
+		_, week := now.ISOWeek()
+		return strconv.Itoa(week)
+	},
+	"yesterday": func(now time.Time) string { return now.AddDate(0, 0, -1).Format(time.DateOnly) },
+	"tomorrow":  func(now time.Time) string { return now.AddDate(0, 0, 1).Format(time.DateOnly) },
+}
+
+// TemplateVariables returns the names of the variables templates may use
+func TemplateVariables() []string {
+	names := []string{}
+	for name := range templateVariables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+const maxTemplateNameLength = 80
+
+// RenderTemplate fills in the variables of text as of now. Unknown variables
+// are left as they are.
+func RenderTemplate(text string, now time.Time) string {
+	return templateVariable.ReplaceAllStringFunc(text, func(variable string) string {
+		name := templateVariable.FindStringSubmatch(variable)[1]
+		if value, ok := templateVariables[name]; ok {
+			return value(now)
+		}
+		return variable
+	})
+}
+
+// validateTemplate checks a template's name and that it uses only known
+// variables, trimming the name
+func validateTemplate(template Template) (Template, error) {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return template, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if len(template.Name) > maxTemplateNameLength {
+		return template, fmt.Errorf("%w: name is longer than %d characters", ErrInvalidTemplate, maxTemplateNameLength)
+	}
+	for _, match := range templateVariable.FindAllStringSubmatch(template.Title+template.Content, -1) {
+		if _, ok := templateVariables[match[1]]; !ok {
+			return template, fmt.Errorf("%w: unknown variable {{%s}}, use one of %s", ErrInvalidTemplate, match[1], strings.Join(TemplateVariables(), ", "))
+		}
+	}
+	return template, nil
+}
+
+// TemplateService manages note templates and creates notes from them
+type TemplateService struct {
+	dbClient *db_client.DBClient
+}
+
+func NewTemplateService(dbClient *db_client.DBClient) *TemplateService {
+	return &TemplateService{dbClient: dbClient}
+}
+
+// Templates returns every template by name
+func (templateService *TemplateService) Templates(ctx context.Context) ([]Template, error) {
+	rows, err := templateService.dbClient.QueryContext(ctx, "SELECT id, name, title, content, created_at, updated_at FROM note_templates ORDER BY name COLLATE NOCASE")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var template Template
+		if err := rows.Scan(&template.TemplateId, &template.Name, &template.Title, &template.Content, &template.CreatedAt, &template.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+func (templateService *TemplateService) Template(ctx context.Context, id uuid.UUID) (Template, error) {
+	return getTemplate(ctx, templateService.dbClient, id)
+}
+
+func getTemplate(ctx context.Context, executor db_client.Executor, id uuid.UUID) (Template, error) {
+	var template Template
+	err := executor.QueryRowContext(ctx, "SELECT id, name, title, content, created_at, updated_at FROM note_templates WHERE id = ?", id).
+		Scan(&template.TemplateId, &template.Name, &template.Title, &template.Content, &template.CreatedAt, &template.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Template{}, fmt.Errorf("%w: %v", ErrTemplateNotFound, id)
+	}
+	return template, err
+}
+
+// SaveTemplate creates a template when it has no id and updates the template
+// with its id otherwise. Names are unique.
+func (templateService *TemplateService) SaveTemplate(ctx context.Context, template Template) (Template, error) {
+	template, err := validateTemplate(template)
+	if err != nil {
+		return template, err
+	}
+
+	err = templateService.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		var existingId uuid.UUID
+		err := tx.QueryRowContext(ctx, "SELECT id FROM note_templates WHERE name = ? COLLATE NOCASE", template.Name).Scan(&existingId)
+		if err == nil && existingId != template.TemplateId {
+			return fmt.Errorf("%w: a template named %q already exists", ErrInvalidTemplate, template.Name)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		now := time.Now()
+		if template.TemplateId == uuid.Nil {
+			template.TemplateId = uuid.New()
+			_, err := tx.ExecContext(ctx, "INSERT INTO note_templates (id, name, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+				template.TemplateId, template.Name, template.Title, template.Content, now, now)
+			if err != nil {
+				return err
+			}
+		} else {
+			result, err := tx.ExecContext(ctx, "UPDATE note_templates SET name = ?, title = ?, content = ?, updated_at = ? WHERE id = ?",
+				template.Name, template.Title, template.Content, now, template.TemplateId)
+			if err != nil {
+				return err
+			}
+			if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+				return fmt.Errorf("%w: %v", ErrTemplateNotFound, template.TemplateId)
+			}
+		}
+		template, err = getTemplate(ctx, tx, template.TemplateId)
+		return err
+	})
+	if err != nil {
+		return Template{}, err
+	}
+	return template, nil
+}
+
+// DeleteTemplate removes a template. Notes created from it are kept.
+func (templateService *TemplateService) DeleteTemplate(ctx context.Context, id uuid.UUID) error {
+	result, err := templateService.dbClient.ExecContext(ctx, "DELETE FROM note_templates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("%w: %v", ErrTemplateNotFound, id)
+	}
+	return nil
+}
+
+// CreateNote creates a note from a template, its variables filled in as of
+// now. A template without a title gives the default title.
+func (templateService *TemplateService) CreateNote(ctx context.Context, notesService NotesService, id uuid.UUID, now time.Time) (Note, error) {
+	template, err := templateService.Template(ctx, id)
+	if err != nil {
+		return Note{}, err
+	}
+	title := strings.TrimSpace(RenderTemplate(template.Title, now))
+	if title == "" {
+		title = DefaultTitle
+	}
+	return notesService.CreateNoteWithContent(ctx, title, RenderTemplate(template.Content, now))
+}
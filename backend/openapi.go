@@ -0,0 +1,22 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI description of every endpoint. Keep this in sync with the handlers
+// whenever a request or response shape changes, the handler tests check the
+// responses against it, and run go generate ./client to update the client.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
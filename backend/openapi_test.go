@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type specSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Format               string                 `json:"format"`
+	Properties           map[string]*specSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	Items                *specSchema            `json:"items"`
+	AdditionalProperties *specSchema            `json:"additionalProperties"`
+	AllOf                []*specSchema          `json:"allOf"`
+	Enum                 []any                  `json:"enum"`
+	Nullable             bool                   `json:"nullable"`
+}
+
+type specResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *specSchema `json:"schema"`
+	} `json:"content"`
+}
+
+type specDocument struct {
+	Paths map[string]map[string]struct {
+		Responses map[string]specResponse `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Responses map[string]specResponse `json:"responses"`
+		Schemas   map[string]*specSchema  `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) specDocument {
+	t.Helper()
+	var doc specDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return doc
+}
+
+// checkResponse fails the test when the recorded response is not one that
+// openapi.json documents for the operation
+func checkResponse(t *testing.T, method string, path string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	doc := loadSpec(t)
+	operations, ok := doc.Paths[path]
+	if !ok {
+		t.Fatalf("%s is not documented", path)
+	}
+	operation, ok := operations[strings.ToLower(method)]
+	if !ok && rec.Code == http.StatusMethodNotAllowed {
+		return
+	}
+	if !ok {
+		t.Fatalf("%s %s is not documented", method, path)
+	}
+	response, ok := operation.Responses[strconv.Itoa(rec.Code)]
+	if !ok {
+		t.Fatalf("%s %s answered %d, which is not documented: %s", method, path, rec.Code, rec.Body.String())
+	}
+	if name, ok := strings.CutPrefix(response.Ref, "#/components/responses/"); ok {
+		response = doc.Components.Responses[name]
+	}
+	if len(response.Content) == 0 {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := response.Content[contentType]
+	if !ok {
+		t.Fatalf("%s %s answered %d with Content-Type %q, which is not documented", method, path, rec.Code, contentType)
+	}
+	if contentType != "application/json" || media.Schema == nil {
+		return
+	}
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s answered %d with invalid JSON: %v", method, path, rec.Code, err)
+	}
+	for _, err := range validate(doc, media.Schema, body, "body") {
+		t.Errorf("%s %s: %v", method, path, err)
+	}
+}
+
+// validate returns where value does not match the schema
+func validate(doc specDocument, schema *specSchema, value any, at string) []error {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		resolved, ok := doc.Components.Schemas[name]
+		if !ok {
+			return []error{fmt.Errorf("%s: unknown schema %s", at, schema.Ref)}
+		}
+		return validate(doc, resolved, value, at)
+	}
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return []error{fmt.Errorf("%s: null is not allowed", at)}
+	}
+	var errs []error
+	for _, member := range schema.AllOf {
+		errs = append(errs, validate(doc, member, value, at)...)
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		errs = append(errs, fmt.Errorf("%s: %v is not one of %v", at, value, schema.Enum))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want an object, got %T", at, value))
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s: missing required %s", at, name))
+			}
+		}
+		for name, property := range object {
+			switch {
+			case schema.Properties[name] != nil:
+				errs = append(errs, validate(doc, schema.Properties[name], property, at+"."+name)...)
+			case schema.AdditionalProperties != nil:
+				errs = append(errs, validate(doc, schema.AdditionalProperties, property, at+"."+name)...)
+			case len(schema.Properties) > 0:
+				errs = append(errs, fmt.Errorf("%s: %s is not documented", at, name))
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want an array, got %T", at, value))
+		}
+		for i, item := range items {
+			errs = append(errs, validate(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return append(errs, fmt.Errorf("%s: want a string, got %T", at, value))
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a date-time", at, text))
+			}
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			errs = append(errs, fmt.Errorf("%s: want an integer, got %v", at, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, fmt.Errorf("%s: want a number, got %T", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Errorf("%s: want a boolean, got %T", at, value))
+		}
+	}
+	return errs
+}
+
+// TestRoutesAreDocumented compares the paths registerRoutes serves with the
+// paths of openapi.json
+func TestRoutesAreDocumented(t *testing.T) {
+	routes := map[string]bool{}
+	for _, path := range registerRoutes(http.NewServeMux(), routeDeps{}) {
+		routes[path] = true
+	}
+
+	doc := loadSpec(t)
+	for path := range routes {
+		if _, ok := doc.Paths[path]; !ok {
+			t.Errorf("%s is served but not documented", path)
+		}
+	}
+	for path := range doc.Paths {
+		if !routes[path] {
+			t.Errorf("%s is documented but not served", path)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"backend/prompt_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StartPromptRequest struct {
+	PromptId string `json:"PromptId"`
+}
+
+// dailyPromptsHandler returns today's journaling prompts
+func dailyPromptsHandler(w http.ResponseWriter, r *http.Request, promptService *prompt_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prompts, err := promptService.Daily(r.Context(), time.Now())
+	if err != nil {
+		log.Printf("Error getting daily prompts: %v", err)
+		http.Error(w, "Failed to get prompts", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prompts)
+}
+
+// startPromptHandler creates a note from one of the prompts
+func startPromptHandler(w http.ResponseWriter, r *http.Request, promptService *prompt_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req StartPromptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	promptId, err := uuid.Parse(req.PromptId)
+	if err != nil {
+		http.Error(w, "Invalid prompt id", http.StatusBadRequest)
+		return
+	}
+
+	note, err := promptService.Start(r.Context(), promptId)
+	if errors.Is(err, prompt_service.ErrPromptNotFound) {
+		http.Error(w, "Prompt not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting a note from prompt %v: %v", promptId, err)
+		http.Error(w, "Failed to create note", http.StatusInternalServerError)
+		return
+	}
+	log.Println("Note created from prompt: ", note.NoteId)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
@@ -0,0 +1,50 @@
+package prompt_service
+
+import "time"
+
+// library holds the prompts given while the model is unavailable. Each day
+// takes the next few in turn, so the same ones do not come back for weeks.
+var library = []string{
+	"What is one thing you are looking forward to today, and why?",
+	"What has been taking up most of your attention lately?",
+	"Who made a difference to your week, and how?",
+	"What is something small that went well recently?",
+	"What would make today feel worthwhile by the evening?",
+	"What are you carrying around that you could set down?",
+	"When did you last feel completely at ease, and what made it so?",
+	"What is a decision you keep putting off, and what is holding you back?",
+	"What did you learn about yourself this week?",
+	"What are three things you are grateful for right now?",
+	"How is your body feeling today, and what does it need?",
+	"What is something you would like to say to someone but have not yet?",
+	"What drained your energy recently, and what restored it?",
+	"What would you do today if you were not afraid of getting it wrong?",
+	"Which part of your routine do you enjoy most, and which least?",
+	"What is a worry you can let go of today?",
+	"What made you laugh or smile recently?",
+	"What is one way you could be kinder to yourself this week?",
+	"What are you proud of that nobody else noticed?",
+	"Where would you like to be a year from now?",
+	"What does a good day look like for you at the moment?",
+	"What is something you have been curious about lately?",
+	"Which conversation has stayed with you recently, and why?",
+	"What boundary would make your days easier?",
+	"What are you holding on to that no longer serves you?",
+	"What would you tell yourself from a month ago?",
+	"What is one habit you would like to start, and what is the first step?",
+	"Where did you find calm today, even briefly?",
+	"What has changed in your life that you have not paused to notice?",
+	"What is one thing you can do tomorrow to look after yourself?",
+}
+
+// libraryPrompts returns the count prompts of the library for day
+func libraryPrompts(day time.Time, count int) []string {
+	// Days since the Unix epoch, counted in day's location
+	year, month, date := day.Date()
+	index := int(time.Date(year, month, date, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60))
+	prompts := make([]string, count)
+	for i := range prompts {
+		prompts[i] = library[(index*count+i)%len(library)]
+	}
+	return prompts
+}
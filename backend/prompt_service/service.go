@@ -0,0 +1,286 @@
+/*
+The prompt_service package gives a few journaling prompts each day, written by
+the local model from the themes of recent entries, and starts notes from them.
+
+Each local day keeps one set of prompts. While the model is unavailable, or
+there are no recent entries to draw on, the day gets prompts from a built-in
+library instead; that set is replaced in the background the first time the
+model can write one, so prompts only change during a day to become personal.
+Notes keep the text of the prompt they were started from.
+*/
+package prompt_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SourceModel   = "model"
+	SourceLibrary = "library"
+)
+
+// PromptCount is the number of prompts given each day
+const PromptCount = 3
+
+const (
+	// Entries of the last two weeks are given to the model as context
+	recentDays       = 14
+	maxRecentEntries = 10
+)
+
+// How long after trying to write prompts with the model it is tried again
+const retryInterval = 10 * time.Minute
+
+var ErrPromptNotFound = errors.New("prompt not found")
+
+type Prompt struct {
+	PromptId uuid.UUID `json:"PromptId"`
+	Text     string    `json:"Text"`
+}
+
+type DailyPrompts struct {
+	// Formatted as 2006-01-02
+	Day string `json:"Day"`
+	// "model" or "library"
+	Source    string    `json:"Source"`
+	Prompts   []Prompt  `json:"Prompts"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// PromptGenerator is the part of the chat service prompts need
+type PromptGenerator interface {
+	GenerateJournalPrompts(ctx context.Context, entries []lm_service.PromptEntry, count int) ([]string, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	notesService notes_service.NotesService
+	generator    PromptGenerator
+	dbClient     *db_client.DBClient
+
+	// Held while a day's prompts are read and written, so concurrent
+	// requests do not each write a set
+	mu sync.Mutex
+	// When the model was last asked for prompts. Only used by Personalise,
+	// which Run calls from one goroutine.
+	attemptedAt time.Time
+
+	trigger  chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewService(notesService notes_service.NotesService, generator PromptGenerator, dbClient *db_client.DBClient) *Service {
+	return &Service{
+		notesService: notesService,
+		generator:    generator,
+		dbClient:     dbClient,
+		trigger:      make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Daily returns the prompts of the local day of now. The first request of a
+// day is given prompts from the library, which the model replaces in the
+// background once it is ready.
+func (service *Service) Daily(ctx context.Context, now time.Time) (DailyPrompts, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	now = now.In(time.Local)
+	day := now.Format(time.DateOnly)
+	prompts, err := loadDailyPrompts(ctx, service.dbClient, day)
+	if errors.Is(err, sql.ErrNoRows) {
+		prompts = newDailyPrompts(day, SourceLibrary, libraryPrompts(now, PromptCount))
+		err = service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+			return saveDailyPrompts(ctx, tx, prompts)
+		})
+	}
+	if err != nil {
+		return DailyPrompts{}, err
+	}
+	if prompts.Source == SourceLibrary && service.generator.GetStatus() {
+		service.Trigger()
+	}
+	return prompts, nil
+}
+
+// Run writes the day's prompts with the model whenever Daily asks for them,
+// until ctx is cancelled or the service is closed
+func (service *Service) Run(ctx context.Context) {
+	defer close(service.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-service.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-service.trigger:
+			if err := service.Personalise(ctx, time.Now()); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Error writing journal prompts, keeping the library: %v", err)
+			}
+		}
+	}
+}
+
+// Trigger schedules writing the day's prompts with the model
+func (service *Service) Trigger() {
+	select {
+	case service.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the job, abandoning the prompts being written, and waits for it
+// to finish
+func (service *Service) Close(ctx context.Context) error {
+	service.stopOnce.Do(func() { close(service.stop) })
+	select {
+	case <-service.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Personalise replaces the library prompts of the local day of now with
+// prompts the model writes from the entries of the days before. Each attempt
+// is a full generation, so after one the next waits for retryInterval.
+func (service *Service) Personalise(ctx context.Context, now time.Time) error {
+	if !service.generator.GetStatus() || time.Since(service.attemptedAt) < retryInterval {
+		return nil
+	}
+	service.attemptedAt = time.Now()
+
+	now = now.In(time.Local)
+	entries, err := service.recentEntries(ctx, now)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	texts, err := service.generator.GenerateJournalPrompts(ctx, entries, PromptCount)
+	if err != nil {
+		return err
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	day := now.Format(time.DateOnly)
+	stored, err := loadDailyPrompts(ctx, service.dbClient, day)
+	if err == nil && stored.Source == SourceModel {
+		return nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		return saveDailyPrompts(ctx, tx, newDailyPrompts(day, SourceModel, texts))
+	})
+}
+
+func newDailyPrompts(day string, source string, texts []string) DailyPrompts {
+	prompts := DailyPrompts{Day: day, Source: source, Prompts: []Prompt{}, CreatedAt: time.Now()}
+	for _, text := range texts {
+		prompts.Prompts = append(prompts.Prompts, Prompt{PromptId: uuid.New(), Text: text})
+	}
+	return prompts
+}
+
+// recentEntries returns the entries with content written in the days up to
+// now, the most recent first
+func (service *Service) recentEntries(ctx context.Context, now time.Time) ([]lm_service.PromptEntry, error) {
+	notes, err := service.notesService.GetNotesInRange(ctx, notes_service.DateRange{From: now.AddDate(0, 0, -recentDays), To: now})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(notes)
+	entries := []lm_service.PromptEntry{}
+	for _, note := range notes {
+		if strings.TrimSpace(note.Content) == "" {
+			continue
+		}
+		entries = append(entries, lm_service.PromptEntry{CreatedAt: note.CreatedAt.Local(), Title: note.Title, Content: note.Content})
+		if len(entries) == maxRecentEntries {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Start creates a note from a prompt and records the prompt it was started
+// from
+func (service *Service) Start(ctx context.Context, promptId uuid.UUID) (notes_service.Note, error) {
+	var text string
+	err := service.dbClient.QueryRowContext(ctx, "SELECT text FROM daily_prompts WHERE prompt_id = ?", promptId).Scan(&text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return notes_service.Note{}, fmt.Errorf("%w: %v", ErrPromptNotFound, promptId)
+	}
+	if err != nil {
+		return notes_service.Note{}, err
+	}
+	return service.notesService.CreateNoteWithPrompt(ctx, notes_service.NotePrompt{PromptId: promptId, Text: text})
+}
+
+// loadDailyPrompts reads the prompts of day, returning sql.ErrNoRows when it
+// has none
+func loadDailyPrompts(ctx context.Context, executor db_client.Executor, day string) (DailyPrompts, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT prompt_id, text, source, created_at FROM daily_prompts WHERE day = ? ORDER BY position", day)
+	if err != nil {
+		return DailyPrompts{}, err
+	}
+	defer rows.Close()
+
+	prompts := DailyPrompts{Day: day, Prompts: []Prompt{}}
+	for rows.Next() {
+		var prompt Prompt
+		if err := rows.Scan(&prompt.PromptId, &prompt.Text, &prompts.Source, &prompts.CreatedAt); err != nil {
+			return DailyPrompts{}, err
+		}
+		prompts.Prompts = append(prompts.Prompts, prompt)
+	}
+	if err := rows.Err(); err != nil {
+		return DailyPrompts{}, err
+	}
+	if len(prompts.Prompts) == 0 {
+		return DailyPrompts{}, sql.ErrNoRows
+	}
+	return prompts, nil
+}
+
+// saveDailyPrompts replaces the prompts of the day of prompts. Notes already
+// started from replaced prompts keep their text.
+func saveDailyPrompts(ctx context.Context, executor db_client.Executor, prompts DailyPrompts) error {
+	if _, err := executor.ExecContext(ctx, "DELETE FROM daily_prompts WHERE day = ?", prompts.Day); err != nil {
+		return err
+	}
+	for i, prompt := range prompts.Prompts {
+		_, err := executor.ExecContext(ctx, "INSERT INTO daily_prompts (prompt_id, day, position, text, source, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			prompt.PromptId, prompts.Day, i, prompt.Text, prompts.Source, prompts.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package prompt_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestService(t *testing.T, generator PromptGenerator) (*Service, notes_service.NotesService, *db_client.DBClient) {
+	t.Helper()
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbClient.Close(ctx) })
+	notesService := notes_service.NewNotesServiceImpl(dbClient)
+	return NewService(notesService, generator, dbClient), notesService, dbClient
+}
+
+// fakeGenerator counts the prompts it is asked for
+type fakeGenerator struct {
+	ready bool
+	err   error
+	calls int
+}
+
+func (generator *fakeGenerator) GenerateJournalPrompts(_ context.Context, entries []lm_service.PromptEntry, count int) ([]string, error) {
+	generator.calls++
+	if generator.err != nil {
+		return nil, generator.err
+	}
+	texts := []string{}
+	for i := range count {
+		texts = append(texts, fmt.Sprintf("About %s, part %d?", entries[0].Title, i+1))
+	}
+	return texts, nil
+}
+
+func (generator *fakeGenerator) GetStatus() bool { return generator.ready }
+
+func TestDailyAnswersBeforeTheModel(t *testing.T) {
+	ctx := context.Background()
+	generator := &fakeGenerator{ready: true}
+	service, notesService, _ := newTestService(t, generator)
+	if _, err := notesService.CreateNoteWithContent(ctx, "Allotment", "Dug the beds"); err != nil {
+		t.Fatal(err)
+	}
+
+	library, err := service.Daily(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if library.Source != SourceLibrary || len(library.Prompts) != PromptCount || generator.calls != 0 {
+		t.Fatalf("got %s prompts after %d generations, want library ones without any", library.Source, generator.calls)
+	}
+	select {
+	case <-service.trigger:
+	default:
+		t.Error("Daily did not schedule writing the prompts with the model")
+	}
+
+	if err := service.Personalise(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	personal, err := service.Daily(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if personal.Source != SourceModel || personal.Prompts[0].Text != "About Allotment, part 1?" {
+		t.Errorf("got %s prompts %v, want the model's", personal.Source, personal.Prompts)
+	}
+	select {
+	case <-service.trigger:
+		t.Error("Daily scheduled writing prompts the model already wrote")
+	default:
+	}
+}
+
+func TestPersonaliseWaitsAfterAFailure(t *testing.T) {
+	ctx := context.Background()
+	generator := &fakeGenerator{ready: true, err: errors.New("model crashed")}
+	service, notesService, _ := newTestService(t, generator)
+	if _, err := notesService.CreateNoteWithContent(ctx, "Allotment", "Dug the beds"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Personalise(ctx, time.Now()); err == nil {
+		t.Error("the failure was not reported")
+	}
+	if err := service.Personalise(ctx, time.Now()); err != nil || generator.calls != 1 {
+		t.Errorf("got %d generations: %v, want the retry to wait", generator.calls, err)
+	}
+	service.attemptedAt = time.Now().Add(-retryInterval)
+	generator.err = nil
+	if err := service.Personalise(ctx, time.Now()); err != nil || generator.calls != 2 {
+		t.Errorf("got %d generations: %v, want a retry", generator.calls, err)
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx := context.Background()
+	service, notesService, dbClient := newTestService(t, &fakeGenerator{})
+
+	promptId := uuid.New()
+	_, err := dbClient.ExecContext(ctx, "INSERT INTO daily_prompts (prompt_id, day, position, text, source, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		promptId, "2024-03-04", 0, "What went well today?", "library", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := service.Start(ctx, promptId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Prompt != "What went well today?" {
+		t.Errorf("got prompt %q", note.Prompt)
+	}
+	stored, err := notesService.GetNote(ctx, note.NoteId)
+	if err != nil || stored.Prompt != note.Prompt {
+		t.Errorf("got stored prompt %q: %v", stored.Prompt, err)
+	}
+
+	// No note is created for a prompt that cannot be found
+	if _, err := service.Start(ctx, uuid.New()); !errors.Is(err, ErrPromptNotFound) {
+		t.Errorf("got %v, want ErrPromptNotFound", err)
+	}
+	notes, err := notesService.GetAllNotes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 {
+		t.Errorf("got %d notes, want only the one started from a prompt", len(notes))
+	}
+}
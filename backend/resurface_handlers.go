@@ -0,0 +1,98 @@
+package main
+
+import (
+	"backend/resurface_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type OnThisDayRequest struct {
+	// Formatted as 2006-01-02, today when empty
+	Date string `json:"Date"`
+}
+
+type ResurfaceRequest struct {
+	// The day whose entries older ones are matched against, formatted as
+	// 2006-01-02, today when empty
+	Date string `json:"Date"`
+	// At most this many entries, 3 when zero
+	Limit int `json:"Limit"`
+	// Add a reflection on then versus now to each entry
+	Reflect bool `json:"Reflect"`
+}
+
+// parseDay parses a day in the backend's time zone, defaulting to today
+func parseDay(day string) (time.Time, error) {
+	if day == "" {
+		return time.Now(), nil
+	}
+	return time.ParseInLocation(time.DateOnly, day, time.Local)
+}
+
+func onThisDayHandler(w http.ResponseWriter, r *http.Request, resurfaceService *resurface_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req OnThisDayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	day, err := parseDay(req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := resurfaceService.OnThisDay(r.Context(), day)
+	if err != nil {
+		log.Printf("Error getting entries on this day: %v", err)
+		http.Error(w, "Failed to get entries", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func resurfaceHandler(w http.ResponseWriter, r *http.Request, resurfaceService *resurface_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResurfaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	day, err := parseDay(req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = resurface_service.DefaultLimit
+	}
+	if req.Limit < 0 || req.Limit > resurface_service.MaxLimit {
+		http.Error(w, "Limit must be between 1 and 10", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := resurfaceService.Resurface(r.Context(), day, req.Limit, req.Reflect)
+	if errors.Is(err, resurface_service.ErrModelUnavailable) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Error resurfacing entries: %v", err)
+		http.Error(w, "Failed to resurface entries", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,183 @@
+/*
+The resurface_service package brings past journal entries back: those written
+on the same calendar day in earlier years, and older entries related to what
+was written on a given day, optionally with a short reflection by the local
+model on then versus now.
+
+Relatedness is scored on the words entries share, weighted by how rare each
+word is across the journal, together with the overlap of the topics the entry
+analysis found in them.
+*/
+package resurface_service
+
+import (
+	"backend/notes_service"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 3
+	MaxLimit     = 10
+)
+
+// Entries younger than this are too recent to be worth resurfacing
+const minAge = 30 * 24 * time.Hour
+
+// Entries scoring lower than this are not related enough to resurface
+const minScore = 0.05
+
+// Share of the score given to topics rather than words when both entries
+// have been analysed
+const topicWeight = 0.3
+
+var ErrModelUnavailable = errors.New("the model is not available yet")
+
+// TopicSource gives the topics found in analysed notes
+type TopicSource interface {
+	Topics(ctx context.Context) (map[uuid.UUID][]string, error)
+}
+
+// Reflector is the part of the chat service reflections need
+type Reflector interface {
+	ReflectThenAndNow(ctx context.Context, thenDate time.Time, then string, now string) (string, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	notesService notes_service.NotesService
+	topics       TopicSource
+	reflector    Reflector
+}
+
+func NewService(notesService notes_service.NotesService, topics TopicSource, reflector Reflector) *Service {
+	return &Service{notesService: notesService, topics: topics, reflector: reflector}
+}
+
+type OnThisDayEntry struct {
+	YearsAgo int                `json:"YearsAgo"`
+	Note     notes_service.Note `json:"Note"`
+}
+
+type ResurfacedEntry struct {
+	Note notes_service.Note `json:"Note"`
+	// How related the note is to the day's entries, from 0 to 1
+	Score        float64  `json:"Score"`
+	SharedTopics []string `json:"SharedTopics"`
+	// Then versus now, only when asked for
+	Reflection string `json:"Reflection,omitempty"`
+}
+
+// OnThisDay returns the notes written on the month and day of day in earlier
+// years, the most recent year first. Days are taken in day's location. Notes
+// written on 29 February show on 28 February in other years.
+func (service *Service) OnThisDay(ctx context.Context, day time.Time) ([]OnThisDayEntry, error) {
+	notes, err := service.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loc := day.Location()
+	_, month, date := day.Date()
+	leapDayShown := month == time.February && date == 28 && !isLeapYear(day.Year())
+
+	entries := []OnThisDayEntry{}
+	for _, note := range notes {
+		created := note.CreatedAt.In(loc)
+		if created.Year() >= day.Year() {
+			continue
+		}
+		_, noteMonth, noteDate := created.Date()
+		if noteMonth == month && (noteDate == date || leapDayShown && noteDate == 29) {
+			entries = append(entries, OnThisDayEntry{YearsAgo: day.Year() - created.Year(), Note: note})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Note.CreatedAt.After(entries[j].Note.CreatedAt)
+	})
+	return entries, nil
+}
+
+// Resurface returns up to limit notes, at least a month older than day, that
+// are most related to the notes written on day, the most related first. With
+// reflect set, each comes with a reflection on then versus now. No notes are
+// returned if nothing was written on day.
+func (service *Service) Resurface(ctx context.Context, day time.Time, limit int, reflect bool) ([]ResurfacedEntry, error) {
+	if reflect && !service.reflector.GetStatus() {
+		return nil, ErrModelUnavailable
+	}
+	notes, err := service.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	cutoff := start.Add(-minAge)
+
+	documents := []string{}
+	written := []notes_service.Note{}
+	for _, note := range notes {
+		documents = append(documents, note.Title+"\n"+note.Content)
+		if !note.CreatedAt.Before(start) && note.CreatedAt.Before(end) {
+			written = append(written, note)
+		}
+	}
+	if len(written) == 0 {
+		return []ResurfacedEntry{}, nil
+	}
+
+	topics := map[uuid.UUID][]string{}
+	if service.topics != nil {
+		if topics, err = service.topics.Topics(ctx); err != nil {
+			return nil, err
+		}
+	}
+	index := newIndex(documents)
+	var writtenText strings.Builder
+	writtenTopics := []string{}
+	for _, note := range written {
+		writtenText.WriteString(note.Title + "\n" + note.Content + "\n\n")
+		writtenTopics = append(writtenTopics, topics[note.NoteId]...)
+	}
+	query := index.vector(writtenText.String())
+
+	resurfaced := []ResurfacedEntry{}
+	for _, note := range notes {
+		if !note.CreatedAt.Before(cutoff) {
+			continue
+		}
+		score := cosine(query, index.vector(note.Title+"\n"+note.Content))
+		shared, overlap := topicOverlap(writtenTopics, topics[note.NoteId])
+		if len(writtenTopics) > 0 && len(topics[note.NoteId]) > 0 {
+			score = (1-topicWeight)*score + topicWeight*overlap
+		}
+		if score >= minScore {
+			resurfaced = append(resurfaced, ResurfacedEntry{Note: note, Score: score, SharedTopics: shared})
+		}
+	}
+	sort.SliceStable(resurfaced, func(i, j int) bool {
+		return resurfaced[i].Score > resurfaced[j].Score
+	})
+	resurfaced = resurfaced[:min(limit, len(resurfaced))]
+
+	if reflect {
+		for i, entry := range resurfaced {
+			reflection, err := service.reflector.ReflectThenAndNow(ctx, entry.Note.CreatedAt.In(day.Location()), entry.Note.Content, writtenText.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to reflect on note %v: %w", entry.Note.NoteId, err)
+			}
+			resurfaced[i].Reflection = reflection
+		}
+	}
+	return resurfaced, nil
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
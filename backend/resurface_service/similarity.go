@@ -0,0 +1,117 @@
+package resurface_service
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Words too common to say anything about what an entry is about
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`about above after again against all also and any are because been before being
+		below between both but can could did does doing down during each few for from further had has have having
+		her here hers herself him himself his how into its itself just more most myself nor not now off once only
+		other our ours ourselves out over own same she should some such than that the their theirs them themselves
+		then there these they this those through too under until very was were what when where which while who whom
+		why will with would you your yours yourself yourselves today really still even much like get got going one
+		thing things feel felt think know day time`) {
+		stopWords[word] = true
+	}
+}
+
+// tokenize splits text into lower case words, leaving out short and common
+// ones
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := []string{}
+	for _, word := range words {
+		if len([]rune(word)) >= 3 && !stopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// index weights words by how rare they are across the journal, so entries
+// sharing an unusual word count as closer than entries sharing a common one
+type index struct {
+	idf map[string]float64
+}
+
+func newIndex(documents []string) index {
+	frequency := map[string]int{}
+	for _, document := range documents {
+		seen := map[string]bool{}
+		for _, token := range tokenize(document) {
+			if !seen[token] {
+				seen[token] = true
+				frequency[token]++
+			}
+		}
+	}
+	idf := map[string]float64{}
+	for token, count := range frequency {
+		idf[token] = math.Log(float64(1+len(documents))/float64(1+count)) + 1
+	}
+	return index{idf: idf}
+}
+
+// vector returns the TF-IDF vector of text, scaled to unit length
+func (index index) vector(text string) map[string]float64 {
+	vector := map[string]float64{}
+	for _, token := range tokenize(text) {
+		vector[token] += index.idf[token]
+	}
+	norm := 0.0
+	for _, weight := range vector {
+		norm += weight * weight
+	}
+	norm = math.Sqrt(norm)
+	for token := range vector {
+		vector[token] /= norm
+	}
+	return vector
+}
+
+// cosine is the cosine similarity of two unit vectors
+func cosine(a map[string]float64, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	dot := 0.0
+	for token, weight := range a {
+		dot += weight * b[token]
+	}
+	return dot
+}
+
+// topicOverlap returns the topics a and b share and their Jaccard index
+func topicOverlap(a []string, b []string) ([]string, float64) {
+	normalise := func(topics []string) []string {
+		normalised := []string{}
+		for _, topic := range topics {
+			topic = strings.ToLower(strings.TrimSpace(topic))
+			if topic != "" && !slices.Contains(normalised, topic) {
+				normalised = append(normalised, topic)
+			}
+		}
+		return normalised
+	}
+	a, b = normalise(a), normalise(b)
+	shared := []string{}
+	for _, topic := range a {
+		if slices.Contains(b, topic) {
+			shared = append(shared, topic)
+		}
+	}
+	union := len(a) + len(b) - len(shared)
+	if union == 0 {
+		return shared, 0
+	}
+	return shared, float64(len(shared)) / float64(union)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"backend/analysis_service"
+	"backend/backup"
+	"backend/clarity_service"
+	"backend/digest_service"
+	"backend/entity_service"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/prompt_service"
+	"backend/resurface_service"
+	"backend/task_service"
+	"backend/title_service"
+	"backend/vault_sync"
+	"log"
+	"net/http"
+)
+
+// routeDeps is everything the endpoints are served with. vaultSyncer is nil
+// when no vault folder is configured.
+type routeDeps struct {
+	config           Config
+	notesService     notes_service.NotesService
+	checkInService   *notes_service.CheckInService
+	statsService     *notes_service.StatsService
+	linkService      *notes_service.LinkService
+	templateService  *notes_service.TemplateService
+	chatService      *lm_service.ChatServiceImpl
+	clarityService   *clarity_service.Service
+	analysisService  *analysis_service.Service
+	taskService      *task_service.Service
+	entityService    *entity_service.Service
+	titleService     *title_service.Service
+	digestService    *digest_service.Service
+	resurfaceService *resurface_service.Service
+	promptService    *prompt_service.Service
+	backups          *backup.Manager
+	vaultSyncer      *vault_sync.Syncer
+}
+
+// registerRoutes registers every endpoint on mux behind the CORS and token
+// checks and returns their paths, which the tests compare with openapi.json
+func registerRoutes(mux *http.ServeMux, deps routeDeps) []string {
+	paths := []string{}
+	handle := func(path string, handler http.HandlerFunc) {
+		mux.HandleFunc(path, protectHandler(deps.config, handler))
+		paths = append(paths, path)
+	}
+
+	handle("/hello", helloHandler)
+	handle("/openapi.json", openAPIHandler)
+	handle("/createnote", func(w http.ResponseWriter, r *http.Request) {
+		createNote(w, r, deps.notesService)
+	})
+	handle("/createnote/template", func(w http.ResponseWriter, r *http.Request) {
+		createNoteFromTemplateHandler(w, r, deps.notesService, deps.templateService)
+	})
+	handle("/templates", func(w http.ResponseWriter, r *http.Request) {
+		templatesHandler(w, r, deps.templateService)
+	})
+	handle("/templates/delete", func(w http.ResponseWriter, r *http.Request) {
+		deleteTemplateHandler(w, r, deps.templateService)
+	})
+	handle("/updatenote", func(w http.ResponseWriter, r *http.Request) {
+		updateNote(w, r, deps.notesService)
+	})
+	handle("/getallnotes", func(w http.ResponseWriter, r *http.Request) {
+		getAllNotes(w, r, deps.notesService)
+	})
+
+	handle("/getnote", func(w http.ResponseWriter, r *http.Request) {
+		getNote(w, r, deps.notesService)
+	})
+
+	handle("/chat", func(w http.ResponseWriter, r *http.Request) {
+		log.Println("/chat request received")
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		chatStream(w, r, deps.chatService)
+	})
+
+	handle("/clarity", func(w http.ResponseWriter, r *http.Request) {
+		clarityStreamHandler(w, r, deps.clarityService)
+	})
+
+	handle("/clarity/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		regenerateClarityHandler(w, r, deps.clarityService)
+	})
+
+	handle("/clarity/reports", func(w http.ResponseWriter, r *http.Request) {
+		clarityHistoryHandler(w, r, deps.clarityService)
+	})
+
+	handle("/clarity/report", func(w http.ResponseWriter, r *http.Request) {
+		getClarityReportHandler(w, r, deps.clarityService)
+	})
+
+	handle("/deletenote", func(w http.ResponseWriter, r *http.Request) {
+		deleteNote(w, r, deps.notesService)
+	})
+
+	handle("/checkin", func(w http.ResponseWriter, r *http.Request) {
+		setCheckInHandler(w, r, deps.notesService, deps.checkInService)
+	})
+
+	handle("/checkin/fields", func(w http.ResponseWriter, r *http.Request) {
+		checkInFieldsHandler(w, r, deps.checkInService)
+	})
+
+	handle("/checkin/fields/delete", func(w http.ResponseWriter, r *http.Request) {
+		deleteCheckInFieldHandler(w, r, deps.checkInService)
+	})
+
+	handle("/checkin/series", func(w http.ResponseWriter, r *http.Request) {
+		checkInSeriesHandler(w, r, deps.checkInService)
+	})
+
+	handle("/stats", func(w http.ResponseWriter, r *http.Request) {
+		writingStatsHandler(w, r, deps.statsService)
+	})
+
+	handle("/stats/activity", func(w http.ResponseWriter, r *http.Request) {
+		activityHandler(w, r, deps.statsService)
+	})
+
+	handle("/analysis/note", func(w http.ResponseWriter, r *http.Request) {
+		noteAnalysisHandler(w, r, deps.analysisService)
+	})
+
+	handle("/analysis/emotions", func(w http.ResponseWriter, r *http.Request) {
+		emotionSeriesHandler(w, r, deps.analysisService)
+	})
+
+	handle("/analysis/status", func(w http.ResponseWriter, r *http.Request) {
+		analysisStatusHandler(w, r, deps.analysisService)
+	})
+
+	handle("/links/note", func(w http.ResponseWriter, r *http.Request) {
+		noteLinksHandler(w, r, deps.linkService)
+	})
+	handle("/links/backlinks", func(w http.ResponseWriter, r *http.Request) {
+		backlinksHandler(w, r, deps.linkService)
+	})
+	handle("/links/unresolved", func(w http.ResponseWriter, r *http.Request) {
+		unresolvedLinksHandler(w, r, deps.linkService)
+	})
+	handle("/tasks", func(w http.ResponseWriter, r *http.Request) {
+		listTasksHandler(w, r, deps.taskService)
+	})
+	handle("/tasks/note", func(w http.ResponseWriter, r *http.Request) {
+		noteTasksHandler(w, r, deps.taskService)
+	})
+	handle("/tasks/status", func(w http.ResponseWriter, r *http.Request) {
+		setTaskStatusHandler(w, r, deps.taskService)
+	})
+	handle("/entities", func(w http.ResponseWriter, r *http.Request) {
+		listEntitiesHandler(w, r, deps.entityService)
+	})
+	handle("/entities/status", func(w http.ResponseWriter, r *http.Request) {
+		setEntityStatusHandler(w, r, deps.entityService)
+	})
+	handle("/entities/update", func(w http.ResponseWriter, r *http.Request) {
+		updateEntityHandler(w, r, deps.entityService)
+	})
+	handle("/entities/merge", func(w http.ResponseWriter, r *http.Request) {
+		mergeEntitiesHandler(w, r, deps.entityService)
+	})
+	handle("/entities/mentions", func(w http.ResponseWriter, r *http.Request) {
+		entityMentionsHandler(w, r, deps.entityService)
+	})
+	handle("/entities/sentiment", func(w http.ResponseWriter, r *http.Request) {
+		entitySentimentHandler(w, r, deps.entityService)
+	})
+	handle("/title/revert", func(w http.ResponseWriter, r *http.Request) {
+		revertTitleHandler(w, r, deps.titleService)
+	})
+
+	handle("/digests", func(w http.ResponseWriter, r *http.Request) {
+		listDigestsHandler(w, r, deps.digestService)
+	})
+
+	handle("/digest", func(w http.ResponseWriter, r *http.Request) {
+		getDigestHandler(w, r, deps.digestService)
+	})
+
+	handle("/digests/generate", func(w http.ResponseWriter, r *http.Request) {
+		generateDigestHandler(w, r, deps.digestService)
+	})
+
+	handle("/onthisday", func(w http.ResponseWriter, r *http.Request) {
+		onThisDayHandler(w, r, deps.resurfaceService)
+	})
+
+	handle("/resurface", func(w http.ResponseWriter, r *http.Request) {
+		resurfaceHandler(w, r, deps.resurfaceService)
+	})
+
+	handle("/prompts/daily", func(w http.ResponseWriter, r *http.Request) {
+		dailyPromptsHandler(w, r, deps.promptService)
+	})
+	handle("/prompts/start", func(w http.ResponseWriter, r *http.Request) {
+		startPromptHandler(w, r, deps.promptService)
+	})
+	handle("/export/markdown", func(w http.ResponseWriter, r *http.Request) {
+		exportMarkdownHandler(w, r, deps.notesService, deps.config.ExportDir)
+	})
+
+	handle("/export/html", func(w http.ResponseWriter, r *http.Request) {
+		exportHTMLHandler(w, r, deps.notesService, deps.clarityService, deps.digestService)
+	})
+
+	handle("/import", func(w http.ResponseWriter, r *http.Request) {
+		importHandler(w, r, deps.notesService, deps.checkInService)
+	})
+
+	handle("/import/markdown", func(w http.ResponseWriter, r *http.Request) {
+		importMarkdownHandler(w, r, deps.notesService)
+	})
+
+	handle("/import/dayone", func(w http.ResponseWriter, r *http.Request) {
+		importDayOneHandler(w, r, deps.notesService)
+	})
+
+	handle("/backup", func(w http.ResponseWriter, r *http.Request) {
+		createBackupHandler(w, r, deps.backups)
+	})
+
+	handle("/backups", func(w http.ResponseWriter, r *http.Request) {
+		listBackupsHandler(w, r, deps.backups)
+	})
+
+	handle("/backup/download", func(w http.ResponseWriter, r *http.Request) {
+		downloadBackupHandler(w, r, deps.backups)
+	})
+
+	handle("/backup/restore", func(w http.ResponseWriter, r *http.Request) {
+		restoreBackupHandler(w, r, deps.backups)
+	})
+
+	handle("/vault/sync", func(w http.ResponseWriter, r *http.Request) {
+		vaultSyncHandler(w, r, deps.vaultSyncer)
+	})
+	return paths
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import "time"
+
+// Schedule decides when a job is next due. Calendar schedules are evaluated
+// in the local time zone, so a job set for 06:00 runs at 06:00 local time on
+// both sides of a daylight saving change.
+type Schedule interface {
+	// Next returns the first time strictly after after that the job is due
+	Next(after time.Time) time.Time
+}
+
+// Every is due at a fixed interval
+type Every time.Duration
+
+func (every Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(every))
+}
+
+// Weekly is due once a week on Weekday at Hour:Minute
+type Weekly struct {
+	Weekday time.Weekday
+	Hour    int
+	Minute  int
+}
+
+func (weekly Weekly) Next(after time.Time) time.Time {
+	after = after.In(time.Local)
+	year, month, day := after.Date()
+	days := (int(weekly.Weekday) - int(after.Weekday()) + 7) % 7
+	next := time.Date(year, month, day+days, weekly.Hour, weekly.Minute, 0, 0, time.Local)
+	if !next.After(after) {
+		next = time.Date(year, month, day+days+7, weekly.Hour, weekly.Minute, 0, 0, time.Local)
+	}
+	return next
+}
+
+// Monthly is due once a month on Day at Hour:Minute. Days past the end of a
+// short month fall on its last day.
+type Monthly struct {
+	Day    int
+	Hour   int
+	Minute int
+}
+
+func (monthly Monthly) Next(after time.Time) time.Time {
+	after = after.In(time.Local)
+	year, month, _ := after.Date()
+	for {
+		next := time.Date(year, month, min(monthly.Day, daysIn(year, month)), monthly.Hour, monthly.Minute, 0, 0, time.Local)
+		if next.After(after) {
+			return next
+		}
+		month++
+		if month > time.December {
+			year, month = year+1, time.January
+		}
+	}
+}
+
+func daysIn(year int, month time.Month) int {
+	// Day 0 of the next month is the last day of this one
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.Local).Day()
+}
@@ -0,0 +1,142 @@
+/*
+The scheduler package runs background jobs at calendar times, such as every
+Monday at 06:00 local time.
+
+Jobs run one at a time on a single goroutine. Due times are checked against
+the wall clock every minute rather than waited for with one long timer, so a
+job that came due while the computer was asleep runs shortly after it wakes.
+*/
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// How often due times are checked
+const tick = time.Minute
+
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Also run the job when the scheduler starts, for jobs that catch up on
+	// work missed while the backend was not running
+	RunAtStart bool
+	// How long to wait before running a failed job again. Zero waits for the
+	// next scheduled time.
+	RetryAfter time.Duration
+	Run        func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	started bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type scheduledJob struct {
+	Job
+	next time.Time
+}
+
+func New() *Scheduler {
+	return &Scheduler{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (scheduler *Scheduler) Add(job Job) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	if scheduler.started {
+		panic("scheduler: job " + job.Name + " added after Start")
+	}
+	scheduler.jobs = append(scheduler.jobs, &scheduledJob{Job: job})
+}
+
+// Start runs the jobs in the background until ctx is cancelled or the
+// scheduler is closed
+func (scheduler *Scheduler) Start(ctx context.Context) {
+	scheduler.mu.Lock()
+	scheduler.started = true
+	now := time.Now()
+	for _, job := range scheduler.jobs {
+		if job.RunAtStart {
+			job.next = now
+		} else {
+			job.next = job.Schedule.Next(now)
+		}
+	}
+	scheduler.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-scheduler.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		defer close(scheduler.done)
+		defer cancel()
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			scheduler.runDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops the scheduler, cancelling a running job, and waits for it to
+// return
+func (scheduler *Scheduler) Close(ctx context.Context) error {
+	scheduler.stopOnce.Do(func() { close(scheduler.stop) })
+	scheduler.mu.Lock()
+	started := scheduler.started
+	scheduler.mu.Unlock()
+	if !started {
+		return nil
+	}
+	select {
+	case <-scheduler.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (scheduler *Scheduler) runDue(ctx context.Context) {
+	for _, job := range scheduler.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Now().Before(job.next) {
+			continue
+		}
+		err := job.Run(ctx)
+		now := time.Now()
+		job.next = job.Schedule.Next(now)
+		if err == nil || errors.Is(err, context.Canceled) {
+			continue
+		}
+		if job.RetryAfter > 0 && now.Add(job.RetryAfter).Before(job.next) {
+			job.next = now.Add(job.RetryAfter)
+		}
+		log.Printf("Scheduled job %q failed, next run at %s: %v", job.Name, job.next.Format(time.DateTime), err)
+	}
+}
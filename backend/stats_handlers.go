@@ -0,0 +1,72 @@
+package main
+
+import (
+	"backend/notes_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type ActivityRequest struct {
+	// First and last day, e.g. 2024-01-05, both included
+	From string `json:"From"`
+	To   string `json:"To"`
+	// "day" (the default) or "week"
+	Bucket string `json:"Bucket"`
+}
+
+func writingStatsHandler(w http.ResponseWriter, r *http.Request, statsService *notes_service.StatsService) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := statsService.Summary(r.Context(), time.Now())
+	if err != nil {
+		log.Printf("Error computing writing stats: %v", err)
+		http.Error(w, "Failed to compute stats", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// activityHandler counts entries per day or week, every day or week in the
+// range included so it can be drawn as a calendar heatmap
+func activityHandler(w http.ResponseWriter, r *http.Request, statsService *notes_service.StatsService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ActivityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	from, err := time.ParseInLocation(time.DateOnly, req.From, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid From date", http.StatusBadRequest)
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, req.To, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid To date", http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := statsService.Activity(r.Context(), from, to, req.Bucket)
+	if errors.Is(err, notes_service.ErrInvalidDateRange) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error computing activity: %v", err)
+		http.Error(w, "Failed to compute activity", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
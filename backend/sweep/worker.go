@@ -0,0 +1,221 @@
+/*
+The sweep package runs the background jobs that read every note with the
+local model, such as the entry analysis and the task extraction.
+
+A job stores the content hash of each note it has read, so a note is read
+again only once its title or content changes. The worker reads the changed
+notes shortly after a change is made through the notes service, and sweeps
+for them every few minutes to pick up notes that could not be read while the
+model was loading and edits that bypass the notification, such as those from
+the vault.
+*/
+package sweep
+
+import (
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How often changed notes are checked for without a change being notified
+const sweepInterval = 5 * time.Minute
+
+// Job is the part of a background job that differs between jobs
+type Job struct {
+	// Names the job in logs, e.g. "Task extraction"
+	Name string
+	// Bumped when the prompt or schema changes, so every note is read again
+	Version string
+	// Entries are saved as they are typed, so reading waits for edits to
+	// settle this long
+	Debounce time.Duration
+	// Reports whether the model is loaded
+	Ready func() bool
+	// Returns the content hash of every note the job has stored a result for
+	Hashes func(ctx context.Context) (map[uuid.UUID]string, error)
+	// Reads a changed note and stores the result along with its ContentHash.
+	// Notes without content are passed once something is stored for them, to
+	// clear it. Errors wrapping lm_service.ErrInvalidOutput skip the note
+	// until it changes again, any other error stops the sweep.
+	Read func(ctx context.Context, note notes_service.Note) error
+	// Optionally called after every sweep, e.g. to clean up
+	AfterSweep func(ctx context.Context) error
+}
+
+type Worker struct {
+	notesService notes_service.NotesService
+	job          Job
+
+	// Held while notes are being read
+	mu sync.Mutex
+	// Notes the model gave no usable output for, with the content hash of
+	// the version it was shown, so they are only tried again once edited
+	failed map[uuid.UUID]string
+
+	trigger  chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func New(notesService notes_service.NotesService, job Job) *Worker {
+	return &Worker{
+		notesService: notesService,
+		job:          job,
+		failed:       map[uuid.UUID]string{},
+		trigger:      make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// ContentHash identifies what the model was shown for a note by a job at
+// version
+func ContentHash(version string, note notes_service.Note) string {
+	sum := sha256.Sum256([]byte(version + "\n" + note.Title + "\n" + note.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// NotifyOnChange wraps notesService so that changes made through it schedule
+// a sweep
+func (worker *Worker) NotifyOnChange(notesService notes_service.NotesService) notes_service.NotesService {
+	return notes_service.NotifyOnChange(notesService, worker.Trigger)
+}
+
+// Run reads changed notes after changes and every sweep interval, until ctx
+// is cancelled or the worker is closed.
+func (worker *Worker) Run(ctx context.Context) {
+	defer close(worker.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-worker.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// The first run waits as long as after a change, giving the model time to
+	// load
+	timer := time.NewTimer(worker.job.Debounce)
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-worker.trigger:
+			timer.Reset(worker.job.Debounce)
+		case <-timer.C:
+			worker.sweepAndLog(ctx)
+		case <-ticker.C:
+			worker.sweepAndLog(ctx)
+		}
+	}
+}
+
+// Trigger schedules a sweep, e.g. after a note changed
+func (worker *Worker) Trigger() {
+	select {
+	case worker.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the worker, abandoning the note being read, and waits for it
+// to finish
+func (worker *Worker) Close(ctx context.Context) error {
+	worker.stopOnce.Do(func() { close(worker.stop) })
+	select {
+	case <-worker.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (worker *Worker) sweepAndLog(ctx context.Context) {
+	if !worker.job.Ready() {
+		return
+	}
+	read, err := worker.Sweep(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("%s stopped after %d notes: %v", worker.job.Name, read, err)
+		return
+	}
+	if read > 0 {
+		log.Printf("%s read %d notes", worker.job.Name, read)
+	}
+}
+
+// Sweep reads every note that changed since the job last read it and
+// returns how many were. It stops at the first error reaching the model,
+// while notes the model returns unusable output for are skipped until they
+// change.
+func (worker *Worker) Sweep(ctx context.Context) (int, error) {
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+
+	pending, err := worker.Pending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	read := 0
+	for _, note := range pending {
+		if err := ctx.Err(); err != nil {
+			return read, err
+		}
+		hash := ContentHash(worker.job.Version, note)
+		if worker.failed[note.NoteId] == hash {
+			continue
+		}
+		err := worker.job.Read(ctx, note)
+		if errors.Is(err, lm_service.ErrInvalidOutput) {
+			log.Printf("%s skipped note %v: %v", worker.job.Name, note.NoteId, err)
+			worker.failed[note.NoteId] = hash
+			continue
+		}
+		if err != nil {
+			return read, fmt.Errorf("failed to read note %v: %w", note.NoteId, err)
+		}
+		delete(worker.failed, note.NoteId)
+		read++
+	}
+	if worker.job.AfterSweep != nil {
+		return read, worker.job.AfterSweep(ctx)
+	}
+	return read, nil
+}
+
+// Pending returns the notes that changed since the job last read them
+func (worker *Worker) Pending(ctx context.Context) ([]notes_service.Note, error) {
+	notes, err := worker.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := worker.job.Hashes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []notes_service.Note{}
+	for _, note := range notes {
+		hash, stored := hashes[note.NoteId]
+		empty := strings.TrimSpace(note.Content) == ""
+		if hash != ContentHash(worker.job.Version, note) && (stored || !empty) {
+			pending = append(pending, note)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,63 @@
+package sweep
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSweepSkipsUnusableNotesUntilEdited(t *testing.T) {
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbClient.Close(ctx)
+	notesService := notes_service.NewNotesServiceImpl(dbClient)
+	note, err := notesService.CreateNoteWithContent(ctx, "Walk", "A long walk by the river")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notesService.CreateNote(ctx, notes_service.DefaultTitle); err != nil {
+		t.Fatal(err)
+	}
+
+	reads := 0
+	worker := New(notesService, Job{
+		Version: "1",
+		Ready:   func() bool { return true },
+		Hashes: func(ctx context.Context) (map[uuid.UUID]string, error) {
+			return map[uuid.UUID]string{}, nil
+		},
+		Read: func(ctx context.Context, note notes_service.Note) error {
+			reads++
+			return fmt.Errorf("%w: no JSON", lm_service.ErrInvalidOutput)
+		},
+	})
+	for range 2 {
+		if _, err := worker.Sweep(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The empty note has nothing stored for it, so it is never read
+	if reads != 1 {
+		t.Errorf("read %d times with the note unchanged, want 1", reads)
+	}
+
+	note.Content += " and back"
+	if _, err := notesService.UpdateNote(ctx, note); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worker.Sweep(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 2 {
+		t.Errorf("read %d times after the note changed, want 2", reads)
+	}
+}
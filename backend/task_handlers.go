@@ -0,0 +1,104 @@
+package main
+
+import (
+	"backend/notes_service"
+	"backend/task_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+type SetTaskStatusRequest struct {
+	TaskId string `json:"TaskId"`
+	// "accepted", "dismissed", "completed", or "proposed" to undo a decision
+	Status string `json:"Status"`
+}
+
+// listTasksHandler lists tasks newest first, of one status when the status
+// query parameter is set
+func listTasksHandler(w http.ResponseWriter, r *http.Request, taskService *task_service.Service) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tasks, err := taskService.Tasks(r.Context(), r.URL.Query().Get("status"))
+	if errors.Is(err, task_service.ErrInvalidStatus) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+		http.Error(w, "Failed to list tasks", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+func noteTasksHandler(w http.ResponseWriter, r *http.Request, taskService *task_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noteId, err := uuid.Parse(req.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := taskService.NoteTasks(r.Context(), noteId)
+	if errors.Is(err, notes_service.ErrNoteNotFound) {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting tasks of note %v: %v", noteId, err)
+		http.Error(w, "Failed to get tasks", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// setTaskStatusHandler accepts, dismisses or completes a task
+func setTaskStatusHandler(w http.ResponseWriter, r *http.Request, taskService *task_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetTaskStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	taskId, err := uuid.Parse(req.TaskId)
+	if err != nil {
+		http.Error(w, "Invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	task, err := taskService.SetStatus(r.Context(), taskId, req.Status)
+	switch {
+	case errors.Is(err, task_service.ErrInvalidStatus):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, task_service.ErrTaskNotFound):
+		http.Error(w, "Task not found", http.StatusNotFound)
+	case err != nil:
+		log.Printf("Error setting the status of task %v: %v", taskId, err)
+		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(task)
+	}
+}
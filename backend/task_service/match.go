@@ -0,0 +1,60 @@
+package task_service
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Share of the words of the shorter of two tasks the longer must contain for
+// them to count as the same task, e.g. "call mum" and "call mum tomorrow"
+const minOverlap = 0.8
+
+// fingerprint normalises a task's text to lower case words
+func fingerprint(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// sameTask reports whether two fingerprints describe the same task. Short
+// tasks must match exactly, as a single shared word says little.
+func sameTask(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA) < 2 || len(wordsB) < 2 {
+		return false
+	}
+	inB := map[string]bool{}
+	for _, word := range wordsB {
+		inB[word] = true
+	}
+	shared := 0
+	for _, word := range wordsA {
+		if inB[word] {
+			shared++
+			delete(inB, word)
+		}
+	}
+	return float64(shared)/float64(min(len(wordsA), len(wordsB))) >= minOverlap
+}
+
+// locate finds quote in content and returns where it starts and ends in
+// characters. Models tend to add or drop a full stop, so a quote is also
+// looked for without its closing punctuation.
+func locate(content string, quote string) (*int, *int) {
+	for _, candidate := range []string{quote, strings.TrimRight(quote, ".!?;, ")} {
+		if candidate == "" {
+			continue
+		}
+		if i := strings.Index(content, candidate); i >= 0 {
+			start := utf8.RuneCountInString(content[:i])
+			end := start + utf8.RuneCountInString(candidate)
+			return &start, &end
+		}
+	}
+	return nil, nil
+}
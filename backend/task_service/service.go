@@ -0,0 +1,205 @@
+/*
+The task_service package finds action items in journal entries with the local
+model, such as "I need to call mum", and keeps them as tasks linked to the
+note and the words they were found in.
+
+Tasks start out proposed and are accepted, dismissed or completed by the user.
+Like the entry analysis, notes are read again in the background whenever their
+content changes. A new reading replaces the proposals still waiting on a note
+but leaves the tasks the user has acted on alone, and a task matching one
+already on the note, dismissed ones included, is not proposed again.
+*/
+package task_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entries are saved as they are typed, so extraction waits for edits to
+// settle
+const debounce = time.Minute
+
+var (
+	ErrTaskNotFound  = errors.New("task not found")
+	ErrInvalidStatus = fmt.Errorf("status must be %q, %q, %q or %q", StatusProposed, StatusAccepted, StatusDismissed, StatusCompleted)
+)
+
+// Extractor is the part of the chat service the job needs
+type Extractor interface {
+	ExtractTasks(ctx context.Context, title string, content string) ([]lm_service.ExtractedTask, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	// Provides Run, Trigger, Close and NotifyOnChange
+	*sweep.Worker
+	notesService notes_service.NotesService
+	extractor    Extractor
+	dbClient     *db_client.DBClient
+}
+
+func NewService(notesService notes_service.NotesService, extractor Extractor, dbClient *db_client.DBClient) *Service {
+	service := &Service{
+		notesService: notesService,
+		extractor:    extractor,
+		dbClient:     dbClient,
+	}
+	service.Worker = sweep.New(notesService, sweep.Job{
+		Name:     "Task extraction",
+		Version:  extractionVersion,
+		Debounce: debounce,
+		Ready:    extractor.GetStatus,
+		Hashes: func(ctx context.Context) (map[uuid.UUID]string, error) {
+			return loadHashes(ctx, dbClient)
+		},
+		Read: service.read,
+	})
+	return service
+}
+
+// read records the tasks found in a note
+func (service *Service) read(ctx context.Context, note notes_service.Note) error {
+	extracted := []lm_service.ExtractedTask{}
+	if strings.TrimSpace(note.Content) != "" {
+		var err error
+		extracted, err = service.extractor.ExtractTasks(ctx, note.Title, note.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		return mergeTasks(ctx, tx, note, extracted)
+	})
+}
+
+// mergeTasks records the tasks just extracted from note. Tasks already on
+// the note are placed in its current content again, following their words
+// when the model finds them reworded; proposals the model no longer finds are
+// removed, and extracted tasks matching any task left on the note are not
+// added.
+func mergeTasks(ctx context.Context, executor db_client.Executor, note notes_service.Note, extracted []lm_service.ExtractedTask) error {
+	existing, err := loadTasks(ctx, executor, "t.note_id = ?", "t.created_at", note.NoteId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	// The quotes of the tasks already on the note the model found again
+	found := map[uuid.UUID]string{}
+	kept := []Task{}
+	for _, task := range extracted {
+		start, end := locate(note.Content, task.Quote)
+		if start == nil {
+			// The model quoted words that are not in the entry
+			continue
+		}
+		taskFingerprint := fingerprint(task.Task)
+		if taskFingerprint == "" {
+			continue
+		}
+		matched := false
+		for _, other := range append(existing, kept...) {
+			if sameTask(other.fingerprint, taskFingerprint) {
+				found[other.TaskId] = task.Quote
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		kept = append(kept, Task{
+			TaskId:      uuid.New(),
+			NoteId:      note.NoteId,
+			Text:        task.Task,
+			Quote:       task.Quote,
+			Status:      StatusProposed,
+			StartOffset: start,
+			EndOffset:   end,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			fingerprint: taskFingerprint,
+		})
+	}
+
+	for _, task := range existing {
+		quote, ok := found[task.TaskId]
+		if task.Status == StatusProposed && !ok {
+			if _, err := executor.ExecContext(ctx, "DELETE FROM tasks WHERE id = ?", task.TaskId); err != nil {
+				return err
+			}
+			continue
+		}
+		start, end := locate(note.Content, task.Quote)
+		if start == nil && ok {
+			task.Quote = quote
+			start, end = locate(note.Content, quote)
+		}
+		_, err := executor.ExecContext(ctx, "UPDATE tasks SET quote = ?, start_offset = ?, end_offset = ? WHERE id = ?", task.Quote, start, end, task.TaskId)
+		if err != nil {
+			return err
+		}
+	}
+	for _, task := range kept {
+		if err := insertTask(ctx, executor, task); err != nil {
+			return err
+		}
+	}
+	return saveExtraction(ctx, executor, note)
+}
+
+// Tasks returns the tasks with status, or every task when status is empty,
+// the newest first
+func (service *Service) Tasks(ctx context.Context, status string) ([]Task, error) {
+	if status == "" {
+		return loadTasks(ctx, service.dbClient, "1", "julianday(t.created_at) DESC")
+	}
+	if !isStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	return loadTasks(ctx, service.dbClient, "t.status = ?", "julianday(t.created_at) DESC", status)
+}
+
+// NoteTasks returns the tasks found in a note in the order they appear
+func (service *Service) NoteTasks(ctx context.Context, noteId uuid.UUID) ([]Task, error) {
+	if _, err := service.notesService.GetNote(ctx, noteId); err != nil {
+		return nil, err
+	}
+	return loadTasks(ctx, service.dbClient, "t.note_id = ?", "t.start_offset IS NULL, t.start_offset, t.created_at", noteId)
+}
+
+// SetStatus accepts, dismisses, completes or reopens a task
+func (service *Service) SetStatus(ctx context.Context, id uuid.UUID, status string) (Task, error) {
+	if !isStatus(status) {
+		return Task{}, ErrInvalidStatus
+	}
+	var task Task
+	err := service.dbClient.WithTx(ctx, func(tx *db_client.Tx) error {
+		now := time.Now()
+		var completedAt *time.Time
+		if status == StatusCompleted {
+			completedAt = &now
+		}
+		result, err := tx.ExecContext(ctx, `UPDATE tasks SET status = ?, updated_at = ?,
+			completed_at = CASE WHEN ? = status THEN completed_at ELSE ? END WHERE id = ?`, status, now, status, completedAt, id)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return fmt.Errorf("%w: %v", ErrTaskNotFound, id)
+		}
+		task, err = getTask(ctx, tx, id)
+		return err
+	})
+	return task, err
+}
@@ -0,0 +1,127 @@
+package task_service
+
+import (
+	"backend/db"
+	"backend/notes_service"
+	"backend/sweep"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Found by the model and waiting to be accepted or dismissed
+	StatusProposed  = "proposed"
+	StatusAccepted  = "accepted"
+	StatusDismissed = "dismissed"
+	StatusCompleted = "completed"
+)
+
+type Task struct {
+	TaskId    uuid.UUID `json:"TaskId"`
+	NoteId    uuid.UUID `json:"NoteId"`
+	NoteTitle string    `json:"NoteTitle"`
+	// The task as a short imperative, e.g. "Call mum"
+	Text string `json:"Text"`
+	// The words of the note the task was found in
+	Quote  string `json:"Quote"`
+	Status string `json:"Status"`
+	// Where Quote starts and ends in the note's content, in characters. Nil
+	// once the note no longer contains it.
+	StartOffset *int       `json:"StartOffset"`
+	EndOffset   *int       `json:"EndOffset"`
+	CreatedAt   time.Time  `json:"CreatedAt"`
+	UpdatedAt   time.Time  `json:"UpdatedAt"`
+	CompletedAt *time.Time `json:"CompletedAt,omitempty"`
+
+	fingerprint string
+}
+
+// Bumped when the prompt or schema changes, so every note is read again
+const extractionVersion = "1"
+
+// loadHashes returns the content hash of every note tasks were extracted from
+func loadHashes(ctx context.Context, executor db_client.Executor) (map[uuid.UUID]string, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, content_hash FROM task_extractions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[uuid.UUID]string{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var hash string
+		if err := rows.Scan(&noteId, &hash); err != nil {
+			return nil, err
+		}
+		hashes[noteId] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// loadTasks loads the tasks matching where, a condition on the tasks table
+// aliased t, in the given order
+func loadTasks(ctx context.Context, executor db_client.Executor, where string, orderBy string, args ...any) ([]Task, error) {
+	rows, err := executor.QueryContext(ctx, `SELECT t.id, t.note_id, n.title, t.text, t.quote, t.fingerprint, t.status, t.start_offset, t.end_offset,
+		t.created_at, t.updated_at, t.completed_at FROM tasks t JOIN notes n ON n.id = t.note_id WHERE `+where+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		var task Task
+		var completedAt sql.NullTime
+		err := rows.Scan(&task.TaskId, &task.NoteId, &task.NoteTitle, &task.Text, &task.Quote, &task.fingerprint, &task.Status,
+			&task.StartOffset, &task.EndOffset, &task.CreatedAt, &task.UpdatedAt, &completedAt)
+		if err != nil {
+			return nil, err
+		}
+		if completedAt.Valid {
+			task.CompletedAt = &completedAt.Time
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func getTask(ctx context.Context, executor db_client.Executor, id uuid.UUID) (Task, error) {
+	tasks, err := loadTasks(ctx, executor, "t.id = ?", "t.id", id)
+	if err != nil {
+		return Task{}, err
+	}
+	if len(tasks) == 0 {
+		return Task{}, fmt.Errorf("%w: %v", ErrTaskNotFound, id)
+	}
+	return tasks[0], nil
+}
+
+func insertTask(ctx context.Context, executor db_client.Executor, task Task) error {
+	_, err := executor.ExecContext(ctx, `INSERT INTO tasks (id, note_id, text, quote, fingerprint, status, start_offset, end_offset, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.TaskId, task.NoteId, task.Text, task.Quote, task.fingerprint, task.Status, task.StartOffset, task.EndOffset, task.CreatedAt, task.UpdatedAt)
+	return err
+}
+
+// saveExtraction records that the tasks of note were extracted from its
+// current content
+func saveExtraction(ctx context.Context, executor db_client.Executor, note notes_service.Note) error {
+	_, err := executor.ExecContext(ctx, `INSERT INTO task_extractions (note_id, content_hash, extracted_at) VALUES (?, ?, ?)
+		ON CONFLICT (note_id) DO UPDATE SET content_hash = excluded.content_hash, extracted_at = excluded.extracted_at`,
+		note.NoteId, sweep.ContentHash(extractionVersion, note), time.Now())
+	return err
+}
+
+// isStatus reports whether status is one tasks can be set to
+func isStatus(status string) bool {
+	switch status {
+	case StatusProposed, StatusAccepted, StatusDismissed, StatusCompleted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"backend/notes_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TemplateRequest struct {
+	TemplateId string `json:"TemplateId"`
+}
+
+// templatesHandler lists the templates on GET and creates or updates one on
+// POST, creating it when it has no id
+func templatesHandler(w http.ResponseWriter, r *http.Request, templateService *notes_service.TemplateService) {
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := templateService.Templates(r.Context())
+		if err != nil {
+			log.Printf("Error listing templates: %v", err)
+			http.Error(w, "Failed to list templates", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(templates)
+	case http.MethodPost:
+		var template notes_service.Template
+		if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		template, err := templateService.SaveTemplate(r.Context(), template)
+		if err != nil {
+			writeTemplateError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(template)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func deleteTemplateHandler(w http.ResponseWriter, r *http.Request, templateService *notes_service.TemplateService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templateId, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := templateService.DeleteTemplate(r.Context(), templateId); err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// createNoteFromTemplateHandler creates a note from a template instead of an
+// empty untitled one
+func createNoteFromTemplateHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService, templateService *notes_service.TemplateService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templateId, ok := decodeTemplateRequest(w, r)
+	if !ok {
+		return
+	}
+	note, err := templateService.CreateNote(r.Context(), notesService, templateId, time.Now())
+	if err != nil {
+		writeTemplateError(w, err)
+		return
+	}
+	log.Println("Note created from template: ", note.NoteId)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
+
+func decodeTemplateRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	templateId, err := uuid.Parse(req.TemplateId)
+	if err != nil {
+		http.Error(w, "Invalid template id", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return templateId, true
+}
+
+func writeTemplateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notes_service.ErrTemplateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, notes_service.ErrInvalidTemplate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Error handling template request: %v", err)
+		http.Error(w, "Failed to handle template request", http.StatusInternalServerError)
+	}
+}
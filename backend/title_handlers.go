@@ -0,0 +1,46 @@
+package main
+
+import (
+	"backend/notes_service"
+	"backend/title_service"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// revertTitleHandler gives a note with a generated title its default title
+// back
+func revertTitleHandler(w http.ResponseWriter, r *http.Request, titleService *title_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GetNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	noteId, err := uuid.Parse(req.NoteId)
+	if err != nil {
+		http.Error(w, "Invalid note id", http.StatusBadRequest)
+		return
+	}
+
+	note, err := titleService.Revert(r.Context(), noteId)
+	switch {
+	case errors.Is(err, title_service.ErrNoGeneratedTitle), errors.Is(err, notes_service.ErrNoteNotFound):
+		http.Error(w, "Note has no generated title", http.StatusNotFound)
+	case errors.Is(err, title_service.ErrTitleChanged):
+		http.Error(w, "The note was renamed after its title was generated", http.StatusConflict)
+	case err != nil:
+		log.Printf("Error reverting title: %v", err)
+		http.Error(w, "Failed to revert title", http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(note)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+The title_service package names untitled notes. Once a note still called
+"Untitled Note" has enough content and has not been edited for a while, the
+local model is asked for a title, which is applied unless the note was renamed
+in the meantime.
+
+Generated titles are recorded so they can be shown as such and reverted. A
+note whose title was reverted is not given another.
+*/
+package title_service
+
+import (
+	"backend/db"
+	"backend/lm_service"
+	"backend/notes_service"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long a note must go without edits before it is titled
+const idleTime = 2 * time.Minute
+
+// Notes shorter than this do not say enough to be titled
+const minWords = 25
+
+// Notes become idle without anything changing, so candidates are looked for
+// on a timer rather than after changes
+const sweepInterval = time.Minute
+
+var (
+	ErrNoGeneratedTitle = errors.New("note has no generated title")
+	ErrTitleChanged     = errors.New("title was changed after it was generated")
+)
+
+// TitleGenerator is the part of the chat service the job needs
+type TitleGenerator interface {
+	GenerateTitle(ctx context.Context, content string) (string, error)
+	GetStatus() bool
+}
+
+type Service struct {
+	notesService notes_service.NotesService
+	generator    TitleGenerator
+	dbClient     *db_client.DBClient
+
+	// Held while titles are being generated
+	mu sync.Mutex
+	// Notes the model gave no usable title for, with the UpdatedAt of the
+	// version it was shown, so they are only tried again once edited
+	failed map[uuid.UUID]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewService creates the job. Titles are applied through notesService, so
+// it should be the one that notifies the vault sync and analysis of changes.
+func NewService(notesService notes_service.NotesService, generator TitleGenerator, dbClient *db_client.DBClient) *Service {
+	return &Service{
+		notesService: notesService,
+		generator:    generator,
+		dbClient:     dbClient,
+		failed:       map[uuid.UUID]time.Time{},
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Run titles idle untitled notes every sweep interval until ctx is cancelled
+// or the service is closed
+func (service *Service) Run(ctx context.Context) {
+	defer close(service.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-service.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !service.generator.GetStatus() {
+				continue
+			}
+			titled, err := service.TitlePending(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Title generation stopped after %d notes: %v", titled, err)
+			} else if titled > 0 {
+				log.Printf("Generated titles for %d notes", titled)
+			}
+		}
+	}
+}
+
+// Close stops the job, abandoning the title being generated, and waits for
+// it to finish
+func (service *Service) Close(ctx context.Context) error {
+	service.stopOnce.Do(func() { close(service.stop) })
+	select {
+	case <-service.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TitlePending generates titles for every untitled note that is long enough
+// and idle, and returns how many notes were renamed
+func (service *Service) TitlePending(ctx context.Context) (int, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	notes, err := service.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	generated, err := loadGeneratedTitles(ctx, service.dbClient)
+	if err != nil {
+		return 0, err
+	}
+
+	titled := 0
+	idleSince := time.Now().Add(-idleTime)
+	for _, note := range notes {
+		if err := ctx.Err(); err != nil {
+			return titled, err
+		}
+		_, done := generated[note.NoteId]
+		failedAt, failed := service.failed[note.NoteId]
+		if note.Title != notes_service.DefaultTitle || done || note.UpdatedAt.After(idleSince) ||
+			failed && failedAt.Equal(note.UpdatedAt) || len(strings.Fields(note.Content)) < minWords {
+			continue
+		}
+
+		title, err := service.generator.GenerateTitle(ctx, note.Content)
+		if errors.Is(err, lm_service.ErrInvalidTitle) || err == nil && title == notes_service.DefaultTitle {
+			service.failed[note.NoteId] = note.UpdatedAt
+			continue
+		}
+		if err != nil {
+			return titled, fmt.Errorf("failed to generate a title for note %v: %w", note.NoteId, err)
+		}
+		delete(service.failed, note.NoteId)
+
+		// The note may have been renamed while the model was working
+		renamed, err := service.notesService.RenameNote(ctx, note.NoteId, notes_service.DefaultTitle, title)
+		if err != nil {
+			return titled, err
+		}
+		if !renamed {
+			continue
+		}
+		if err := saveGeneratedTitle(ctx, service.dbClient, note.NoteId, title); err != nil {
+			return titled, err
+		}
+		titled++
+	}
+	return titled, nil
+}
+
+// Revert puts back the default title of a note that was given a generated
+// one, and returns the note. It fails with ErrTitleChanged if the note has
+// been renamed since.
+func (service *Service) Revert(ctx context.Context, noteId uuid.UUID) (notes_service.Note, error) {
+	generated, err := loadGeneratedTitle(ctx, service.dbClient, noteId)
+	if err != nil {
+		return notes_service.Note{}, err
+	}
+	if generated.Reverted {
+		return notes_service.Note{}, fmt.Errorf("%w: %v", ErrNoGeneratedTitle, noteId)
+	}
+	renamed, err := service.notesService.RenameNote(ctx, noteId, generated.Title, notes_service.DefaultTitle)
+	if err != nil {
+		return notes_service.Note{}, err
+	}
+	if !renamed {
+		return notes_service.Note{}, fmt.Errorf("%w: %v", ErrTitleChanged, noteId)
+	}
+	if err := markReverted(ctx, service.dbClient, noteId); err != nil {
+		return notes_service.Note{}, err
+	}
+	return service.notesService.GetNote(ctx, noteId)
+}
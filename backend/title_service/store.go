@@ -0,0 +1,57 @@
+package title_service
+
+import (
+	"backend/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type generatedTitle struct {
+	Title    string
+	Reverted bool
+}
+
+func loadGeneratedTitles(ctx context.Context, executor db_client.Executor) (map[uuid.UUID]generatedTitle, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, title, reverted FROM generated_titles")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := map[uuid.UUID]generatedTitle{}
+	for rows.Next() {
+		var noteId uuid.UUID
+		var title generatedTitle
+		if err := rows.Scan(&noteId, &title.Title, &title.Reverted); err != nil {
+			return nil, err
+		}
+		titles[noteId] = title
+	}
+	return titles, rows.Err()
+}
+
+func loadGeneratedTitle(ctx context.Context, executor db_client.Executor, noteId uuid.UUID) (generatedTitle, error) {
+	var title generatedTitle
+	err := executor.QueryRowContext(ctx, "SELECT title, reverted FROM generated_titles WHERE note_id = ?", noteId).Scan(&title.Title, &title.Reverted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return title, fmt.Errorf("%w: %v", ErrNoGeneratedTitle, noteId)
+	}
+	return title, err
+}
+
+func saveGeneratedTitle(ctx context.Context, executor db_client.Executor, noteId uuid.UUID, title string) error {
+	sqlStatement := `INSERT INTO generated_titles (note_id, title, generated_at, reverted) VALUES (?, ?, ?, 0)
+		ON CONFLICT (note_id) DO UPDATE SET title = excluded.title, generated_at = excluded.generated_at, reverted = 0`
+	_, err := executor.ExecContext(ctx, sqlStatement, noteId, title, time.Now())
+	return err
+}
+
+func markReverted(ctx context.Context, executor db_client.Executor, noteId uuid.UUID) error {
+	_, err := executor.ExecContext(ctx, "UPDATE generated_titles SET reverted = 1 WHERE note_id = ?", noteId)
+	return err
+}
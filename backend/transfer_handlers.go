@@ -0,0 +1,417 @@
+package main
+
+import (
+	"backend/clarity_service"
+	"backend/digest_service"
+	"backend/notes_service"
+	"backend/vault_sync"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Largest archive accepted by the import endpoints
+const maxImportSize = 256 << 20
+
+type ExportMarkdownRequest struct {
+	notes_service.NoteFilter
+	// When set, the files are written into this folder inside the configured
+	// export folder instead of being returned as a zip
+	Directory string `json:"Directory"`
+}
+
+type ExportMarkdownResponse struct {
+	Exported  int    `json:"Exported"`
+	Directory string `json:"Directory"`
+}
+
+// exportMarkdownHandler only writes to disk below exportDir, so a request
+// cannot overwrite files elsewhere
+func exportMarkdownHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService, exportDir string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExportMarkdownRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	dir := ""
+	if req.Directory != "" {
+		if exportDir == "" {
+			http.Error(w, "Exporting to a folder is not enabled", http.StatusBadRequest)
+			return
+		}
+		if !filepath.IsLocal(req.Directory) {
+			http.Error(w, "Directory must be a relative path inside the export folder", http.StatusBadRequest)
+			return
+		}
+		dir = filepath.Join(exportDir, req.Directory)
+	}
+
+	notes, err := notesService.GetAllNotes(r.Context())
+	if err != nil {
+		log.Printf("Error getting notes: %v", err)
+		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
+		return
+	}
+	notes = req.Apply(notes)
+
+	if dir != "" {
+		if err := notes_service.WriteMarkdownDir(dir, notes); err != nil {
+			log.Printf("Error exporting notes to %s: %v", dir, err)
+			http.Error(w, "Failed to export notes", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Exported %d notes to %s", len(notes), dir)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ExportMarkdownResponse{Exported: len(notes), Directory: dir})
+		return
+	}
+
+	// Build the archive before writing anything so a failure can still be
+	// reported with an error status
+	var buf bytes.Buffer
+	if err := notes_service.WriteMarkdownZip(&buf, notes); err != nil {
+		log.Printf("Error exporting notes: %v", err)
+		http.Error(w, "Failed to export notes", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("athena-journal-%s.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Write(buf.Bytes())
+	log.Printf("Exported %d notes as Markdown", len(notes))
+}
+
+type ExportHTMLRequest struct {
+	notes_service.NoteFilter
+	// Heading of the exported site
+	Title string `json:"Title"`
+	// Adds the stored clarity reports and digests overlapping From and To
+	IncludeReflections bool `json:"IncludeReflections"`
+}
+
+func exportHTMLHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService, clarityService *clarity_service.Service, digestService *digest_service.Service) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExportHTMLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := notesService.GetAllNotes(r.Context())
+	if err != nil {
+		log.Printf("Error getting notes: %v", err)
+		http.Error(w, "Failed to get notes", http.StatusInternalServerError)
+		return
+	}
+	notes = req.Apply(notes)
+
+	options := notes_service.HTMLExportOptions{Title: req.Title}
+	if req.IncludeReflections {
+		options.Reflections, err = loadReflections(r.Context(), req.From, req.To, clarityService, digestService)
+		if err != nil {
+			log.Printf("Error getting reflections: %v", err)
+			http.Error(w, "Failed to get reflections", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := notes_service.WriteHTMLZip(&buf, notes, options); err != nil {
+		log.Printf("Error exporting notes as HTML: %v", err)
+		http.Error(w, "Failed to export notes", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("athena-journal-%s-html.zip", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.Write(buf.Bytes())
+	log.Printf("Exported %d notes as HTML", len(notes))
+}
+
+// loadReflections returns the clarity reports and digests whose range
+// overlaps from and to, either of which may be zero for an open end, oldest
+// range first
+func loadReflections(ctx context.Context, from time.Time, to time.Time, clarityService *clarity_service.Service, digestService *digest_service.Service) ([]notes_service.HTMLReflection, error) {
+	type dated struct {
+		notes_service.HTMLReflection
+		start time.Time
+	}
+	overlaps := func(start time.Time, end time.Time) bool {
+		return (to.IsZero() || start.IsZero() || start.Before(to)) && (from.IsZero() || end.IsZero() || end.After(from))
+	}
+	found := []dated{}
+
+	reports, err := clarityService.History(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range reports {
+		var start, end time.Time
+		if summary.From != nil {
+			start = *summary.From
+		}
+		if summary.To != nil {
+			end = *summary.To
+		}
+		if !overlaps(start, end) {
+			continue
+		}
+		report, err := clarityService.Get(ctx, summary.ReportId)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, dated{notes_service.HTMLReflection{
+			Title:     "Clarity: " + report.Description,
+			CreatedAt: report.CreatedAt,
+			Content:   report.Content,
+		}, start})
+	}
+
+	digests, err := digestService.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range digests {
+		start, err := time.ParseInLocation(time.DateOnly, summary.PeriodStart, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		last, err := time.ParseInLocation(time.DateOnly, summary.PeriodEnd, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		if !overlaps(start, last.AddDate(0, 0, 1)) {
+			continue
+		}
+		digest, err := digestService.Get(ctx, summary.DigestId)
+		if err != nil {
+			return nil, err
+		}
+		title := "Week of " + start.Format("2 January 2006")
+		if digest.Kind == digest_service.KindMonthly {
+			title = start.Format("January 2006")
+		}
+		found = append(found, dated{notes_service.HTMLReflection{
+			Title:     "Digest: " + title,
+			CreatedAt: digest.CreatedAt,
+			Content:   digest.Content,
+		}, start})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].start.Before(found[j].start)
+	})
+	reflections := []notes_service.HTMLReflection{}
+	for _, reflection := range found {
+		reflections = append(reflections, reflection.HTMLReflection)
+	}
+	return reflections, nil
+}
+
+// importMarkdownHandler accepts either a zip of Markdown files or a single
+// Markdown file as the request body.
+func importMarkdownHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	notes, skipped, err := notes_service.MarkdownImporter{}.Parse(r.URL.Query().Get("filename"), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := notes_service.ImportNotes(r.Context(), notesService, notes, skipped)
+	if err != nil {
+		log.Printf("Error importing notes: %v", err)
+		http.Error(w, "Failed to import notes", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Imported Markdown: %d created, %d updated, %d skipped", result.Created, result.Updated, len(result.Skipped))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// importDayOneHandler accepts a Day One JSON export, either the zip Day One
+// produces or a single journal JSON file.
+func importDayOneHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	notes, skipped, err := notes_service.DayOneImporter{}.Parse("", body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := notes_service.ImportNotes(r.Context(), notesService, notes, skipped)
+	if err != nil {
+		log.Printf("Error importing notes: %v", err)
+		http.Error(w, "Failed to import notes", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Imported Day One: %d created, %d updated, %d skipped", result.Created, result.Updated, len(result.Skipped))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// vaultSyncHandler runs a vault sync straight away rather than waiting for the
+// next change
+func vaultSyncHandler(w http.ResponseWriter, r *http.Request, syncer *vault_sync.Syncer) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if syncer == nil {
+		http.Error(w, "Vault sync is not configured", http.StatusBadRequest)
+		return
+	}
+
+	result, err := syncer.Sync(r.Context())
+	if err != nil {
+		log.Printf("Error syncing vault: %v", err)
+		http.Error(w, "Failed to sync vault", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ImportProgress is one line of the /import response stream. The last line
+// carries the result, or an error if the import failed part way.
+type ImportProgress struct {
+	Done   int                         `json:"Done"`
+	Total  int                         `json:"Total"`
+	Result *notes_service.ImportResult `json:"Result,omitempty"`
+	Error  string                      `json:"Error,omitempty"`
+}
+
+// How often progress lines are written while importing
+const importProgressInterval = 250 * time.Millisecond
+
+// importHandler imports a file in any supported format from a multipart
+// upload with the fields "file", "format" (detected from the file when
+// omitted) and "dryRun". A dry run responds with a preview; otherwise progress
+// is streamed as one JSON object per line.
+func importHandler(w http.ResponseWriter, r *http.Request, notesService notes_service.NotesService, checkInService *notes_service.CheckInService) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart/form-data upload", http.StatusBadRequest)
+		return
+	}
+	var format, fileName string
+	var data []byte
+	dryRun := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Error reading upload", http.StatusBadRequest)
+			return
+		}
+		value, err := io.ReadAll(part)
+		if err != nil {
+			http.Error(w, "Error reading upload", http.StatusBadRequest)
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			fileName, data = part.FileName(), value
+		case "format":
+			format = strings.TrimSpace(string(value))
+		case "dryRun":
+			dryRun, _ = strconv.ParseBool(strings.TrimSpace(string(value)))
+		}
+	}
+	if data == nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+
+	importer, err := notes_service.FindImporter(format, fileName, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	notes, skipped, err := importer.Parse(fileName, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if dryRun {
+		preview, err := notes_service.PreviewImport(r.Context(), notesService, checkInService, importer.Name(), notes, skipped)
+		if err != nil {
+			log.Printf("Error previewing import: %v", err)
+			http.Error(w, "Failed to preview import", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	send := func(progress ImportProgress) {
+		encoder.Encode(progress)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+	lastSent := time.Time{}
+	result, err := notes_service.ImportNotesWithProgress(r.Context(), notesService, notes, skipped, func(done int, total int) {
+		if time.Since(lastSent) >= importProgressInterval {
+			send(ImportProgress{Done: done, Total: total})
+			lastSent = time.Now()
+		}
+	})
+	if err != nil {
+		log.Printf("Error importing notes: %v", err)
+		send(ImportProgress{Error: "Failed to import notes"})
+		return
+	}
+	log.Printf("Imported %s: %d created, %d updated, %d skipped", importer.Name(), result.Created, result.Updated, len(result.Skipped))
+	send(ImportProgress{Done: len(notes), Total: len(notes), Result: &result})
+}
@@ -0,0 +1,9 @@
+package vault_sync
+
+import "backend/notes_service"
+
+// NotifyOnChange wraps notesService so that changes made through it, e.g. by
+// the HTTP handlers, are mirrored into the vault.
+func (syncer *Syncer) NotifyOnChange(notesService notes_service.NotesService) notes_service.NotesService {
+	return notes_service.NotifyOnChange(notesService, syncer.Trigger)
+}
@@ -0,0 +1,53 @@
+package vault_sync
+
+import (
+	"backend/db"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// syncState records what a note and its file looked like when they were last
+// in sync, so the next sync can tell which side changed.
+type syncState struct {
+	NoteId   uuid.UUID
+	FileName string
+	// sha256 of the file as we last wrote or read it
+	FileHash string
+	// The note's UpdatedAt and noteHash when the file was last written or
+	// read
+	NoteUpdatedAt time.Time
+	NoteHash      string
+}
+
+func loadStates(ctx context.Context, executor db_client.Executor, vault string) (map[uuid.UUID]syncState, error) {
+	rows, err := executor.QueryContext(ctx, "SELECT note_id, file_name, file_hash, note_updated_at, note_hash FROM vault_sync_state WHERE vault = ?", vault)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := map[uuid.UUID]syncState{}
+	for rows.Next() {
+		var state syncState
+		if err := rows.Scan(&state.NoteId, &state.FileName, &state.FileHash, &state.NoteUpdatedAt, &state.NoteHash); err != nil {
+			return nil, err
+		}
+		states[state.NoteId] = state
+	}
+	return states, rows.Err()
+}
+
+func saveState(ctx context.Context, executor db_client.Executor, vault string, state syncState) error {
+	sqlStatement := `INSERT INTO vault_sync_state (vault, note_id, file_name, file_hash, note_updated_at, note_hash) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (vault, note_id) DO UPDATE SET file_name = excluded.file_name, file_hash = excluded.file_hash,
+		note_updated_at = excluded.note_updated_at, note_hash = excluded.note_hash`
+	_, err := executor.ExecContext(ctx, sqlStatement, vault, state.NoteId, state.FileName, state.FileHash, state.NoteUpdatedAt, state.NoteHash)
+	return err
+}
+
+func deleteState(ctx context.Context, executor db_client.Executor, vault string, noteId uuid.UUID) error {
+	_, err := executor.ExecContext(ctx, "DELETE FROM vault_sync_state WHERE vault = ? AND note_id = ?", vault, noteId)
+	return err
+}
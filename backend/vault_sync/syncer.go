@@ -0,0 +1,427 @@
+/*
+The vault_sync package mirrors the journal into a folder of Markdown files,
+such as a folder inside an Obsidian vault, and applies edits made to those
+files back to the notes.
+
+Every note is written once under a name derived from its date and title, and
+keeps that file from then on, even if the note is retitled or the file is
+renamed. Files are matched to notes by the id in their front matter. When a
+note and its file have both changed since the last sync the file is rewritten
+from the note and the edited version is kept next to it as a conflict file.
+Deleting a note removes its file; deleting a file does not delete the note,
+which is written out again on the next sync. Edits with an invalid check-in,
+such as a mood out of range, are not applied until the file is fixed.
+*/
+package vault_sync
+
+import (
+	"backend/db"
+	"backend/notes_service"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/google/uuid"
+)
+
+// How long the folder and notes must be quiet before a sync runs, so a burst
+// of saves results in a single sync
+const debounce = 500 * time.Millisecond
+
+// Conflict files are named after the file they conflict with, e.g.
+// "2026-10-01-weekly-review (conflict 2026-10-02 184501).md"
+var conflictFileName = regexp.MustCompile(` \(conflict \d{4}-\d{2}-\d{2} \d{6}\)\.md$`)
+
+type Syncer struct {
+	dir          string
+	notesService notes_service.NotesService
+	dbClient     *db_client.DBClient
+	watcher      *fsnotify.Watcher
+
+	// Held for the duration of a sync
+	mu      sync.Mutex
+	trigger chan struct{}
+	done    chan struct{}
+}
+
+// SyncResult summarises a sync
+type SyncResult struct {
+	// Files written from notes
+	Exported int `json:"Exported"`
+	// Notes updated from edited files
+	Imported int `json:"Imported"`
+	// Conflict files written because a note and its file both changed
+	Conflicts []string `json:"Conflicts"`
+	// Files removed because their note was deleted
+	Removed int `json:"Removed"`
+	// Edited files that were not applied, such as ones with an invalid
+	// check-in. They are read again by every sync until fixed.
+	Skipped []notes_service.ImportSkip `json:"Skipped"`
+}
+
+// NewSyncer creates a syncer for the folder dir, creating it if needed.
+// Changes to notes are applied through notesService and the sync state is
+// kept in the database.
+func NewSyncer(dir string, notesService notes_service.NotesService, dbClient *db_client.DBClient) (*Syncer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create vault folder %s: %w", dir, err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault watcher: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch vault folder %s: %w", dir, err)
+	}
+	return &Syncer{
+		dir:          dir,
+		notesService: notesService,
+		dbClient:     dbClient,
+		watcher:      watcher,
+		trigger:      make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Run syncs once, then again whenever the folder or the notes change, until
+// ctx is cancelled or the syncer is closed.
+func (syncer *Syncer) Run(ctx context.Context) {
+	defer close(syncer.done)
+	syncer.syncAndLog(ctx)
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-syncer.watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.EqualFold(filepath.Ext(event.Name), ".md") {
+				timer.Reset(debounce)
+			}
+		case err, ok := <-syncer.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been dropped, so check everything
+			log.Printf("Vault watcher error: %v", err)
+			timer.Reset(debounce)
+		case <-syncer.trigger:
+			timer.Reset(debounce)
+		case <-timer.C:
+			syncer.syncAndLog(ctx)
+		}
+	}
+}
+
+// Trigger schedules a sync, e.g. after a note was changed
+func (syncer *Syncer) Trigger() {
+	select {
+	case syncer.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops watching the folder and waits for a running sync to finish
+func (syncer *Syncer) Close(ctx context.Context) error {
+	err := syncer.watcher.Close()
+	select {
+	case <-syncer.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (syncer *Syncer) syncAndLog(ctx context.Context) {
+	result, err := syncer.Sync(ctx)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("Vault sync failed: %v", err)
+		}
+		return
+	}
+	if result.Exported+result.Imported+len(result.Conflicts)+result.Removed+len(result.Skipped) > 0 {
+		log.Printf("Vault sync: %d exported, %d imported, %d conflicts, %d removed, %d skipped",
+			result.Exported, result.Imported, len(result.Conflicts), result.Removed, len(result.Skipped))
+	}
+}
+
+// Sync brings the folder and the notes in line with each other
+func (syncer *Syncer) Sync(ctx context.Context) (SyncResult, error) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+
+	result := SyncResult{Conflicts: []string{}, Skipped: []notes_service.ImportSkip{}}
+	notes, err := syncer.notesService.GetAllNotes(ctx)
+	if err != nil {
+		return result, err
+	}
+	states, err := loadStates(ctx, syncer.dbClient, syncer.dir)
+	if err != nil {
+		return result, err
+	}
+	filesById, taken, err := syncer.scan()
+	if err != nil {
+		return result, err
+	}
+	for _, state := range states {
+		taken[state.FileName] = true
+	}
+
+	for _, note := range notes {
+		state, tracked := states[note.NoteId]
+		delete(states, note.NoteId)
+
+		// A file renamed in the vault is found again by its id
+		name := ""
+		if tracked && syncer.exists(state.FileName) {
+			name = state.FileName
+		} else if found, ok := filesById[note.NoteId]; ok {
+			name = found
+		}
+
+		var err error
+		switch {
+		case name == "":
+			name = uniqueFileName(note, taken)
+			taken[name] = true
+			err = syncer.export(ctx, note, name, &result)
+		case !tracked:
+			// The file was synced before but the record of it is gone, so
+			// assume both sides changed unless they already agree
+			err = syncer.reconcile(ctx, note, name, nil, &result)
+		default:
+			err = syncer.reconcile(ctx, note, name, &state, &result)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			log.Printf("Vault sync of note %v (%s) failed: %v", note.NoteId, name, err)
+		}
+	}
+
+	// Whatever is left belongs to notes that were deleted
+	for _, state := range states {
+		if err := syncer.remove(ctx, state, &result); err != nil {
+			log.Printf("Vault sync of deleted note %v (%s) failed: %v", state.NoteId, state.FileName, err)
+		}
+	}
+	return result, nil
+}
+
+// reconcile syncs a note with its existing file. state is nil when there is no
+// record of the last sync.
+func (syncer *Syncer) reconcile(ctx context.Context, note notes_service.Note, name string, state *syncState, result *SyncResult) error {
+	data, err := os.ReadFile(filepath.Join(syncer.dir, name))
+	if err != nil {
+		return err
+	}
+	fileHash := hashOf(data)
+
+	if state == nil {
+		exported, err := notes_service.MarshalMarkdown(note)
+		if err != nil {
+			return err
+		}
+		if hashOf(exported) == fileHash {
+			return syncer.saveState(ctx, note, name, fileHash)
+		}
+		return syncer.conflict(ctx, note, name, data, result)
+	}
+
+	fileChanged := fileHash != state.FileHash
+	// Automatic renames keep UpdatedAt, so the title and content are compared
+	// too
+	noteChanged := !note.UpdatedAt.Equal(state.NoteUpdatedAt) || state.NoteHash != "" && state.NoteHash != noteHash(note)
+	switch {
+	case fileChanged && noteChanged:
+		return syncer.conflict(ctx, note, name, data, result)
+	case fileChanged:
+		return syncer.importFile(ctx, note, name, data, result)
+	case noteChanged:
+		return syncer.export(ctx, note, name, result)
+	case name != state.FileName:
+		// Renamed in the vault, follow the new name
+		return syncer.saveState(ctx, note, name, fileHash)
+	}
+	return nil
+}
+
+// export writes the note to its file
+func (syncer *Syncer) export(ctx context.Context, note notes_service.Note, name string, result *SyncResult) error {
+	data, err := notes_service.MarshalMarkdown(note)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(syncer.dir, name), data); err != nil {
+		return err
+	}
+	result.Exported++
+	return syncer.saveState(ctx, note, name, hashOf(data))
+}
+
+// importFile applies an edited file to its note
+func (syncer *Syncer) importFile(ctx context.Context, note notes_service.Note, name string, data []byte, result *SyncResult) error {
+	edited, err := notes_service.UnmarshalMarkdown(name, data)
+	if err != nil {
+		return err
+	}
+	// The file belongs to this note even if its id was edited or removed
+	edited.NoteId = note.NoteId
+	rewritten, err := syncer.notesService.UpdateNote(ctx, edited)
+	if errors.Is(err, notes_service.ErrInvalidCheckIn) {
+		// The state is left as it was, so the file is read again once fixed
+		log.Printf("Vault sync skipped %s: %v", name, err)
+		result.Skipped = append(result.Skipped, notes_service.ImportSkip{Name: name, Reason: err.Error()})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Renaming the note rewrote the links to it in other notes, which this
+	// sync may have passed already, so they are written out by the next one
+	if len(rewritten) > 0 {
+		syncer.Trigger()
+	}
+	updated, err := syncer.notesService.GetNote(ctx, note.NoteId)
+	if err != nil {
+		return err
+	}
+	result.Imported++
+	return syncer.saveState(ctx, updated, name, hashOf(data))
+}
+
+// conflict keeps the vault's version of the file as a conflict file and
+// rewrites the file from the note
+func (syncer *Syncer) conflict(ctx context.Context, note notes_service.Note, name string, data []byte, result *SyncResult) error {
+	conflictName := fmt.Sprintf("%s (conflict %s).md", strings.TrimSuffix(name, filepath.Ext(name)), time.Now().Format("2006-01-02 150405"))
+	if err := writeFileAtomic(filepath.Join(syncer.dir, conflictName), data); err != nil {
+		return err
+	}
+	log.Printf("Vault sync conflict on %s, kept the vault's version as %s", name, conflictName)
+	result.Conflicts = append(result.Conflicts, conflictName)
+	return syncer.export(ctx, note, name, result)
+}
+
+// remove deletes the file of a deleted note, unless it was edited in the vault
+// since the last sync
+func (syncer *Syncer) remove(ctx context.Context, state syncState, result *SyncResult) error {
+	path := filepath.Join(syncer.dir, state.FileName)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case hashOf(data) != state.FileHash:
+		log.Printf("Vault sync kept %s, its note was deleted but the file was edited", state.FileName)
+	default:
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		result.Removed++
+	}
+	return deleteState(ctx, syncer.dbClient, syncer.dir, state.NoteId)
+}
+
+func (syncer *Syncer) saveState(ctx context.Context, note notes_service.Note, name string, fileHash string) error {
+	return saveState(ctx, syncer.dbClient, syncer.dir, syncState{
+		NoteId:        note.NoteId,
+		FileName:      name,
+		FileHash:      fileHash,
+		NoteUpdatedAt: note.UpdatedAt,
+		NoteHash:      noteHash(note),
+	})
+}
+
+// scan lists the Markdown files in the folder, returning the file each note
+// id is found in and the set of every file name
+func (syncer *Syncer) scan() (map[uuid.UUID]string, map[string]bool, error) {
+	entries, err := os.ReadDir(syncer.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	filesById := map[uuid.UUID]string{}
+	names := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(name), ".md") {
+			continue
+		}
+		names[name] = true
+		if conflictFileName.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(syncer.dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		// Files without an id are not ours and are left alone
+		noteId, ok := notes_service.MarkdownNoteId(data)
+		if !ok {
+			continue
+		}
+		if _, seen := filesById[noteId]; !seen {
+			filesById[noteId] = name
+		}
+	}
+	return filesById, names, nil
+}
+
+func (syncer *Syncer) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(syncer.dir, name))
+	return err == nil
+}
+
+// uniqueFileName names a new file, falling back to a name carrying part of the
+// note's id when another file already has the usual name
+func uniqueFileName(note notes_service.Note, taken map[string]bool) string {
+	name := notes_service.MarkdownFileName(note)
+	if taken[name] {
+		name = strings.TrimSuffix(name, ".md") + "-" + note.NoteId.String()[:8] + ".md"
+	}
+	return name
+}
+
+// writeFileAtomic writes through a temporary file so editors watching the
+// folder never see a partly written note
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".athena-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// noteHash identifies the title and content of a note
+func noteHash(note notes_service.Note) string {
+	return hashOf([]byte(note.Title + "\n" + note.Content))
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
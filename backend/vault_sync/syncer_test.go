@@ -0,0 +1,131 @@
+package vault_sync
+
+import (
+	"backend/db"
+	"backend/notes_service"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestSyncer(t *testing.T) (*Syncer, notes_service.NotesService, string) {
+	t.Helper()
+	ctx := context.Background()
+	dbClient, err := db_client.NewDBClient(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbClient.Close(ctx) })
+	notesService := notes_service.NewNotesServiceImpl(dbClient)
+	vault := t.TempDir()
+	syncer, err := NewSyncer(vault, notesService, dbClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syncer.watcher.Close() })
+	return syncer, notesService, vault
+}
+
+// TestSyncExportsAutomaticRenames checks that a rename keeping the note's
+// updated_at still reaches the vault
+func TestSyncExportsAutomaticRenames(t *testing.T) {
+	ctx := context.Background()
+	syncer, notesService, vault := newTestSyncer(t)
+
+	note, err := notesService.CreateNoteWithContent(ctx, notes_service.DefaultTitle, "A long walk by the river")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := syncer.Sync(ctx); err != nil || result.Exported != 1 {
+		t.Fatalf("got %+v: %v", result, err)
+	}
+
+	if _, err := notesService.RenameNote(ctx, note.NoteId, notes_service.DefaultTitle, "River walk"); err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := notesService.GetNote(ctx, note.NoteId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renamed.UpdatedAt.Equal(note.UpdatedAt) {
+		t.Errorf("the rename moved updated_at from %v to %v", note.UpdatedAt, renamed.UpdatedAt)
+	}
+
+	result, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Exported != 1 || len(result.Conflicts) > 0 {
+		t.Errorf("got %+v, want the renamed note exported", result)
+	}
+	files, err := os.ReadDir(vault)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got %d files in the vault: %v", len(files), err)
+	}
+	data, err := os.ReadFile(filepath.Join(vault, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "River walk") {
+		t.Errorf("the vault file is missing the new title:\n%s", data)
+	}
+
+	if result, err := syncer.Sync(ctx); err != nil || result.Exported != 0 {
+		t.Errorf("got %+v from a sync without changes: %v", result, err)
+	}
+}
+
+func TestSyncSkipsInvalidCheckIns(t *testing.T) {
+	ctx := context.Background()
+	syncer, notesService, vault := newTestSyncer(t)
+	note, err := notesService.CreateNoteWithContent(ctx, "Morning", "Slept well")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(vault)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got %d files in the vault: %v", len(files), err)
+	}
+	path := filepath.Join(vault, files[0].Name())
+
+	edit := func(mood int) {
+		t.Helper()
+		edited := note
+		edited.Content = "Slept very well"
+		edited.CheckIn = &notes_service.CheckIn{Mood: &mood}
+		data, err := notes_service.MarshalMarkdown(edited)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edit(9)
+	for range 2 {
+		result, err := syncer.Sync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Imported != 0 || len(result.Skipped) != 1 || result.Skipped[0].Name != files[0].Name() {
+			t.Errorf("got %+v, want the file skipped", result)
+		}
+	}
+	if stored, err := notesService.GetNote(ctx, note.NoteId); err != nil || stored.Content != "Slept well" || stored.CheckIn != nil {
+		t.Errorf("got %q with check-in %+v from an invalid file: %v", stored.Content, stored.CheckIn, err)
+	}
+
+	edit(4)
+	if result, err := syncer.Sync(ctx); err != nil || result.Imported != 1 || len(result.Skipped) > 0 {
+		t.Errorf("got %+v once the file was fixed: %v", result, err)
+	}
+	if stored, err := notesService.GetNote(ctx, note.NoteId); err != nil || stored.CheckIn == nil || *stored.CheckIn.Mood != 4 {
+		t.Errorf("got check-in %+v once the file was fixed: %v", stored.CheckIn, err)
+	}
+}
@@ -24,23 +24,23 @@ cd backend
 
 # Build for macOS ARM64 (Apple Silicon)
 echo "Building for macOS ARM64..."
-GOOS=darwin GOARCH=arm64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-aarch64-apple-darwin main.go
+GOOS=darwin GOARCH=arm64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-aarch64-apple-darwin .
 
 # Build for macOS Intel64
 echo "Building for macOS Intel64..."
-GOOS=darwin GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-apple-darwin main.go
+GOOS=darwin GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-apple-darwin .
 
 # Build for Windows AMD64
 echo "Building for Windows AMD64..."
-GOOS=windows GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-pc-windows-msvc.exe main.go
+GOOS=windows GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-pc-windows-msvc.exe .
 
 # Build for Linux AMD64
 echo "Building for Linux AMD64..."
-GOOS=linux GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-unknown-linux-gnu main.go
+GOOS=linux GOARCH=amd64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-x86_64-unknown-linux-gnu .
 
 # Build for Linux ARM64
 echo "Building for Linux ARM64..."
-GOOS=linux GOARCH=arm64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-aarch64-unknown-linux-gnu main.go
+GOOS=linux GOARCH=arm64 go build -o ../lm-journal/src-tauri/athena-be/athena-backend-aarch64-unknown-linux-gnu .
 
 cd ..
 
@@ -1,16 +1,76 @@
+use tauri::Manager;
+use tauri_plugin_shell::process::CommandEvent;
+use tauri_plugin_shell::ShellExt;
+
+// Per-launch token shared with the backend sidecar. It lives as long as the
+// Tauri process, so reloading the window keeps talking to the same backend.
+struct ApiToken(String);
+
 // Learn more about Tauri commands at https://tauri.app/develop/calling-rust/
 #[tauri::command]
 fn greet(name: &str) -> String {
     format!("Hello, {}! You've been greeted from Rust!", name)
 }
 
+#[tauri::command]
+fn api_token(token: tauri::State<ApiToken>) -> String {
+    token.0.clone()
+}
+
+fn new_api_token() -> String {
+    let mut bytes = [0u8; 32];
+    getrandom::getrandom(&mut bytes).expect("failed to generate the API token");
+    bytes.iter().map(|byte| format!("{:02x}", byte)).collect()
+}
+
+// Starts the backend sidecar with the token. It runs once, from setup, so
+// remounting or reloading the window never starts a second backend.
+fn start_backend(app: &tauri::App, token: &str) -> Result<(), Box<dyn std::error::Error>> {
+    let path = app.path().app_data_dir()?;
+    println!("APP PATH {}", path.display());
+    let (mut events, child) = app
+        .shell()
+        .sidecar("athena-be/athena-backend")?
+        .arg(path)
+        .env("ATHENA_API_TOKEN", token)
+        .spawn()?;
+    println!("Athena backend started with pid {}", child.pid());
+
+    tauri::async_runtime::spawn(async move {
+        while let Some(event) = events.recv().await {
+            match event {
+                CommandEvent::Stdout(line) => {
+                    println!("athena-be stdout: \"{}\"", String::from_utf8_lossy(&line).trim_end())
+                }
+                CommandEvent::Stderr(line) => {
+                    eprintln!("athena-be stderr: \"{}\"", String::from_utf8_lossy(&line).trim_end())
+                }
+                CommandEvent::Error(error) => eprintln!("athena-be error: \"{}\"", error),
+                CommandEvent::Terminated(payload) => {
+                    eprintln!("athena-be exited with code {:?}", payload.code)
+                }
+                _ => {}
+            }
+        }
+    });
+    Ok(())
+}
+
 #[cfg_attr(mobile, tauri::mobile_entry_point)]
 pub fn run() {
     tauri::Builder::default()
         .plugin(tauri_plugin_shell::init())
         .plugin(tauri_plugin_opener::init())
         .plugin(tauri_plugin_fs::init())
-        .invoke_handler(tauri::generate_handler![greet])
+        .setup(|app| {
+            let token = new_api_token();
+            if let Err(error) = start_backend(app, &token) {
+                eprintln!("Failed to start Athena backend: {}", error);
+            }
+            app.manage(ApiToken(token));
+            Ok(())
+        })
+        .invoke_handler(tauri::generate_handler![greet, api_token])
         .run(tauri::generate_context!())
         .expect("error while running tauri application");
 }
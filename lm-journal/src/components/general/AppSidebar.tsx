@@ -24,6 +24,7 @@ import { useNavigate } from "react-router-dom"
 import {
   TooltipProvider,
 } from "@/components/ui/tooltip"
+import { apiFetch } from "@/lib/api"
 
 // Menu items.
 export const AppSidebar = memo(() => {
@@ -36,7 +37,7 @@ export const AppSidebar = memo(() => {
   useHotkeys('meta+shift+c', () => setIsDialogOpen(true), { enableOnFormTags: true });
 
   const createNewNote = async () => {
-    const newNoteCreation = await fetch("http://localhost:8080/createnote", {
+    const newNoteCreation = await apiFetch("/createnote", {
       method: "GET",
       headers: {
         "Content-Type": "application/json",
@@ -0,0 +1,22 @@
+import { invoke } from "@tauri-apps/api/core";
+
+// Base URL of the Athena backend. The backend only listens on loopback.
+export const API_BASE_URL = "http://127.0.0.1:8080";
+
+// Per-launch token shared with the backend sidecar. The backend rejects any
+// request that does not carry it, so other local apps and websites cannot
+// read the journal. The Tauri shell makes it and starts the sidecar once per
+// launch, so it survives reloads of the window.
+let apiToken: Promise<string> | null = null;
+
+export function getApiToken(): Promise<string> {
+  apiToken ??= invoke<string>("api_token");
+  return apiToken;
+}
+
+// fetch wrapper that targets the backend and attaches the bearer token.
+export async function apiFetch(path: string, init: RequestInit = {}): Promise<Response> {
+  const headers = new Headers(init.headers);
+  headers.set("Authorization", `Bearer ${await getApiToken()}`);
+  return fetch(`${API_BASE_URL}${path}`, { ...init, headers });
+}
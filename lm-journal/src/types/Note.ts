@@ -1,9 +1,11 @@
+// Mirrors the Note schema in backend/openapi.json. Timestamps are sent as
+// RFC 3339 strings.
 type Note = {
     NoteId: string;
     Title: string;
     Content: string;
-    CreatedAt: Date;
-    UpdatedAt: Date;
+    CreatedAt: string;
+    UpdatedAt: string;
 }
 
 export default Note;
\ No newline at end of file
@@ -3,6 +3,7 @@ import { useEffect, useState, useRef } from "react";
 import { Loader2 } from "lucide-react";
 import ReactMarkdown from "react-markdown";
 import remarkGfm from "remark-gfm";
+import { apiFetch } from "@/lib/api";
 
 // Add this style block to your component or global CSS
 const fadeInStyle = `
@@ -77,7 +78,7 @@ export default function Clarity() {
     bufferRef.current = "";
     const fetchClarity = async () => {
       try {
-        const response = await fetch("http://localhost:8080/clarity", {
+        const response = await apiFetch("/clarity", {
           method: "POST",
           headers: { "Content-Type": "application/json" },
           body: JSON.stringify({ timeframe }),
@@ -11,6 +11,7 @@ import {
     DropdownMenuItem,
     DropdownMenuTrigger,
 } from "@/components/ui/dropdown-menu";
+import { apiFetch } from "@/lib/api";
 
 function Dashboard() {
     const [notes, setNotes] = useState<Note[]>([]);
@@ -18,7 +19,7 @@ function Dashboard() {
     const navigate = useNavigate();
     
     const fetchJournalEntries = async () => {
-        const response = await fetch("http://localhost:8080/getallnotes");
+        const response = await apiFetch("/getallnotes");
         const data = await response.json();
         setNotes(data as Note[]);
     };
@@ -29,7 +30,7 @@ function Dashboard() {
 
     const handleDeleteNote = async (noteId: string, _e: React.MouseEvent) => {
         try {
-            const response = await fetch("http://localhost:8080/deletenote", {
+            const response = await apiFetch("/deletenote", {
                 method: "POST",
                 headers: {
                     "Content-Type": "application/json",
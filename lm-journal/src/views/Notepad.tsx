@@ -7,6 +7,7 @@ import { useParams } from "react-router-dom";
 import Note from "@/types/Note";
 import ReactMarkdown from "react-markdown";
 import remarkGfm from "remark-gfm";
+import { apiFetch } from "@/lib/api";
 
 // Add glass card and fade-in styles
 const glassCardStyles = `
@@ -61,7 +62,7 @@ export default function Notepad() {
     if (!note?.NoteId && !params.id) return;
     
     try {
-      await fetch("http://localhost:8080/updatenote", {
+      await apiFetch("/updatenote", {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -145,7 +146,7 @@ export default function Notepad() {
     setAiResponse(""); // Clear previous response
     
     try {
-      const response = await fetch("http://localhost:8080/chat", {
+      const response = await apiFetch("/chat", {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -209,7 +210,7 @@ export default function Notepad() {
       console.log("Fetching note: ", params.id);
       
       try {
-        const response = await fetch("http://localhost:8080/getnote", {
+        const response = await apiFetch("/getnote", {
           method: "POST",
           headers: {
             "Content-Type": "application/json",
@@ -235,7 +236,7 @@ export default function Notepad() {
           return;
         }
         console.error('Error fetching note:', error);
-        setNote({ NoteId: params.id, Title: "", Content: "", CreatedAt: new Date(), UpdatedAt: new Date() });
+        setNote({ NoteId: params.id, Title: "", Content: "", CreatedAt: new Date().toISOString(), UpdatedAt: new Date().toISOString() });
         setTitle("");
         setContent("");
         setHasUserTyped(false);